@@ -0,0 +1,443 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+const (
+	ACCOUNT_DELETION_GRACE_PERIOD = 30 * 24 * time.Hour
+	// Accounts without a password must present an access token issued this
+	// recently to delete themselves.
+	REAUTHENTICATION_WINDOW = 5 * time.Minute
+	DATA_EXPORT_TTL         = 7 * 24 * time.Hour
+	DATA_EXPORT_POLL        = 10 * time.Second
+	// An export still running after this long is assumed abandoned by a
+	// crashed worker and is claimed again.
+	DATA_EXPORT_LEASE      = 10 * time.Minute
+	ACCOUNT_PURGE_INTERVAL = time.Hour
+)
+
+type deleteUserReqParams struct {
+	Password string `json:"password"`
+}
+
+type dataExportResponse struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+func (cfg *apiConfig) deleteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal := auth.PrincipalFrom(r.Context())
+
+	reqBody := &deleteUserReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	user, err := cfg.store.GetUserByID(r.Context(), principal.UserID)
+	if err != nil {
+		log.Printf("DELETE /api/users: Error retrieving user %s: %v\n", principal.UserID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	// A stolen access token alone shouldn't be enough to delete an account.
+	if user.HashedPassword == UNUSABLE_PASSWORD_HASH {
+		if principal.IssuedAt.IsZero() || time.Since(principal.IssuedAt) > REAUTHENTICATION_WINDOW {
+			apierror.Write(w, apierror.Unauthorized("Please sign in again to delete your account."))
+			return
+		}
+	} else if auth.CheckPasswordHash(reqBody.Password, user.HashedPassword) != nil {
+		apierror.Write(w, apierror.Unauthorized("Incorrect password"))
+		return
+	}
+
+	user, err = cfg.store.ScheduleUserDeletion(r.Context(), database.ScheduleUserDeletionParams{
+		ID: user.ID,
+		DeletionScheduledAt: sql.NullTime{
+			Time:  time.Now().UTC().Add(ACCOUNT_DELETION_GRACE_PERIOD),
+			Valid: true,
+		},
+	})
+	if err != nil {
+		log.Printf("DELETE /api/users: Error scheduling deletion of user %s: %v\n", principal.UserID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := struct {
+		DeletionScheduledAt time.Time `json:"deletion_scheduled_at"`
+	}{user.DeletionScheduledAt.Time}
+
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("DELETE /api/users: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) cancelUserDeletion(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	rows, err := cfg.store.CancelUserDeletion(r.Context(), userID)
+	if err != nil {
+		log.Printf("DELETE /api/users/deletion: Error cancelling deletion of user %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("Your account is not scheduled for deletion."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// purgeDeletedUsers deletes accounts whose grace period has ended. Their
+// content is removed by the cascading foreign keys.
+func (cfg *apiConfig) purgeDeletedUsers(ctx context.Context) error {
+	rows, err := cfg.store.DeleteUsersPastGracePeriod(ctx)
+	if err != nil {
+		return fmt.Errorf("Error deleting users: %v", err)
+	}
+	if rows > 0 {
+		log.Printf("Deleted %d users past their deletion grace period\n", rows)
+	}
+
+	err = cfg.store.DeleteExpiredDataExports(ctx)
+	if err != nil {
+		return fmt.Errorf("Error deleting expired data exports: %v", err)
+	}
+	return nil
+}
+
+func newDataExportResponse(id uuid.UUID, createdAt time.Time, status string, exportErr sql.NullString, completedAt, expiresAt sql.NullTime) dataExportResponse {
+	res := dataExportResponse{
+		ID:          id,
+		CreatedAt:   createdAt,
+		Status:      status,
+		Error:       exportErr.String,
+		CompletedAt: nullTimePtr(completedAt),
+		ExpiresAt:   nullTimePtr(expiresAt),
+	}
+	if status == "complete" {
+		res.DownloadURL = fmt.Sprintf("/api/users/export/%s", id)
+	}
+	return res
+}
+
+func (cfg *apiConfig) requestDataExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	latest, err := cfg.store.GetLatestDataExport(r.Context(), userID)
+	if err == nil && (latest.Status == "pending" || latest.Status == "running") {
+		apierror.Write(w, apierror.Conflict("An export is already in progress."))
+		return
+	}
+
+	export, err := cfg.store.CreateDataExport(r.Context(), userID)
+	if err != nil {
+		log.Printf("POST /api/users/export: Error creating export for user %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := newDataExportResponse(export.ID, export.CreatedAt, export.Status, export.Error, export.CompletedAt, export.ExpiresAt)
+	w.Header().Set("Location", "/api/users/export")
+	w.WriteHeader(http.StatusAccepted)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("POST /api/users/export: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) getDataExport(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	export, err := cfg.store.GetLatestDataExport(r.Context(), userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.NotFound("No export has been requested. POST /api/users/export to start one."))
+		return
+	}
+	if err != nil {
+		log.Printf("GET /api/users/export: Error retrieving export for user %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := newDataExportResponse(export.ID, export.CreatedAt, export.Status, export.Error, export.CompletedAt, export.ExpiresAt)
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/users/export: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) downloadDataExport(w http.ResponseWriter, r *http.Request) {
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	exportID, err := request.PathUUID(r, "exportID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	archive, err := cfg.store.GetDataExportArchive(r.Context(), database.GetDataExportArchiveParams{
+		ID:     exportID,
+		UserID: userID,
+	})
+	if err != nil {
+		apierror.Write(w, apierror.NotFound("Export not found or expired"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="chirpy-export.zip"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(archive)
+}
+
+// processDataExports builds every pending export, along with any whose
+// worker died mid-export. Jobs are claimed with SKIP LOCKED, so any number of
+// replicas can run this concurrently.
+func (cfg *apiConfig) processDataExports(ctx context.Context) error {
+	for {
+		job, err := cfg.store.ClaimDataExport(ctx, time.Now().UTC().Add(-DATA_EXPORT_LEASE))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error claiming data export: %v", err)
+		}
+
+		archive, err := buildDataExport(ctx, cfg.store, job.UserID)
+		if err != nil {
+			log.Printf("Error building data export %s: %v\n", job.ID, err)
+			err = cfg.store.FailDataExport(ctx, database.FailDataExportParams{
+				ID:    job.ID,
+				Error: sql.NullString{String: "Export failed. Please try again later.", Valid: true},
+			})
+		} else {
+			err = cfg.store.CompleteDataExport(ctx, database.CompleteDataExportParams{
+				ID:        job.ID,
+				Archive:   archive,
+				ExpiresAt: sql.NullTime{Time: time.Now().UTC().Add(DATA_EXPORT_TTL), Valid: true},
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("Error saving data export %s: %v", job.ID, err)
+		}
+	}
+}
+
+// buildDataExport returns a zip archive of everything Chirpy stores about a
+// user, one JSON file per kind of data. Secrets and password hashes are left
+// out.
+func buildDataExport(ctx context.Context, q database.Querier, userID uuid.UUID) ([]byte, error) {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps, err := q.GetChirpsByAuthorId(ctx, database.GetChirpsByAuthorIdParams{
+		UserID:   userID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		return nil, err
+	}
+	drafts, err := q.GetUnpublishedChirpsByAuthor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	chirps = append(chirps, drafts...)
+	refreshTokens, err := q.GetRefreshTokensByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	pats, err := q.GetPersonalAccessTokensByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	clients, err := q.GetOAuthClientsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	identities, err := q.GetExternalIdentitiesByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	subscriptionHistory, err := q.GetSubscriptionEventsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	endpoints, err := q.GetWebhookEndpointsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	follows, err := q.GetFollowsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	messages, err := q.GetDirectMessagesBySender(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if messages == nil {
+		messages = []database.DirectMessage{}
+	}
+
+	blocks, err := q.GetBlocksByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	mutes, err := q.GetMutesByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	bookmarks, err := q.GetBookmarksByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if bookmarks == nil {
+		bookmarks = []database.Bookmark{}
+	}
+
+	pinned, err := q.GetPinnedChirpIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if pinned == nil {
+		pinned = []uuid.UUID{}
+	}
+
+	type exportedSession struct {
+		CreatedAt time.Time  `json:"created_at"`
+		ExpiresAt time.Time  `json:"expires_at"`
+		RevokedAt *time.Time `json:"revoked_at"`
+		ClientID  *uuid.UUID `json:"client_id"`
+		Scopes    []string   `json:"scopes"`
+	}
+	sessions := make([]exportedSession, 0, len(refreshTokens))
+	for _, t := range refreshTokens {
+		s := exportedSession{
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: t.ExpiresAt,
+			RevokedAt: nullTimePtr(t.RevokedAt),
+			Scopes:    t.Scopes,
+		}
+		if t.ClientID.Valid {
+			s.ClientID = &t.ClientID.UUID
+		}
+		sessions = append(sessions, s)
+	}
+
+	tokens := make([]personalAccessTokenResponse, 0, len(pats))
+	for _, pat := range pats {
+		tokens = append(tokens, newPersonalAccessTokenResponse(pat))
+	}
+
+	apps := make([]oauthClientResponse, 0, len(clients))
+	for _, c := range clients {
+		apps = append(apps, oauthClientResponse{
+			ClientID:     c.ID,
+			CreatedAt:    c.CreatedAt,
+			Name:         c.Name,
+			RedirectURIs: c.RedirectUris,
+			Scopes:       c.Scopes,
+		})
+	}
+
+	type exportedFollow struct {
+		UserID     uuid.UUID `json:"user_id"`
+		FollowedAt time.Time `json:"followed_at"`
+	}
+	following := make([]exportedFollow, 0, len(follows))
+	for _, f := range follows {
+		following = append(following, exportedFollow{UserID: f.FolloweeID, FollowedAt: f.CreatedAt})
+	}
+
+	webhooks := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, e := range endpoints {
+		webhooks = append(webhooks, newWebhookEndpointResponse(e))
+	}
+
+	files := map[string]any{
+		"profile.json": createUserResponse{
+			ID:          user.ID,
+			CreatedAt:   user.CreatedAt,
+			UpdatedAt:   user.UpdatedAt,
+			Email:       user.Email,
+			IsChirpyRed: user.IsChirpyRed,
+		},
+		"chirps.json":       newChirpsResponse(chirps),
+		"subscription.json": subscriptionHistory,
+		"webhooks.json":     webhooks,
+		"following.json":    following,
+		"messages.json":     messages,
+		"settings.json":     newUserSettings(user),
+		"blocking.json": map[string]any{
+			"blocks": newBlocksResponse(blocks),
+			"mutes":  newMutesResponse(mutes),
+		},
+		"bookmarks.json": map[string]any{
+			"bookmarks":     bookmarks,
+			"pinned_chirps": pinned,
+		},
+		"sessions.json": map[string]any{
+			"refresh_tokens":         sessions,
+			"personal_access_tokens": tokens,
+			"oauth_clients":          apps,
+			"external_identities":    identities,
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, data := range files {
+		f, err := zw.Create(name)
+		if err != nil {
+			return nil, err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(data)
+		if err != nil {
+			return nil, fmt.Errorf("Error encoding %s: %v", name, err)
+		}
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
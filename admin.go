@@ -1,12 +1,29 @@
 package main
 
 import (
+	"crypto/subtle"
 	"encoding/json"
 	"fmt"
 	"log"
 	"net/http"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
 )
 
+// isAdmin reports whether r carries the admin API key. Admin endpoints are
+// disabled when no key is configured.
+func (cfg *apiConfig) isAdmin(r *http.Request) bool {
+	if cfg.adminKey == "" {
+		return false
+	}
+	apiKey, err := auth.GetAPIKey(r.Header)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.adminKey)) == 1
+}
+
 func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		cfg.fsHits.Add(1)
@@ -16,20 +33,16 @@ func (cfg *apiConfig) middlewareMetricsInc(next http.Handler) http.Handler {
 
 func (cfg *apiConfig) reset(w http.ResponseWriter, r *http.Request) {
 	if cfg.platform != "dev" {
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusForbidden)
-		w.Write(json.RawMessage(`"error": "Access forbidden"`))
+		apierror.Write(w, apierror.Forbidden("Access forbidden"))
 		return
 	}
 
 	cfg.fsHits.Store(0)
-	result, err := cfg.queries.DeleteUsers(r.Context())
+	result, err := cfg.store.DeleteUsers(r.Context())
 
 	if err != nil {
 		log.Printf("Error deleting users: %v\n", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error deleting users from database"`))
+		apierror.Write(w, apierror.Internal("Error deleting users from database"))
 		return
 	}
 
@@ -38,8 +51,6 @@ func (cfg *apiConfig) reset(w http.ResponseWriter, r *http.Request) {
 		Message      string `json:"message"`
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
 	rows, _ := result.RowsAffected()
 	response := DeleteResponse{
 		RowsAffected: rows,
@@ -49,13 +60,12 @@ func (cfg *apiConfig) reset(w http.ResponseWriter, r *http.Request) {
 	rawRes, err := json.Marshal(response)
 	if err != nil {
 		log.Printf("POST /admin/reset: Error marshalling response\n")
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Server error"`))
+		apierror.Write(w, apierror.Internal("Server error"))
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
 	w.Write(rawRes)
 	return
 }
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/entitlements"
+	"github.com/caleb-fringer/chirpy/internal/impressions"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+// Buffered impressions are written out this often, and on shutdown.
+const IMPRESSION_FLUSH_INTERVAL = 30 * time.Second
+
+const ANALYTICS_DATE_FORMAT = "2006-01-02"
+
+type analyticsDayResponse struct {
+	Date        string `json:"date"`
+	Impressions int64  `json:"impressions"`
+	Replies     int64  `json:"replies"`
+}
+
+type chirpAnalyticsResponse struct {
+	ChirpID     uuid.UUID              `json:"chirp_id"`
+	Impressions int64                  `json:"impressions"`
+	Replies     int64                  `json:"replies"`
+	Days        []analyticsDayResponse `json:"days"`
+}
+
+type analyticsResponse struct {
+	Since  string                   `json:"since"`
+	Chirps []chirpAnalyticsResponse `json:"chirps"`
+}
+
+// recordImpressions counts chirps as shown to viewer. Authors seeing their
+// own chirps don't count.
+func (cfg *apiConfig) recordImpressions(viewer uuid.NullUUID, chirps []database.Chirp) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		if !viewer.Valid || c.UserID != viewer.UUID {
+			ids = append(ids, c.ID)
+		}
+	}
+	cfg.impressions.Record(ids...)
+}
+
+// flushImpressions writes out buffered impressions. If that fails, they are
+// kept for the next flush.
+func (cfg *apiConfig) flushImpressions(ctx context.Context) error {
+	counts := cfg.impressions.Take()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	params := database.AddChirpImpressionsParams{}
+	for k, n := range counts {
+		params.ChirpIds = append(params.ChirpIds, k.ChirpID)
+		params.Days = append(params.Days, k.Day.Format(ANALYTICS_DATE_FORMAT))
+		params.Counts = append(params.Counts, n)
+	}
+	err := cfg.store.AddChirpImpressions(ctx, params)
+	if err != nil {
+		cfg.impressions.Restore(counts)
+		return fmt.Errorf("Error saving impressions: %v", err)
+	}
+	return nil
+}
+
+// getChirpAnalytics returns daily impressions and replies for the author's
+// chirps, over the last days days including today. Only chirps and days with
+// activity are listed. How far back authors can look depends on their plan.
+func (cfg *apiConfig) getChirpAnalytics(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal := auth.PrincipalFrom(r.Context())
+
+	plan, err := cfg.userPlan(r.Context(), principal.UserID)
+	if err != nil {
+		log.Printf("GET /api/analytics/chirps: Error retrieving plan: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	// Plans never allow more than a few years; this only keeps the date
+	// arithmetic sane before the plan is checked.
+	days, err := request.QueryInt(r, "days", plan.AnalyticsDays, 1, 3650)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+	var missing entitlements.MissingError
+	if errors.As(plan.CheckAnalyticsDays(days), &missing) {
+		writeEntitlementError(w, missing)
+		return
+	}
+	if days > plan.AnalyticsDays {
+		apierror.Write(w, apierror.ValidationFailed(fmt.Sprintf("Analytics go back at most %d days.", plan.AnalyticsDays)))
+		return
+	}
+
+	since := impressions.Day(time.Now()).AddDate(0, 0, 1-days)
+	rows, err := cfg.store.GetChirpAnalytics(r.Context(), database.GetChirpAnalyticsParams{
+		UserID: principal.UserID,
+		Since:  since,
+	})
+	if err != nil {
+		log.Printf("GET /api/analytics/chirps: Error retrieving analytics: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := analyticsResponse{
+		Since:  since.Format(ANALYTICS_DATE_FORMAT),
+		Chirps: []chirpAnalyticsResponse{},
+	}
+	// Rows are ordered by chirp, then day.
+	for _, row := range rows {
+		if n := len(res.Chirps); n == 0 || res.Chirps[n-1].ChirpID != row.ChirpID {
+			res.Chirps = append(res.Chirps, chirpAnalyticsResponse{ChirpID: row.ChirpID})
+		}
+		c := &res.Chirps[len(res.Chirps)-1]
+		c.Impressions += row.Impressions
+		c.Replies += row.Replies
+		c.Days = append(c.Days, analyticsDayResponse{
+			Date:        row.Day.Format(ANALYTICS_DATE_FORMAT),
+			Impressions: row.Impressions,
+			Replies:     row.Replies,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/analytics/chirps: Error writing response: %v\n", err)
+	}
+}
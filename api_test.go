@@ -0,0 +1,1091 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/entitlements"
+	"github.com/caleb-fringer/chirpy/internal/impressions"
+	"github.com/caleb-fringer/chirpy/internal/store"
+	"github.com/caleb-fringer/chirpy/internal/stream"
+	"github.com/caleb-fringer/chirpy/internal/unfurl"
+	"github.com/caleb-fringer/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// testAPI is the API served by an httptest server.
+type testAPI struct {
+	t      *testing.T
+	cfg    *apiConfig
+	server *httptest.Server
+}
+
+// forEachBackend runs test against the API on an empty store of each kind.
+// Postgres is tested if TEST_DB_URL is set, which CI requires, and its
+// schema must be migrated already. Its users are deleted first.
+func forEachBackend(t *testing.T, test func(api *testAPI)) {
+	t.Run("memory", func(t *testing.T) {
+		s, err := store.OpenMemory(context.Background())
+		if err != nil {
+			t.Fatalf("Error opening in-memory database: %v\n", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(serveTestAPI(t, &apiConfig{store: s}))
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		cfg := &apiConfig{}
+		err := cfg.openDatabase(context.Background(), SQLITE_SCHEME+filepath.Join(t.TempDir(), "chirpy.db"))
+		if err != nil {
+			t.Fatalf("Error opening SQLite database: %v\n", err)
+		}
+		t.Cleanup(func() { cfg.store.Close() })
+		test(serveTestAPI(t, cfg))
+	})
+
+	t.Run("postgres", func(t *testing.T) {
+		dbURL := os.Getenv("TEST_DB_URL")
+		if dbURL == "" && os.Getenv("CI") != "" {
+			t.Fatal("TEST_DB_URL must be set in CI")
+		}
+		if dbURL == "" {
+			t.Skip("TEST_DB_URL is not set")
+		}
+		cfg := &apiConfig{}
+		err := cfg.openDatabase(context.Background(), dbURL)
+		if err == nil {
+			_, err = cfg.store.DeleteUsers(context.Background())
+		}
+		if err != nil {
+			t.Fatalf("Error opening Postgres database: %v\n", err)
+		}
+		t.Cleanup(func() { cfg.store.Close() })
+		test(serveTestAPI(t, cfg))
+	})
+}
+
+// serveTestAPI serves the API on cfg's store with an httptest server.
+func serveTestAPI(t *testing.T, cfg *apiConfig) *testAPI {
+	t.Helper()
+	cfg.platform = "dev"
+	cfg.secretKey = "test secret"
+	cfg.polkaKey = "test polka key"
+	cfg.adminKey = "test admin key"
+	cfg.impressions = &impressions.Counter{}
+	// Test receivers are served on loopback.
+	cfg.webhookSender = &webhook.Sender{Client: webhook.NewClient(webhook.DEFAULT_SEND_TIMEOUT, true)}
+	// Test pages are served on loopback, which the default client refuses.
+	cfg.unfurler = &unfurl.Fetcher{Client: &http.Client{}}
+	cfg.chirpStream = stream.NewBroker()
+	cfg.notificationStream = stream.NewBroker()
+	cfg.sockets = newSocketHub()
+	server := httptest.NewServer(cfg.routes())
+	t.Cleanup(server.Close)
+	// OAuth redirects go to the client, so they are returned as is.
+	server.Client().CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &testAPI{t: t, cfg: cfg, server: server}
+}
+
+// do sends a request with body encoded as JSON, authenticated by token if
+// it isn't empty, and decodes the response into res if it isn't nil.
+func (api *testAPI) do(method, path, token string, body any, res any) *http.Response {
+	api.t.Helper()
+	var reqBody bytes.Buffer
+	if body != nil {
+		if s, ok := body.(string); ok {
+			reqBody.WriteString(s)
+		} else if err := json.NewEncoder(&reqBody).Encode(body); err != nil {
+			api.t.Fatalf("Error encoding request body: %v\n", err)
+		}
+	}
+
+	req, err := http.NewRequest(method, api.server.URL+path, &reqBody)
+	if err != nil {
+		api.t.Fatalf("Error creating request: %v\n", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return api.send(req, res)
+}
+
+// postForm sends a form, as browsers and OAuth clients do, and decodes the
+// response into res if it isn't nil.
+func (api *testAPI) postForm(path string, form url.Values, res any) *http.Response {
+	api.t.Helper()
+	req, err := http.NewRequest("POST", api.server.URL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		api.t.Fatalf("Error creating request: %v\n", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return api.send(req, res)
+}
+
+// send sends req and decodes the response into res if it isn't nil.
+func (api *testAPI) send(req *http.Request, res any) *http.Response {
+	api.t.Helper()
+	resp, err := api.server.Client().Do(req)
+	if err != nil {
+		api.t.Fatalf("%s %s: %v\n", req.Method, req.URL.Path, err)
+	}
+	defer resp.Body.Close()
+	if res != nil {
+		err = json.NewDecoder(resp.Body).Decode(res)
+		if err != nil {
+			api.t.Fatalf("%s %s: Error decoding response: %v\n", req.Method, req.URL.Path, err)
+		}
+	}
+	return resp
+}
+
+func (api *testAPI) expect(resp *http.Response, status int) {
+	api.t.Helper()
+	if resp.StatusCode != status {
+		api.t.Fatalf("%s %s: Expected status %d, got %d\n", resp.Request.Method, resp.Request.URL.Path, status, resp.StatusCode)
+	}
+}
+
+type testErrorResponse struct {
+	Error struct {
+		Code    apierror.Code `json:"code"`
+		Message string        `json:"message"`
+		Details struct {
+			Fields map[string]string `json:"fields"`
+		} `json:"details"`
+	} `json:"error"`
+}
+
+// signUp creates a user and logs them in.
+func (api *testAPI) signUp(email string) loginResponse {
+	api.t.Helper()
+	creds := map[string]string{"email": email, "password": "hunter2"}
+	api.expect(api.do("POST", "/api/users", "", creds, nil), http.StatusCreated)
+
+	var session loginResponse
+	api.expect(api.do("POST", "/api/login", "", creds, &session), http.StatusOK)
+	return session
+}
+
+func TestAPIUsers(t *testing.T) {
+	forEachBackend(t, testAPIUsers)
+}
+
+func testAPIUsers(api *testAPI) {
+	t := api.t
+	session := api.signUp("joe@example.com")
+
+	var errRes testErrorResponse
+	resp := api.do("POST", "/api/login", "", map[string]string{"email": "joe@example.com", "password": "wrong"}, &errRes)
+	api.expect(resp, http.StatusUnauthorized)
+
+	resp = api.do("POST", "/api/users", "", map[string]string{"email": "", "password": ""}, &errRes)
+	api.expect(resp, http.StatusBadRequest)
+	if errRes.Error.Details.Fields["email"] == "" || errRes.Error.Details.Fields["password"] == "" {
+		t.Fatalf("Expected email and password errors, got %+v\n", errRes.Error)
+	}
+
+	resp = api.do("POST", "/api/users", "", map[string]string{"email": "joe@example.com", "password": "hunter3"}, &errRes)
+	api.expect(resp, http.StatusConflict)
+	if errRes.Error.Code != apierror.CONFLICT {
+		t.Fatalf("Expected %s, got %s\n", apierror.CONFLICT, errRes.Error.Code)
+	}
+	api.signUp("ann@example.com")
+	taken := map[string]string{"email": "ann@example.com", "password": "hunter3"}
+	api.expect(api.do("PUT", "/api/users", session.Token, taken, nil), http.StatusConflict)
+
+	update := map[string]string{"email": "joseph@example.com", "password": "hunter3"}
+	api.expect(api.do("PUT", "/api/users", session.Token, update, nil), http.StatusOK)
+	api.expect(api.do("POST", "/api/login", "", update, nil), http.StatusOK)
+
+	api.expect(api.do("PUT", "/api/users", "", update, nil), http.StatusUnauthorized)
+}
+
+func TestAPIRefreshTokens(t *testing.T) {
+	forEachBackend(t, testAPIRefreshTokens)
+}
+
+func testAPIRefreshTokens(api *testAPI) {
+	t := api.t
+	session := api.signUp("joe@example.com")
+
+	var refreshed struct {
+		Token string `json:"token"`
+	}
+	api.expect(api.do("POST", "/api/refresh", session.RefreshToken, nil, &refreshed), http.StatusOK)
+	if refreshed.Token == "" {
+		t.Fatal("Expected a new access token")
+	}
+	api.expect(api.do("GET", "/api/chirps", refreshed.Token, nil, nil), http.StatusOK)
+
+	api.expect(api.do("POST", "/api/revoke", session.RefreshToken, nil, nil), http.StatusNoContent)
+	api.expect(api.do("POST", "/api/refresh", session.RefreshToken, nil, nil), http.StatusUnauthorized)
+}
+
+func TestAPIChirps(t *testing.T) {
+	forEachBackend(t, testAPIChirps)
+}
+
+func testAPIChirps(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	ann := api.signUp("ann@example.com")
+
+	var chirp chirpResponse
+	resp := api.do("POST", "/api/chirps", joe.Token, map[string]string{"body": "What a kerfuffle"}, &chirp)
+	api.expect(resp, http.StatusCreated)
+	if chirp.Body != "What a ****" || chirp.UserID != joe.ID {
+		t.Fatalf("Unexpected chirp %+v\n", chirp)
+	}
+
+	api.do("POST", "/api/chirps", ann.Token, map[string]any{
+		"body":       "Just for Joe",
+		"visibility": "mentioned",
+		"mentions":   []string{joe.ID.String()},
+	}, nil)
+
+	var chirps []chirpResponse
+	api.expect(api.do("GET", "/api/chirps", "", nil, &chirps), http.StatusOK)
+	if len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Fatalf("Expected only the public chirp anonymously, got %+v\n", chirps)
+	}
+	api.expect(api.do("GET", "/api/chirps", joe.Token, nil, &chirps), http.StatusOK)
+	if len(chirps) != 2 {
+		t.Fatalf("Expected Joe to see both chirps, got %d\n", len(chirps))
+	}
+
+	var got chirpResponse
+	api.expect(api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil, &got), http.StatusOK)
+	if got.ID != chirp.ID {
+		t.Fatalf("Expected chirp %s, got %s\n", chirp.ID, got.ID)
+	}
+	api.expect(api.do("GET", "/api/chirps/not-an-id", "", nil, nil), http.StatusBadRequest)
+
+	// Editing published chirps is a Chirpy Red feature.
+	edit := map[string]string{"body": "Edited"}
+	api.expect(api.do("PUT", "/api/chirps/"+chirp.ID.String(), ann.Token, edit, nil), http.StatusForbidden)
+	api.expect(api.do("PUT", "/api/chirps/"+chirp.ID.String(), joe.Token, edit, nil), http.StatusPaymentRequired)
+
+	api.expect(api.do("DELETE", "/api/chirps/"+chirp.ID.String(), ann.Token, nil, nil), http.StatusForbidden)
+	api.expect(api.do("DELETE", "/api/chirps/"+chirp.ID.String(), joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil, nil), http.StatusNotFound)
+}
+
+func TestAPIChirpValidation(t *testing.T) {
+	forEachBackend(t, testAPIChirpValidation)
+}
+
+func testAPIChirpValidation(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+
+	var errRes testErrorResponse
+	resp := api.do("POST", "/api/chirps", joe.Token, `{"body": "Hi", "colour": "red"}`, &errRes)
+	api.expect(resp, http.StatusBadRequest)
+
+	resp = api.do("POST", "/api/chirps", joe.Token, map[string]string{"body": strings.Repeat("a", 1000)}, &errRes)
+	api.expect(resp, http.StatusBadRequest)
+	if errRes.Error.Code != apierror.VALIDATION_FAILED {
+		t.Fatalf("Expected %s, got %s\n", apierror.VALIDATION_FAILED, errRes.Error.Code)
+	}
+
+	// A body a paid plan allows is an entitlement error, on edit as on create.
+	long := map[string]string{"body": strings.Repeat("a", 200)}
+	resp = api.do("POST", "/api/chirps", joe.Token, long, &errRes)
+	api.expect(resp, http.StatusPaymentRequired)
+	if errRes.Error.Code != apierror.ENTITLEMENT_REQUIRED {
+		t.Fatalf("Expected %s, got %s\n", apierror.ENTITLEMENT_REQUIRED, errRes.Error.Code)
+	}
+	var draft chirpResponse
+	api.expect(api.do("POST", "/api/chirps", joe.Token, map[string]string{"body": "Hi", "status": "draft"}, &draft), http.StatusCreated)
+	api.expect(api.do("PUT", "/api/chirps/"+draft.ID.String(), joe.Token, long, nil), http.StatusPaymentRequired)
+
+	resp = api.do("POST", "/api/chirps", joe.Token, map[string]any{
+		"body": "Vote!",
+		"poll": map[string]any{"options": []string{"Yes", "No"}},
+	}, &errRes)
+	api.expect(resp, http.StatusBadRequest)
+}
+
+func TestAPIAuthentication(t *testing.T) {
+	forEachBackend(t, testAPIAuthentication)
+}
+
+func testAPIAuthentication(api *testAPI) {
+	t := api.t
+
+	var errRes testErrorResponse
+	resp := api.do("POST", "/api/chirps", "", map[string]string{"body": "Hi"}, &errRes)
+	api.expect(resp, http.StatusUnauthorized)
+	if errRes.Error.Code != apierror.UNAUTHORIZED {
+		t.Fatalf("Expected %s, got %s\n", apierror.UNAUTHORIZED, errRes.Error.Code)
+	}
+	if got := resp.Header.Get("WWW-Authenticate"); got != `Bearer realm="chirpy"` {
+		t.Fatalf("Unexpected challenge %q\n", got)
+	}
+
+	resp = api.do("POST", "/api/chirps", "not a token", map[string]string{"body": "Hi"}, nil)
+	api.expect(resp, http.StatusUnauthorized)
+	if got := resp.Header.Get("WWW-Authenticate"); !strings.Contains(got, `error="invalid_token"`) {
+		t.Fatalf("Expected an invalid_token challenge, got %q\n", got)
+	}
+
+	resp = api.do("POST", "/api/login", "", map[string]string{"email": "nobody@example.com", "password": "hunter2"}, &errRes)
+	api.expect(resp, http.StatusUnauthorized)
+}
+
+func TestAPITokens(t *testing.T) {
+	forEachBackend(t, testAPITokens)
+}
+
+func testAPITokens(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+
+	var pat personalAccessTokenResponse
+	resp := api.do("POST", "/api/tokens", joe.Token, map[string]any{"name": "CLI", "scopes": []string{"chirps:read"}}, &pat)
+	api.expect(resp, http.StatusCreated)
+	api.expect(api.do("GET", "/api/chirps/drafts", pat.Token, nil, nil), http.StatusOK)
+	api.expect(api.do("POST", "/api/chirps", pat.Token, map[string]string{"body": "Hi"}, nil), http.StatusForbidden)
+
+	var tokens []personalAccessTokenResponse
+	api.expect(api.do("GET", "/api/tokens", joe.Token, nil, &tokens), http.StatusOK)
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil || !slices.Equal(tokens[0].Scopes, []string{"chirps:read"}) {
+		t.Fatalf("Expected the used token to be listed, got %+v\n", tokens)
+	}
+	api.expect(api.do("DELETE", "/api/tokens/"+pat.ID.String(), joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", "/api/chirps/drafts", pat.Token, nil, nil), http.StatusUnauthorized)
+}
+
+func TestAPIOAuth(t *testing.T) {
+	forEachBackend(t, testAPIOAuth)
+}
+
+func testAPIOAuth(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+
+	redirectURI := "https://client.example.com/callback"
+	var client oauthClientResponse
+	resp := api.do("POST", "/api/oauth/clients", joe.Token, map[string]any{
+		"name":          "Client",
+		"redirect_uris": []string{redirectURI},
+		"scopes":        []string{"chirps:read", "chirps:write"},
+		"public":        true,
+	}, &client)
+	api.expect(resp, http.StatusCreated)
+
+	verifier := "a-code-verifier-that-is-long-enough-to-be-valid-1234567890"
+	authorize := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {client.ClientID.String()},
+		"redirect_uri":          {redirectURI},
+		"scope":                 {"chirps:read"},
+		"state":                 {"xyz"},
+		"code_challenge":        {auth.PKCEChallenge(verifier)},
+		"code_challenge_method": {auth.PKCE_METHOD_S256},
+	}
+	api.expect(api.do("GET", "/oauth/authorize?"+authorize.Encode(), "", nil, nil), http.StatusOK)
+
+	authorize.Set("email", "joe@example.com")
+	authorize.Set("password", "hunter2")
+	authorize.Set("decision", "approve")
+	resp = api.postForm("/oauth/authorize", authorize, nil)
+	api.expect(resp, http.StatusFound)
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || location.Query().Get("state") != "xyz" {
+		t.Fatalf("Unexpected redirect %q\n", resp.Header.Get("Location"))
+	}
+
+	tokenReq := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {location.Query().Get("code")},
+		"redirect_uri":  {redirectURI},
+		"client_id":     {client.ClientID.String()},
+		"code_verifier": {verifier},
+	}
+	var tokens oauthTokenResponse
+	api.expect(api.postForm("/oauth/token", tokenReq, &tokens), http.StatusOK)
+	if tokens.Scope != "chirps:read" {
+		t.Fatalf("Expected the chirps:read scope, got %q\n", tokens.Scope)
+	}
+	api.expect(api.do("GET", "/api/chirps/drafts", tokens.AccessToken, nil, nil), http.StatusOK)
+	api.expect(api.do("POST", "/api/chirps", tokens.AccessToken, map[string]string{"body": "Hi"}, nil), http.StatusForbidden)
+
+	// Codes can only be used once.
+	api.expect(api.postForm("/oauth/token", tokenReq, nil), http.StatusBadRequest)
+
+	refresh := url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {tokens.RefreshToken},
+		"client_id":     {client.ClientID.String()},
+	}
+	var refreshed oauthTokenResponse
+	api.expect(api.postForm("/oauth/token", refresh, &refreshed), http.StatusOK)
+	// Refresh tokens are rotated, so each can only be used once.
+	api.expect(api.postForm("/oauth/token", refresh, nil), http.StatusBadRequest)
+
+	refresh.Set("refresh_token", refreshed.RefreshToken)
+	api.expect(api.do("DELETE", "/api/oauth/clients/"+client.ClientID.String(), joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.postForm("/oauth/token", refresh, nil), http.StatusUnauthorized)
+}
+
+func TestAPIOutboundWebhooks(t *testing.T) {
+	forEachBackend(t, testAPIOutboundWebhooks)
+}
+
+func testAPIOutboundWebhooks(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+
+	received := make(chan string, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received <- r.Header.Get("X-Chirpy-Event")
+	}))
+	defer receiver.Close()
+
+	var endpoint webhookEndpointResponse
+	resp := api.do("POST", "/api/webhooks", joe.Token, map[string]any{
+		"url":    receiver.URL,
+		"events": []string{CHIRP_CREATED_EVENT},
+	}, &endpoint)
+	api.expect(resp, http.StatusCreated)
+	var endpoints []webhookEndpointResponse
+	api.expect(api.do("GET", "/api/webhooks", joe.Token, nil, &endpoints), http.StatusOK)
+	if len(endpoints) != 1 || !slices.Equal(endpoints[0].Events, []string{CHIRP_CREATED_EVENT}) {
+		t.Fatalf("Expected the endpoint to be listed, got %+v\n", endpoints)
+	}
+
+	chirp := api.chirp(joe.Token, map[string]any{"body": "Hi"})
+	api.expect(api.do("DELETE", "/api/chirps/"+chirp.ID.String(), joe.Token, nil, nil), http.StatusNoContent)
+	err := api.cfg.deliverOutboundWebhooks(context.Background())
+	if err != nil {
+		t.Fatalf("Error delivering webhooks: %v\n", err)
+	}
+	select {
+	case event := <-received:
+		if event != CHIRP_CREATED_EVENT {
+			t.Fatalf("Expected a %s webhook, got %q\n", CHIRP_CREATED_EVENT, event)
+		}
+	default:
+		t.Fatal("Expected a webhook to be delivered")
+	}
+	if len(received) != 0 {
+		t.Fatal("Expected only subscribed events to be delivered")
+	}
+
+	deliveries := "/api/webhooks/" + endpoint.ID.String() + "/deliveries"
+	var webhooks []outboundWebhookResponse
+	api.expect(api.do("GET", deliveries, joe.Token, nil, &webhooks), http.StatusOK)
+	if len(webhooks) != 1 || webhooks[0].Status != "delivered" || webhooks[0].Attempts != 1 {
+		t.Fatalf("Expected 1 delivered webhook, got %+v\n", webhooks)
+	}
+	var attempts []outboundWebhookAttemptResponse
+	api.expect(api.do("GET", deliveries+"/"+webhooks[0].ID.String()+"/attempts", joe.Token, nil, &attempts), http.StatusOK)
+	if len(attempts) != 1 || attempts[0].StatusCode == nil || *attempts[0].StatusCode != http.StatusOK {
+		t.Fatalf("Expected 1 successful attempt, got %+v\n", attempts)
+	}
+
+	api.expect(api.do("DELETE", "/api/webhooks/"+endpoint.ID.String(), joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", deliveries, joe.Token, nil, nil), http.StatusNotFound)
+}
+
+func TestAPIAccount(t *testing.T) {
+	forEachBackend(t, testAPIAccount)
+}
+
+func testAPIAccount(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	api.chirp(joe.Token, map[string]any{"body": "Remember me"})
+
+	api.expect(api.do("GET", "/api/users/export", joe.Token, nil, nil), http.StatusNotFound)
+	api.expect(api.do("POST", "/api/users/export", joe.Token, nil, nil), http.StatusAccepted)
+	api.expect(api.do("POST", "/api/users/export", joe.Token, nil, nil), http.StatusConflict)
+	err := api.cfg.processDataExports(context.Background())
+	if err != nil {
+		t.Fatalf("Error processing exports: %v\n", err)
+	}
+	var export dataExportResponse
+	api.expect(api.do("GET", "/api/users/export", joe.Token, nil, &export), http.StatusOK)
+	if export.Status != "complete" || export.DownloadURL == "" {
+		t.Fatalf("Expected a complete export, got %+v\n", export)
+	}
+	resp := api.do("GET", export.DownloadURL, joe.Token, nil, nil)
+	api.expect(resp, http.StatusOK)
+	if got := resp.Header.Get("Content-Type"); got != "application/zip" {
+		t.Fatalf("Expected a zip archive, got %s\n", got)
+	}
+
+	// An export whose worker died is claimed again once its lease runs out.
+	ann := api.signUp("ann@example.com")
+	api.expect(api.do("POST", "/api/users/export", ann.Token, nil, nil), http.StatusAccepted)
+	abandoned, err := api.cfg.store.ClaimDataExport(context.Background(), time.Now().UTC().Add(-DATA_EXPORT_LEASE))
+	if err != nil {
+		t.Fatalf("Error claiming export: %v\n", err)
+	}
+	api.expect(api.do("POST", "/api/users/export", ann.Token, nil, nil), http.StatusConflict)
+	reclaimed, err := api.cfg.store.ClaimDataExport(context.Background(), time.Now().UTC().Add(time.Minute))
+	if err != nil || reclaimed.ID != abandoned.ID {
+		t.Fatalf("Expected export %s to be reclaimed, got %s (%v)\n", abandoned.ID, reclaimed.ID, err)
+	}
+
+	password := map[string]string{"password": "hunter2"}
+	api.expect(api.do("DELETE", "/api/users", joe.Token, map[string]string{"password": "wrong"}, nil), http.StatusUnauthorized)
+	api.expect(api.do("DELETE", "/api/users", joe.Token, password, nil), http.StatusAccepted)
+	api.expect(api.do("DELETE", "/api/users/deletion", joe.Token, nil, nil), http.StatusNoContent)
+}
+
+// chirp posts a chirp and returns it.
+func (api *testAPI) chirp(token string, params map[string]any) chirpResponse {
+	api.t.Helper()
+	var chirp chirpResponse
+	api.expect(api.do("POST", "/api/chirps", token, params, &chirp), http.StatusCreated)
+	return chirp
+}
+
+// doAPIKey sends a request authenticated with an API key, as Polka and
+// admins do.
+func (api *testAPI) doAPIKey(method, path, key, body string, res any) *http.Response {
+	api.t.Helper()
+	req, err := http.NewRequest(method, api.server.URL+path, strings.NewReader(body))
+	if err != nil {
+		api.t.Fatalf("Error creating request: %v\n", err)
+	}
+	req.Header.Set("Authorization", "ApiKey "+key)
+	return api.send(req, res)
+}
+
+// upgrade sends Polka's upgrade event for a user.
+func (api *testAPI) upgrade(userID uuid.UUID) {
+	api.t.Helper()
+	body := fmt.Sprintf(`{"event": "user.upgraded", "data": {"user_id": %q}}`, userID)
+	api.expect(api.doAPIKey("POST", "/api/polka/webhooks", api.cfg.polkaKey, body, nil), http.StatusNoContent)
+}
+
+func TestAPISubscriptions(t *testing.T) {
+	forEachBackend(t, testAPISubscriptions)
+}
+
+func testAPISubscriptions(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+
+	var sub subscriptionResponse
+	api.expect(api.do("GET", "/api/users/subscription", joe.Token, nil, &sub), http.StatusOK)
+	if sub.IsChirpyRed {
+		t.Fatal("Expected new users to be on the free plan")
+	}
+	chirp := api.chirp(joe.Token, map[string]any{"body": "Typo"})
+	fix := map[string]string{"body": "Fixed"}
+	api.expect(api.do("PUT", "/api/chirps/"+chirp.ID.String(), joe.Token, fix, nil), http.StatusPaymentRequired)
+	long := map[string]string{"body": strings.Repeat("a", 200)}
+	api.expect(api.do("POST", "/api/chirps", joe.Token, long, nil), http.StatusPaymentRequired)
+
+	api.upgrade(joe.ID)
+	api.expect(api.do("GET", "/api/users/subscription", joe.Token, nil, &sub), http.StatusOK)
+	if !sub.IsChirpyRed || len(sub.History) != 1 {
+		t.Fatalf("Expected an upgrade, got %+v\n", sub)
+	}
+	var edited chirpResponse
+	api.expect(api.do("PUT", "/api/chirps/"+chirp.ID.String(), joe.Token, fix, &edited), http.StatusOK)
+	if edited.Body != "Fixed" {
+		t.Fatalf("Expected the chirp to be edited, got %q\n", edited.Body)
+	}
+	api.expect(api.do("POST", "/api/chirps", joe.Token, long, nil), http.StatusCreated)
+
+	// Unsigned events without an id can't be told apart from a genuine
+	// repeat, so they aren't deduplicated. Deliveries aren't anyone's, so
+	// other tests' deliveries may be listed after these.
+	api.upgrade(joe.ID)
+	var deliveries []webhookDeliveryResponse
+	api.expect(api.doAPIKey("GET", "/admin/webhooks", api.cfg.adminKey, "", &deliveries), http.StatusOK)
+	if len(deliveries) < 2 || deliveries[0].Status != WEBHOOK_PROCESSED || deliveries[1].Status != WEBHOOK_PROCESSED {
+		t.Fatalf("Expected 2 processed deliveries, got %+v\n", deliveries)
+	}
+
+	// The body of a rejected delivery isn't stored, so it can't be replayed.
+	forged := fmt.Sprintf(`{"event": "user.upgraded", "data": {"user_id": %q}}`, joe.ID)
+	api.expect(api.doAPIKey("POST", "/api/polka/webhooks", "wrong key", forged, nil), http.StatusUnauthorized)
+	api.expect(api.doAPIKey("GET", "/admin/webhooks?status="+WEBHOOK_REJECTED, api.cfg.adminKey, "", &deliveries), http.StatusOK)
+	if len(deliveries) == 0 || deliveries[0].Body != "" {
+		t.Fatalf("Expected a rejected delivery without a body, got %+v\n", deliveries)
+	}
+	unknown := fmt.Sprintf(`{"event": "user.upgraded", "data": {"user_id": %q}}`, uuid.New())
+	api.expect(api.doAPIKey("POST", "/api/polka/webhooks", api.cfg.polkaKey, unknown, nil), http.StatusNotFound)
+}
+
+func TestAPIScheduledChirps(t *testing.T) {
+	forEachBackend(t, testAPIScheduledChirps)
+}
+
+func testAPIScheduledChirps(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	api.upgrade(joe.ID)
+
+	draft := api.chirp(joe.Token, map[string]any{"body": "Draft", "status": "draft"})
+	soon := api.chirp(joe.Token, map[string]any{"body": "Soon", "publish_at": time.Now().Add(time.Second)})
+	if soon.Status != "scheduled" {
+		t.Fatalf("Expected a scheduled chirp, got %s\n", soon.Status)
+	}
+
+	var drafts []chirpResponse
+	api.expect(api.do("GET", "/api/chirps/drafts", joe.Token, nil, &drafts), http.StatusOK)
+	if len(drafts) != 2 {
+		t.Fatalf("Expected 2 unpublished chirps, got %d\n", len(drafts))
+	}
+	api.expect(api.do("GET", "/api/chirps/"+draft.ID.String(), "", nil, nil), http.StatusNotFound)
+
+	schedule := "/api/chirps/" + draft.ID.String() + "/schedule"
+	var got chirpResponse
+	api.expect(api.do("PUT", schedule, joe.Token, map[string]any{"publish_at": time.Now().Add(time.Hour)}, &got), http.StatusOK)
+	if got.Status != "scheduled" || got.PublishAt == nil {
+		t.Fatalf("Expected the draft to be scheduled, got %+v\n", got)
+	}
+	api.expect(api.do("DELETE", schedule, joe.Token, nil, &got), http.StatusOK)
+	if got.Status != "draft" {
+		t.Fatalf("Expected the chirp to be a draft again, got %s\n", got.Status)
+	}
+	api.expect(api.do("POST", "/api/chirps/"+draft.ID.String()+"/publish", joe.Token, nil, &got), http.StatusOK)
+	if got.Status != "published" {
+		t.Fatalf("Expected the draft to be published, got %s\n", got.Status)
+	}
+
+	time.Sleep(time.Until(*soon.PublishAt))
+	n, err := api.cfg.publishDueChirps(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 chirp to be published, got %d, %v\n", n, err)
+	}
+	var chirps []chirpResponse
+	api.expect(api.do("GET", "/api/chirps", "", nil, &chirps), http.StatusOK)
+	if len(chirps) != 2 {
+		t.Fatalf("Expected 2 published chirps, got %d\n", len(chirps))
+	}
+
+	// A poll must still close within its window of the new publish time.
+	poll := map[string]any{"options": []string{"Yes", "No"}, "closes_at": time.Now().Add(time.Hour)}
+	polled := api.chirp(joe.Token, map[string]any{"body": "Vote", "status": "draft", "poll": poll})
+	api.expect(api.do("PUT", "/api/chirps/"+polled.ID.String()+"/schedule", joe.Token, map[string]any{"publish_at": time.Now().Add(2 * time.Hour)}, nil), http.StatusBadRequest)
+
+	// Chirps are checked against the author's plan again as they go out.
+	long := strings.Repeat("a", entitlements.FREE.MaxChirpLength+1)
+	longDraft := api.chirp(joe.Token, map[string]any{"body": long, "status": "draft"})
+	longSoon := api.chirp(joe.Token, map[string]any{"body": long, "publish_at": time.Now().Add(time.Second)})
+	downgrade := fmt.Sprintf(`{"event": "user.downgraded", "data": {"user_id": %q}}`, joe.ID)
+	api.expect(api.doAPIKey("POST", "/api/polka/webhooks", api.cfg.polkaKey, downgrade, nil), http.StatusNoContent)
+	api.expect(api.do("POST", "/api/chirps/"+longDraft.ID.String()+"/publish", joe.Token, nil, nil), http.StatusPaymentRequired)
+	time.Sleep(time.Until(*longSoon.PublishAt))
+	n, err = api.cfg.publishDueChirps(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("Expected 1 chirp to be handled, got %d, %v\n", n, err)
+	}
+	api.expect(api.do("GET", "/api/chirps/"+longSoon.ID.String(), "", nil, nil), http.StatusNotFound)
+	api.expect(api.do("GET", "/api/chirps/drafts", joe.Token, nil, &drafts), http.StatusOK)
+	if !slices.ContainsFunc(drafts, func(c chirpResponse) bool { return c.ID == longSoon.ID && c.Status == "draft" }) {
+		t.Fatalf("Expected the scheduled chirp to be returned to drafts, got %+v\n", drafts)
+	}
+
+	// Drafts count against the rate limit when they're published.
+	ann := api.signUp("ann@example.com")
+	later := api.chirp(ann.Token, map[string]any{"body": "Later", "status": "draft"})
+	for range entitlements.ForUser(false).ChirpsPerHour {
+		api.chirp(ann.Token, map[string]any{"body": "Now"})
+	}
+	api.chirp(ann.Token, map[string]any{"body": "Even later", "status": "draft"})
+	api.expect(api.do("POST", "/api/chirps/"+later.ID.String()+"/publish", ann.Token, nil, nil), http.StatusTooManyRequests)
+}
+
+func TestAPIStream(t *testing.T) {
+	forEachBackend(t, testAPIStream)
+}
+
+func testAPIStream(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	// The event log isn't emptied between tests, so the stream resumes from
+	// its latest event.
+	lastEventID, err := api.cfg.store.GetLatestChirpEventID(context.Background())
+	if err != nil {
+		t.Fatalf("Error reading latest chirp event: %v\n", err)
+	}
+	first := api.chirp(joe.Token, map[string]any{"body": "Before"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go api.cfg.listenEventLogs(ctx)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", api.server.URL+"/api/stream", nil)
+	if err != nil {
+		t.Fatalf("Error creating request: %v\n", err)
+	}
+	// Replays what was missed, then streams live.
+	req.Header.Set("Last-Event-ID", fmt.Sprint(lastEventID))
+	resp, err := api.server.Client().Do(req)
+	if err != nil {
+		t.Fatalf("GET /api/stream: %v\n", err)
+	}
+	defer resp.Body.Close()
+	api.expect(resp, http.StatusOK)
+
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				lines <- data
+			}
+		}
+	}()
+	next := func() chirpResponse {
+		t.Helper()
+		select {
+		case data, ok := <-lines:
+			if !ok {
+				t.Fatal("Stream ended")
+			}
+			var chirp chirpResponse
+			err := json.Unmarshal([]byte(data), &chirp)
+			if err != nil {
+				t.Fatalf("Error decoding event %q: %v\n", data, err)
+			}
+			return chirp
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for an event")
+		}
+		return chirpResponse{}
+	}
+
+	if got := next(); got.ID != first.ID {
+		t.Fatalf("Expected chirp %s to be replayed, got %+v\n", first.ID, got)
+	}
+	second := api.chirp(joe.Token, map[string]any{"body": "After"})
+	if got := next(); got.ID != second.ID {
+		t.Fatalf("Expected chirp %s to be streamed, got %+v\n", second.ID, got)
+	}
+}
+
+func TestAPIThreads(t *testing.T) {
+	forEachBackend(t, testAPIThreads)
+}
+
+func testAPIThreads(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	ann := api.signUp("ann@example.com")
+
+	root := api.chirp(joe.Token, map[string]any{"body": "Who likes #Go?"})
+	reply := api.chirp(ann.Token, map[string]any{"body": "Me", "reply_to_id": root.ID})
+	if reply.ReplyToID == nil || *reply.ReplyToID != root.ID || reply.ThreadID != root.ID {
+		t.Fatalf("Expected a reply in %s's thread, got %+v\n", root.ID, reply)
+	}
+	api.chirp(joe.Token, map[string]any{"body": "Same", "reply_to_id": reply.ID})
+
+	var thread []chirpResponse
+	api.expect(api.do("GET", "/api/chirps/"+reply.ID.String()+"/thread", "", nil, &thread), http.StatusOK)
+	if len(thread) != 3 || thread[0].ID != root.ID {
+		t.Fatalf("Expected the whole thread from the root, got %+v\n", thread)
+	}
+
+	quote := api.chirp(ann.Token, map[string]any{"body": "Look", "quote_id": root.ID})
+	var got chirpResponse
+	api.expect(api.do("GET", "/api/chirps/"+quote.ID.String(), "", nil, &got), http.StatusOK)
+	if got.Quote == nil || got.Quote.ID != root.ID {
+		t.Fatalf("Expected chirp %s to be quoted, got %+v\n", root.ID, got.Quote)
+	}
+
+	api.expect(api.do("POST", "/api/users/"+joe.ID.String()+"/follow", ann.Token, nil, nil), http.StatusNoContent)
+	var notifications []notificationResponse
+	api.expect(api.do("GET", "/api/notifications", joe.Token, nil, &notifications), http.StatusOK)
+	if len(notifications) != 2 || notifications[0].Type != "follow" || notifications[1].Type != "reply" {
+		t.Fatalf("Expected a follow and a reply notification, got %+v\n", notifications)
+	}
+	for _, n := range notifications {
+		if n.ActorID != ann.ID {
+			t.Fatalf("Expected notifications from %s, got %+v\n", ann.ID, n)
+		}
+	}
+	api.expect(api.do("GET", "/api/notifications?before=x", joe.Token, nil, nil), http.StatusBadRequest)
+}
+
+func TestAPIDirectMessages(t *testing.T) {
+	forEachBackend(t, testAPIDirectMessages)
+}
+
+func testAPIDirectMessages(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	ann := api.signUp("ann@example.com")
+
+	var conversation conversationResponse
+	resp := api.do("POST", "/api/conversations", joe.Token, map[string]any{
+		"member_ids": []uuid.UUID{ann.ID},
+		"body":       "Hi Ann",
+	}, &conversation)
+	api.expect(resp, http.StatusCreated)
+	again := api.do("POST", "/api/conversations", ann.Token, map[string]any{"member_ids": []uuid.UUID{joe.ID}}, nil)
+	api.expect(again, http.StatusOK)
+
+	messages := "/api/conversations/" + conversation.ID.String() + "/messages"
+	api.expect(api.do("POST", messages, ann.Token, map[string]string{"body": "Hi Joe"}, nil), http.StatusCreated)
+
+	var conversations []conversationResponse
+	api.expect(api.do("GET", "/api/conversations", joe.Token, nil, &conversations), http.StatusOK)
+	if len(conversations) != 1 || conversations[0].UnreadCount != 1 {
+		t.Fatalf("Expected 1 conversation with 1 unread message, got %+v\n", conversations)
+	}
+	api.expect(api.do("POST", "/api/conversations/"+conversation.ID.String()+"/read", joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", "/api/conversations", joe.Token, nil, &conversations), http.StatusOK)
+	if conversations[0].UnreadCount != 0 || conversations[0].LastReadAt == nil {
+		t.Fatalf("Expected the conversation to be read, got %+v\n", conversations[0])
+	}
+
+	var got []database.DirectMessage
+	api.expect(api.do("GET", messages, joe.Token, nil, &got), http.StatusOK)
+	if len(got) != 2 || got[0].Body != "Hi Joe" {
+		t.Fatalf("Expected 2 messages, newest first, got %+v\n", got)
+	}
+
+	bob := api.signUp("bob@example.com")
+	api.expect(api.do("GET", messages, bob.Token, nil, nil), http.StatusNotFound)
+	api.expect(api.do("PUT", "/api/users/settings", bob.Token, map[string]string{"dm_policy": "nobody"}, nil), http.StatusOK)
+	nobody := api.do("POST", "/api/conversations", joe.Token, map[string]any{"member_ids": []uuid.UUID{bob.ID}}, nil)
+	api.expect(nobody, http.StatusForbidden)
+}
+
+func TestAPIRelationships(t *testing.T) {
+	forEachBackend(t, testAPIRelationships)
+}
+
+func testAPIRelationships(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	ann := api.signUp("ann@example.com")
+	bob := api.signUp("bob@example.com")
+
+	api.expect(api.do("PUT", "/api/users/settings", ann.Token, map[string]bool{"protected": true}, nil), http.StatusOK)
+	var settings userSettings
+	api.expect(api.do("GET", "/api/users/settings", ann.Token, nil, &settings), http.StatusOK)
+	if !settings.Protected {
+		t.Fatalf("Expected Ann's account to be protected, got %+v\n", settings)
+	}
+
+	annFollow := "/api/users/" + ann.ID.String() + "/follow"
+	api.expect(api.do("POST", annFollow, joe.Token, nil, nil), http.StatusAccepted)
+	api.expect(api.do("POST", annFollow, bob.Token, nil, nil), http.StatusAccepted)
+	var requests []relationshipResponse
+	api.expect(api.do("GET", "/api/follow-requests", ann.Token, nil, &requests), http.StatusOK)
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 follow requests, got %+v\n", requests)
+	}
+	api.expect(api.do("POST", "/api/follow-requests/"+joe.ID.String(), ann.Token, nil, nil), http.StatusNoContent)
+
+	followersOnly := api.chirp(ann.Token, map[string]any{"body": "Friends only", "visibility": "followers"})
+	api.expect(api.do("GET", "/api/chirps/"+followersOnly.ID.String(), joe.Token, nil, nil), http.StatusOK)
+	api.expect(api.do("GET", "/api/chirps/"+followersOnly.ID.String(), bob.Token, nil, nil), http.StatusNotFound)
+
+	// Unprotecting the account accepts everyone still waiting.
+	api.expect(api.do("PUT", "/api/users/settings", ann.Token, map[string]bool{"protected": false}, nil), http.StatusOK)
+	api.expect(api.do("GET", "/api/follow-requests", ann.Token, nil, &requests), http.StatusOK)
+	if len(requests) != 0 {
+		t.Fatalf("Expected no follow requests left, got %+v\n", requests)
+	}
+	api.expect(api.do("GET", "/api/chirps/"+followersOnly.ID.String(), bob.Token, nil, nil), http.StatusOK)
+	api.expect(api.do("DELETE", "/api/users/"+ann.ID.String()+"/follow", bob.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", "/api/chirps/"+followersOnly.ID.String(), bob.Token, nil, nil), http.StatusNotFound)
+
+	public := api.chirp(ann.Token, map[string]any{"body": "Hello"})
+	api.expect(api.do("POST", "/api/users/"+ann.ID.String()+"/mute", joe.Token, nil, nil), http.StatusNoContent)
+	var mutes []relationshipResponse
+	api.expect(api.do("GET", "/api/users/mutes", joe.Token, nil, &mutes), http.StatusOK)
+	if len(mutes) != 1 || mutes[0].UserID != ann.ID {
+		t.Fatalf("Expected Ann to be muted, got %+v\n", mutes)
+	}
+	var chirps []chirpResponse
+	api.expect(api.do("GET", "/api/chirps", joe.Token, nil, &chirps), http.StatusOK)
+	if len(chirps) != 0 {
+		t.Fatalf("Expected muted chirps to be hidden, got %+v\n", chirps)
+	}
+	api.expect(api.do("DELETE", "/api/users/"+ann.ID.String()+"/mute", joe.Token, nil, nil), http.StatusNoContent)
+
+	api.expect(api.do("POST", "/api/users/"+joe.ID.String()+"/block", ann.Token, nil, nil), http.StatusNoContent)
+	var blocks []relationshipResponse
+	api.expect(api.do("GET", "/api/users/blocks", ann.Token, nil, &blocks), http.StatusOK)
+	if len(blocks) != 1 || blocks[0].UserID != joe.ID {
+		t.Fatalf("Expected Joe to be blocked, got %+v\n", blocks)
+	}
+	api.expect(api.do("GET", "/api/chirps/"+public.ID.String(), joe.Token, nil, nil), http.StatusNotFound)
+	api.expect(api.do("POST", annFollow, joe.Token, nil, nil), http.StatusForbidden)
+	api.expect(api.do("DELETE", "/api/users/"+joe.ID.String()+"/block", ann.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", "/api/chirps/"+public.ID.String(), joe.Token, nil, nil), http.StatusOK)
+}
+
+func TestAPIPollsAndPins(t *testing.T) {
+	forEachBackend(t, testAPIPollsAndPins)
+}
+
+func testAPIPollsAndPins(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	ann := api.signUp("ann@example.com")
+
+	chirp := api.chirp(joe.Token, map[string]any{
+		"body": "Tabs or spaces?",
+		"poll": map[string]any{"options": []string{"Tabs", "Spaces"}, "closes_at": time.Now().Add(time.Hour)},
+	})
+	if chirp.Poll == nil || len(chirp.Poll.Options) != 2 {
+		t.Fatalf("Expected a poll with 2 options, got %+v\n", chirp.Poll)
+	}
+
+	votes := "/api/chirps/" + chirp.ID.String() + "/poll/votes"
+	var poll pollResponse
+	api.expect(api.do("POST", votes, ann.Token, map[string]int{"option": 1}, &poll), http.StatusOK)
+	if poll.Voted == nil || *poll.Voted != 1 || poll.TotalVotes == nil || *poll.TotalVotes != 1 {
+		t.Fatalf("Expected Ann's vote to be counted, got %+v\n", poll)
+	}
+	api.expect(api.do("POST", votes, ann.Token, map[string]int{"option": 0}, nil), http.StatusConflict)
+	api.expect(api.do("POST", votes, joe.Token, map[string]int{"option": 2}, nil), http.StatusBadRequest)
+
+	other := api.chirp(joe.Token, map[string]any{"body": "Pin me"})
+	api.expect(api.do("POST", "/api/chirps/"+other.ID.String()+"/pin", joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("POST", "/api/chirps/"+other.ID.String()+"/pin", ann.Token, nil, nil), http.StatusNotFound)
+
+	byJoe := "/api/chirps?author_id=" + joe.ID.String()
+	var chirps []chirpResponse
+	api.expect(api.do("GET", byJoe, "", nil, &chirps), http.StatusOK)
+	if len(chirps) != 2 || chirps[0].ID != other.ID || !chirps[0].Pinned {
+		t.Fatalf("Expected the pinned chirp first, got %+v\n", chirps)
+	}
+	api.expect(api.do("DELETE", "/api/chirps/"+other.ID.String()+"/pin", joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", byJoe, "", nil, &chirps), http.StatusOK)
+	if chirps[0].ID != chirp.ID || chirps[1].Pinned {
+		t.Fatalf("Expected the chirps in order and unpinned, got %+v\n", chirps)
+	}
+}
+
+func TestAPIBookmarks(t *testing.T) {
+	forEachBackend(t, testAPIBookmarks)
+}
+
+func testAPIBookmarks(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	first := api.chirp(joe.Token, map[string]any{"body": "First"})
+	second := api.chirp(joe.Token, map[string]any{"body": "Second"})
+
+	for _, c := range []chirpResponse{first, second} {
+		// Bookmarks are paged by time, so they mustn't share one.
+		time.Sleep(2 * time.Millisecond)
+		api.expect(api.do("POST", "/api/chirps/"+c.ID.String()+"/bookmark", joe.Token, nil, nil), http.StatusNoContent)
+	}
+	var bookmarks []bookmarkResponse
+	api.expect(api.do("GET", "/api/bookmarks", joe.Token, nil, &bookmarks), http.StatusOK)
+	if len(bookmarks) != 2 || bookmarks[0].Chirp.ID != second.ID {
+		t.Fatalf("Expected 2 bookmarks, newest first, got %+v\n", bookmarks)
+	}
+
+	api.expect(api.do("GET", "/api/bookmarks?limit=1", joe.Token, nil, &bookmarks), http.StatusOK)
+	before := url.QueryEscape(bookmarks[0].BookmarkedAt.Format(time.RFC3339Nano))
+	api.expect(api.do("GET", "/api/bookmarks?before="+before, joe.Token, nil, &bookmarks), http.StatusOK)
+	if len(bookmarks) != 1 || bookmarks[0].Chirp.ID != first.ID {
+		t.Fatalf("Expected the next page to hold the first chirp, got %+v\n", bookmarks)
+	}
+
+	api.expect(api.do("DELETE", "/api/chirps/"+first.ID.String()+"/bookmark", joe.Token, nil, nil), http.StatusNoContent)
+	api.expect(api.do("GET", "/api/bookmarks", joe.Token, nil, &bookmarks), http.StatusOK)
+	if len(bookmarks) != 1 {
+		t.Fatalf("Expected 1 bookmark left, got %d\n", len(bookmarks))
+	}
+}
+
+func TestAPIAnalytics(t *testing.T) {
+	forEachBackend(t, testAPIAnalytics)
+}
+
+func testAPIAnalytics(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+	ann := api.signUp("ann@example.com")
+
+	chirp := api.chirp(joe.Token, map[string]any{"body": "Count me"})
+	api.chirp(ann.Token, map[string]any{"body": "Reply", "reply_to_id": chirp.ID})
+	api.expect(api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil, nil), http.StatusOK)
+	api.expect(api.do("GET", "/api/chirps/"+chirp.ID.String(), ann.Token, nil, nil), http.StatusOK)
+	// The author's own views don't count.
+	api.expect(api.do("GET", "/api/chirps/"+chirp.ID.String(), joe.Token, nil, nil), http.StatusOK)
+	err := api.cfg.flushImpressions(context.Background())
+	if err != nil {
+		t.Fatalf("Error flushing impressions: %v\n", err)
+	}
+
+	var res analyticsResponse
+	api.expect(api.do("GET", "/api/analytics/chirps", joe.Token, nil, &res), http.StatusOK)
+	if len(res.Chirps) != 1 || res.Chirps[0].Impressions != 2 || res.Chirps[0].Replies != 1 || len(res.Chirps[0].Days) != 1 {
+		t.Fatalf("Expected 2 impressions and 1 reply today, got %+v\n", res.Chirps)
+	}
+	api.expect(api.do("GET", "/api/analytics/chirps?days=30", joe.Token, nil, nil), http.StatusPaymentRequired)
+}
+
+func TestAPIWorkers(t *testing.T) {
+	forEachBackend(t, testAPIWorkers)
+}
+
+// testAPIWorkers runs each background job once over a store with some data
+// in it.
+func testAPIWorkers(api *testAPI) {
+	t := api.t
+	joe := api.signUp("joe@example.com")
+
+	page := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		fmt.Fprint(w, `<html><head><meta property="og:title" content="A page"></head></html>`)
+	}))
+	defer page.Close()
+	chirp := api.chirp(joe.Token, map[string]any{"body": "Read " + page.URL + "/post"})
+
+	api.upgrade(joe.ID)
+	api.expect(api.do("DELETE", "/api/users", joe.Token, map[string]string{"password": "hunter2"}, nil), http.StatusAccepted)
+	api.expect(api.do("POST", "/api/users/export", joe.Token, nil, nil), http.StatusAccepted)
+
+	jobs := map[string]func(context.Context) error{
+		"data exports":         api.cfg.processDataExports,
+		"account purge":        api.cfg.purgeDeletedUsers,
+		"subscription expiry":  api.cfg.expireSubscriptions,
+		"outbound webhooks":    api.cfg.deliverOutboundWebhooks,
+		"scheduled chirps":     api.cfg.publishScheduledChirps,
+		"chirp event cleanup":  api.cfg.deleteOldChirpEvents,
+		"notification cleanup": api.cfg.deleteOldNotifications,
+		"link previews":        api.cfg.fetchLinkPreviews,
+		"impressions":          api.cfg.flushImpressions,
+	}
+	for name, job := range jobs {
+		err := job(context.Background())
+		if err != nil {
+			t.Fatalf("Error running %s: %v\n", name, err)
+		}
+	}
+
+	var got chirpResponse
+	api.expect(api.do("GET", "/api/chirps/"+chirp.ID.String(), "", nil, &got), http.StatusOK)
+	if got.LinkPreview == nil || got.LinkPreview.Title != "A page" {
+		t.Fatalf("Expected a preview of %s, got %+v\n", page.URL, got.LinkPreview)
+	}
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+)
+
+const AUTH_REALM = "chirpy"
+
+// authPolicy says which access tokens a route accepts.
+type authPolicy struct {
+	// The scope scoped tokens must allow. Empty if the route doesn't need
+	// one.
+	scope string
+	// Only first-party session tokens are accepted, so personal access
+	// tokens and OAuth clients can't be used to manage other tokens or the
+	// account itself.
+	sessionOnly bool
+}
+
+// authenticate resolves a bearer token to the user it belongs to. Both JWT
+// access tokens and personal access tokens are accepted.
+func (cfg *apiConfig) authenticate(ctx context.Context, token string) (*auth.Principal, error) {
+	if !auth.IsPersonalAccessToken(token) {
+		claims, err := auth.ValidateJWT(token, cfg.secretKey)
+		if err != nil {
+			return nil, err
+		}
+		return auth.PrincipalFromClaims(claims), nil
+	}
+
+	pat, err := cfg.store.GetPersonalAccessTokenByHash(ctx, auth.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("Unknown personal access token: %v", err)
+	}
+	if pat.RevokedAt.Valid {
+		return nil, errors.New("Personal access token has been revoked")
+	}
+	if pat.ExpiresAt.Valid && time.Now().UTC().After(pat.ExpiresAt.Time) {
+		return nil, errors.New("Personal access token has expired")
+	}
+
+	err = cfg.store.TouchPersonalAccessToken(ctx, pat.ID)
+	if err != nil {
+		log.Printf("Error recording use of personal access token %s: %v\n", pat.ID, err)
+	}
+
+	return &auth.Principal{
+		UserID: pat.UserID,
+		Kind:   auth.TOKEN_PERSONAL,
+		Scopes: pat.Scopes,
+	}, nil
+}
+
+// requireAuth only lets requests with a token allowing scope through to
+// next, which can read the user from auth.PrincipalFrom.
+func (cfg *apiConfig) requireAuth(scope string, next http.HandlerFunc) http.Handler {
+	return cfg.authMiddleware(authPolicy{scope: scope}, false, next)
+}
+
+// requireSession only lets requests with a first-party session token through
+// to next.
+func (cfg *apiConfig) requireSession(next http.HandlerFunc) http.Handler {
+	return cfg.authMiddleware(authPolicy{sessionOnly: true}, false, next)
+}
+
+// optionalAuth lets anonymous requests through to next as well, without a
+// principal. Requests that do send a token must send a valid one.
+func (cfg *apiConfig) optionalAuth(scope string, next http.HandlerFunc) http.Handler {
+	return cfg.authMiddleware(authPolicy{scope: scope}, true, next)
+}
+
+func (cfg *apiConfig) authMiddleware(policy authPolicy, optional bool, next http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if optional && r.Header.Get("Authorization") == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			writeAuthError(w, apierror.Unauthorized("Missing/malformed access token."), "")
+			return
+		}
+		principal, err := cfg.authenticate(r.Context(), token)
+		if err != nil {
+			writeAuthError(w, apierror.Unauthorized("Invalid access token."), "invalid_token")
+			return
+		}
+
+		if policy.sessionOnly && principal.Kind != auth.TOKEN_SESSION {
+			writeAuthError(w, apierror.InsufficientScope("This request needs a token from signing in to Chirpy."), "insufficient_scope")
+			return
+		}
+		if policy.scope != "" && !principal.Allows(policy.scope) {
+			err := apierror.InsufficientScope(fmt.Sprintf("Token does not grant the %s scope.", policy.scope)).
+				WithDetails(map[string]string{"scope": policy.scope})
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm=%q, error="insufficient_scope", scope=%q`, AUTH_REALM, policy.scope))
+			apierror.Write(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+	})
+}
+
+// writeAuthError writes err with a WWW-Authenticate challenge as described
+// in RFC 6750. code is the challenge's error code, if any.
+func writeAuthError(w http.ResponseWriter, err *apierror.Error, code string) {
+	challenge := fmt.Sprintf("Bearer realm=%q", AUTH_REALM)
+	if code != "" {
+		challenge += fmt.Sprintf(", error=%q, error_description=%q", code, err.Message)
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	apierror.Write(w, err)
+}
+
+// withQueryToken lets clients that can't set headers, like browsers opening
+// a WebSocket, pass their access token as the access_token query parameter.
+func withQueryToken(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token := r.URL.Query().Get("access_token"); token != "" && r.Header.Get("Authorization") == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
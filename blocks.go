@@ -0,0 +1,259 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+type relationshipResponse struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// chirpViewer returns the user reading chirps. Chirps can be read
+// anonymously, in which case the result is not valid.
+func chirpViewer(r *http.Request) uuid.NullUUID {
+	principal := auth.PrincipalFrom(r.Context())
+	if principal == nil {
+		return uuid.NullUUID{}
+	}
+	return uuid.NullUUID{UUID: principal.UserID, Valid: true}
+}
+
+// hiddenAuthors returns the users whose chirps are hidden from viewer
+// because of blocks and mutes. Nothing is hidden from anonymous viewers.
+func (cfg *apiConfig) hiddenAuthors(ctx context.Context, viewer uuid.NullUUID) (map[uuid.UUID]struct{}, error) {
+	hidden := map[uuid.UUID]struct{}{}
+	if !viewer.Valid {
+		return hidden, nil
+	}
+
+	ids, err := cfg.store.GetHiddenUserIDs(ctx, viewer.UUID)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		hidden[id] = struct{}{}
+	}
+	return hidden, nil
+}
+
+func filterHiddenChirps(chirps []database.Chirp, hidden map[uuid.UUID]struct{}) []database.Chirp {
+	visible := make([]database.Chirp, 0, len(chirps))
+	for _, c := range chirps {
+		if _, ok := hidden[c.UserID]; !ok {
+			visible = append(visible, c)
+		}
+	}
+	return visible
+}
+
+// relationshipTarget returns the user making the request and the user named
+// in the request path, who must exist and be someone else. Otherwise it
+// writes an error response and returns false.
+func (cfg *apiConfig) relationshipTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	targetID, err := request.PathUUID(r, "userID")
+	if err != nil {
+		apierror.Write(w, err)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if targetID == userID {
+		apierror.Write(w, apierror.BadRequest("You can't do that to yourself."))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	_, err = cfg.store.GetUserByID(r.Context(), targetID)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.NotFound("User not found"))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+	if err != nil {
+		log.Printf("%s %s: Error retrieving user: %v\n", r.Method, r.URL.Path, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return userID, targetID, true
+}
+
+// blockUser blocks a user. Both users stop following each other, and pending
+// follow requests between them are dropped.
+func (cfg *apiConfig) blockUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, blockedID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("POST /api/users/%s/block: Error starting transaction: %v\n", blockedID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err == nil {
+		err = tx.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+			FollowerID: userID,
+			FolloweeID: blockedID,
+		})
+	}
+	if err == nil {
+		err = tx.DeleteFollowRequestsBetween(r.Context(), database.DeleteFollowRequestsBetweenParams{
+			RequesterID: userID,
+			TargetID:    blockedID,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("POST /api/users/%s/block: Error blocking user: %v\n", blockedID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unblockUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, blockedID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.store.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: blockedID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/users/%s/block: Error unblocking user: %v\n", blockedID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("You haven't blocked this user."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getBlocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	blocks, err := cfg.store.GetBlocksByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/users/blocks: Error retrieving blocks: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newBlocksResponse(blocks))
+	if err != nil {
+		log.Printf("GET /api/users/blocks: Error writing response: %v\n", err)
+	}
+}
+
+func newBlocksResponse(blocks []database.Block) []relationshipResponse {
+	res := make([]relationshipResponse, 0, len(blocks))
+	for _, b := range blocks {
+		res = append(res, relationshipResponse{UserID: b.BlockedID, CreatedAt: b.CreatedAt})
+	}
+	return res
+}
+
+// muteUser hides a user's chirps from the muter, without them knowing.
+func (cfg *apiConfig) muteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, mutedID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.store.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		log.Printf("POST /api/users/%s/mute: Error muting user: %v\n", mutedID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unmuteUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID, mutedID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.store.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: mutedID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/users/%s/mute: Error unmuting user: %v\n", mutedID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("You haven't muted this user."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) getMutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	mutes, err := cfg.store.GetMutesByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/users/mutes: Error retrieving mutes: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newMutesResponse(mutes))
+	if err != nil {
+		log.Printf("GET /api/users/mutes: Error writing response: %v\n", err)
+	}
+}
+
+func newMutesResponse(mutes []database.Mute) []relationshipResponse {
+	res := make([]relationshipResponse, 0, len(mutes))
+	for _, m := range mutes {
+		res = append(res, relationshipResponse{UserID: m.MutedID, CreatedAt: m.CreatedAt})
+	}
+	return res
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+type bookmarkResponse struct {
+	BookmarkedAt time.Time     `json:"bookmarked_at"`
+	Chirp        chirpResponse `json:"chirp"`
+}
+
+// readableChirp returns a published chirp if userID can see it and neither
+// of them blocks the other. Otherwise it returns sql.ErrNoRows.
+func (cfg *apiConfig) readableChirp(ctx context.Context, chirpID, userID uuid.UUID) (database.Chirp, error) {
+	chirp, err := cfg.store.GetVisibleChirpById(ctx, database.GetVisibleChirpByIdParams{
+		ID:       chirpID,
+		ViewerID: uuid.NullUUID{UUID: userID, Valid: true},
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	blocked, err := cfg.store.IsBlockedEither(ctx, database.IsBlockedEitherParams{
+		BlockerID: chirp.UserID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	if blocked {
+		return database.Chirp{}, sql.ErrNoRows
+	}
+	return chirp, nil
+}
+
+// bookmarkChirp privately bookmarks a chirp for the user.
+func (cfg *apiConfig) bookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	chirpID, err := request.PathUUID(r, "chirpID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	_, err = cfg.readableChirp(r.Context(), chirpID, userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.NotFound("Chirp not found"))
+		return
+	}
+	if err == nil {
+		err = cfg.store.BookmarkChirp(r.Context(), database.BookmarkChirpParams{
+			UserID:  userID,
+			ChirpID: chirpID,
+		})
+	}
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/bookmark: Error bookmarking chirp: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unbookmarkChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	chirpID, err := request.PathUUID(r, "chirpID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	rows, err := cfg.store.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/chirps/%s/bookmark: Error deleting bookmark: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("You haven't bookmarked this chirp."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getBookmarks lists the user's bookmarked chirps, newest bookmark first.
+// Pages are fetched by passing the last bookmarked_at seen as before.
+// Chirps the user can no longer see are left out.
+func (cfg *apiConfig) getBookmarks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	before, limit, ok := timePage(w, r)
+	if !ok {
+		return
+	}
+
+	viewer := uuid.NullUUID{UUID: userID, Valid: true}
+	hidden, err := cfg.hiddenAuthors(r.Context(), viewer)
+	if err != nil {
+		log.Printf("GET /api/bookmarks: Error retrieving blocks and mutes: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	bookmarks, err := cfg.store.GetBookmarkedChirps(r.Context(), database.GetBookmarkedChirpsParams{
+		UserID: userID,
+		Before: before,
+		Limit:  limit,
+	})
+	if err != nil {
+		log.Printf("GET /api/bookmarks: Error retrieving bookmarks: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	chirps := make([]database.Chirp, 0, len(bookmarks))
+	bookmarkedAt := make([]time.Time, 0, len(bookmarks))
+	for _, b := range bookmarks {
+		if _, ok := hidden[b.Chirp.UserID]; !ok {
+			chirps = append(chirps, b.Chirp)
+			bookmarkedAt = append(bookmarkedAt, b.BookmarkedAt)
+		}
+	}
+
+	rendered, err := cfg.renderChirps(r.Context(), viewer, hidden, chirps)
+	if err != nil {
+		log.Printf("GET /api/bookmarks: Error rendering chirps: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	cfg.recordImpressions(viewer, chirps)
+
+	res := make([]bookmarkResponse, 0, len(rendered))
+	for i, c := range rendered {
+		res = append(res, bookmarkResponse{BookmarkedAt: bookmarkedAt[i], Chirp: c})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/bookmarks: Error writing response: %v\n", err)
+	}
+}
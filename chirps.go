@@ -1,74 +1,370 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"sort"
+	"time"
 
+	"github.com/caleb-fringer/chirpy/internal/apierror"
 	"github.com/caleb-fringer/chirpy/internal/auth"
 	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/entitlements"
+	"github.com/caleb-fringer/chirpy/internal/request"
 	"github.com/google/uuid"
 )
 
+// Chirp statuses.
+const (
+	CHIRP_DRAFT     = "draft"
+	CHIRP_SCHEDULED = "scheduled"
+	CHIRP_PUBLISHED = "published"
+)
+
 type createChirpReqParams struct {
 	Body string `json:"body"`
+	// Status defaults to scheduled if PublishAt is set, and published
+	// otherwise.
+	Status    string     `json:"status,omitempty"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	ReplyToID *uuid.UUID `json:"reply_to_id,omitempty"`
+	// Visibility defaults to public.
+	Visibility string               `json:"visibility,omitempty"`
+	Mentions   []uuid.UUID          `json:"mentions,omitempty"`
+	QuoteID    *uuid.UUID           `json:"quote_id,omitempty"`
+	Poll       *createPollReqParams `json:"poll,omitempty"`
+}
+
+func (p *createChirpReqParams) Validate() request.FieldErrors {
+	errs := request.FieldErrors{}
+	switch p.Status {
+	case "", CHIRP_DRAFT, CHIRP_SCHEDULED, CHIRP_PUBLISHED:
+	default:
+		errs.Add("status", "must be draft, scheduled or published")
+	}
+	switch p.Visibility {
+	case "", VISIBILITY_PUBLIC, VISIBILITY_FOLLOWERS, VISIBILITY_MENTIONED:
+	default:
+		errs.Add("visibility", "must be public, followers or mentioned")
+	}
+	return errs
+}
+
+type chirpResponse struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	Body      string     `json:"body"`
+	UserID    uuid.UUID  `json:"user_id"`
+	Status    string     `json:"status"`
+	PublishAt *time.Time `json:"publish_at,omitempty"`
+	// Unset for chirps that aren't replies, or whose parent was deleted.
+	ReplyToID  *uuid.UUID  `json:"reply_to_id,omitempty"`
+	ThreadID   uuid.UUID   `json:"thread_id"`
+	Visibility string      `json:"visibility"`
+	Mentions   []uuid.UUID `json:"mentions"`
+	// The quoted chirp is only embedded if the viewer can see it.
+	QuoteID     *uuid.UUID           `json:"quote_id,omitempty"`
+	Quote       *chirpResponse       `json:"quote,omitempty"`
+	LinkPreview *linkPreviewResponse `json:"link_preview,omitempty"`
+	Poll        *pollResponse        `json:"poll,omitempty"`
+	// Only set in listings of a single author's chirps.
+	Pinned bool `json:"pinned,omitempty"`
+}
+
+func newChirpResponse(c database.Chirp) chirpResponse {
+	res := chirpResponse{
+		ID:         c.ID,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Body:       c.Body,
+		UserID:     c.UserID,
+		Status:     c.Status,
+		ThreadID:   chirpThreadID(c),
+		Visibility: c.Visibility,
+		Mentions:   c.Mentions,
+	}
+	if res.Mentions == nil {
+		res.Mentions = []uuid.UUID{}
+	}
+	if c.Status == CHIRP_SCHEDULED {
+		res.PublishAt = nullTimePtr(c.PublishAt)
+	}
+	if c.ReplyToID.Valid {
+		res.ReplyToID = &c.ReplyToID.UUID
+	}
+	if c.QuoteID.Valid {
+		res.QuoteID = &c.QuoteID.UUID
+	}
+	return res
+}
+
+// chirpThreadID returns the id of the chirp that started c's thread, which
+// is c itself if it isn't a reply.
+func chirpThreadID(c database.Chirp) uuid.UUID {
+	if c.ThreadID.Valid {
+		return c.ThreadID.UUID
+	}
+	return c.ID
+}
+
+func newChirpsResponse(chirps []database.Chirp) []chirpResponse {
+	res := make([]chirpResponse, 0, len(chirps))
+	for _, c := range chirps {
+		res = append(res, newChirpResponse(c))
+	}
+	return res
+}
+
+// renderChirps builds the responses for chirps shown to viewer, embedding
+// the chirps they quote, their link previews and their polls. Quoted chirps
+// that are hidden from the viewer are left out.
+func (cfg *apiConfig) renderChirps(ctx context.Context, viewer uuid.NullUUID, hidden map[uuid.UUID]struct{}, chirps []database.Chirp) ([]chirpResponse, error) {
+	quoteIDs := []uuid.UUID{}
+	for _, c := range chirps {
+		if c.QuoteID.Valid {
+			quoteIDs = append(quoteIDs, c.QuoteID.UUID)
+		}
+	}
+
+	quoted := map[uuid.UUID]database.Chirp{}
+	linked := chirps
+	if len(quoteIDs) > 0 {
+		quotes, err := cfg.store.GetVisibleChirpsByIds(ctx, database.GetVisibleChirpsByIdsParams{
+			Ids:      quoteIDs,
+			ViewerID: viewer,
+		})
+		if err != nil {
+			return nil, err
+		}
+		quotes = filterHiddenChirps(quotes, hidden)
+		for _, q := range quotes {
+			quoted[q.ID] = q
+		}
+		linked = append(quotes, chirps...)
+	}
+
+	previews, err := cfg.linkPreviews(ctx, linked)
+	if err != nil {
+		return nil, err
+	}
+	polls, err := cfg.polls(ctx, viewer, linked)
+	if err != nil {
+		return nil, err
+	}
+	render := func(c database.Chirp) chirpResponse {
+		res := newChirpResponse(c)
+		if p, ok := polls[c.ID]; ok {
+			res.Poll = &p
+		}
+		if url, ok := chirpLink(c.Body); ok {
+			if p, ok := previews[url]; ok {
+				res.LinkPreview = &p
+			}
+		}
+		return res
+	}
+
+	res := make([]chirpResponse, 0, len(chirps))
+	for _, c := range chirps {
+		r := render(c)
+		if q, ok := quoted[c.QuoteID.UUID]; ok && c.QuoteID.Valid {
+			embedded := render(q)
+			r.Quote = &embedded
+		}
+		res = append(res, r)
+	}
+	return res, nil
 }
 
 func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 	reqBody := &createChirpReqParams{}
 
-	err := json.NewDecoder(r.Body).Decode(reqBody)
+	err := request.DecodeJSON(w, r, reqBody)
 	if err != nil {
-		log.Printf("POST /api/chirps: Error decoding request body: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Server error decoding request body"`))
+		apierror.Write(w, err)
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	principal := auth.PrincipalFrom(r.Context())
+
+	plan, err := cfg.userPlan(r.Context(), principal.UserID)
 	if err != nil {
-		log.Printf("POST /api/chirps: Error retrieving bearer token from authorization header: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		switch err.(type) {
-		case auth.HeaderNotFoundError:
-			w.Write(json.RawMessage(`"error": "Please provide your JWT in the authorization header of your request."`))
-		case auth.WrongAuthorizationSchemeError:
-			w.Write(json.RawMessage(`"error": "Please use the Bearer authorization scheme to authorize your request."`))
+		log.Printf("POST /api/chirps: Error retrieving plan: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if reqBody.Status == "" {
+		reqBody.Status = CHIRP_PUBLISHED
+		if reqBody.PublishAt != nil {
+			reqBody.Status = CHIRP_SCHEDULED
 		}
+	}
+
+	// Drafts and scheduled chirps count against the limit when they're
+	// published.
+	if reqBody.Status == CHIRP_PUBLISHED && !checkChirpRateLimit(w, r, cfg.store, principal.UserID, plan) {
 		return
 	}
 
-	id, err := auth.ValidateJWT(token, cfg.secretKey)
-	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`"error": "Invalid token."`))
+	var missing entitlements.MissingError
+	if reqBody.Status == CHIRP_SCHEDULED {
+		if errors.As(plan.Require(entitlements.SCHEDULE_CHIRPS), &missing) {
+			writeEntitlementError(w, missing)
+			return
+		}
+		if problem := checkPublishAt(reqBody.PublishAt); problem != "" {
+			apierror.Write(w, request.FieldErrors{"publish_at": problem}.Err())
+			return
+		}
+	}
+
+	if reqBody.Poll != nil {
+		publishAt := time.Now().UTC()
+		if reqBody.Status == CHIRP_SCHEDULED {
+			publishAt = *reqBody.PublishAt
+		}
+		if msg := checkPoll(*reqBody.Poll, publishAt); msg != "" {
+			apierror.Write(w, apierror.ValidationFailed(msg))
+			return
+		}
+	}
+
+	if errors.As(plan.CheckChirpLength(len(reqBody.Body)), &missing) {
+		writeEntitlementError(w, missing)
 		return
 	}
 
-	censoredChirp, ok := validateChirp(reqBody.Body)
+	censoredChirp, ok := validateChirp(reqBody.Body, plan.MaxChirpLength)
 	if !ok {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(json.RawMessage(`"error": "Chirp is too long. Max chirp length is 140 characters."`))
+		apierror.Write(w, apierror.ValidationFailed(fmt.Sprintf("Chirp is too long. Max chirp length is %d characters.", plan.MaxChirpLength)))
+		return
+	}
+
+	if reqBody.Visibility == "" {
+		reqBody.Visibility = VISIBILITY_PUBLIC
+	}
+
+	mentions, msg, err := cfg.checkMentions(r.Context(), principal.UserID, reqBody.Mentions)
+	if err != nil {
+		log.Printf("POST /api/chirps: Error checking mentions: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if msg != "" {
+		apierror.Write(w, apierror.ValidationFailed(msg))
 		return
 	}
 
 	dbParams := database.CreateChirpParams{
-		Body:   censoredChirp,
-		UserID: id,
+		Body:       censoredChirp,
+		UserID:     principal.UserID,
+		Status:     reqBody.Status,
+		Visibility: reqBody.Visibility,
+		Mentions:   mentions,
+	}
+	if reqBody.Status == CHIRP_SCHEDULED {
+		dbParams.PublishAt = sql.NullTime{Time: reqBody.PublishAt.UTC(), Valid: true}
 	}
-	chirp, err := cfg.queries.CreateChirp(r.Context(), dbParams)
 
+	if reqBody.ReplyToID != nil {
+		parent, err := cfg.store.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{
+			ID:       *reqBody.ReplyToID,
+			ViewerID: uuid.NullUUID{UUID: principal.UserID, Valid: true},
+		})
+		blocked := false
+		if err == nil {
+			blocked, err = cfg.store.IsBlockedEither(r.Context(), database.IsBlockedEitherParams{
+				BlockerID: parent.UserID,
+				BlockedID: principal.UserID,
+			})
+		}
+		// Chirps the user can't see, or by users on either side of a block,
+		// can't be replied to and look as if they don't exist.
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && blocked) {
+			apierror.Write(w, apierror.NotFound("The chirp being replied to was not found."))
+			return
+		}
+		if err != nil {
+			log.Printf("POST /api/chirps: Error retrieving parent chirp: %v\n", err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+		dbParams.ReplyToID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+		dbParams.ThreadID = uuid.NullUUID{UUID: chirpThreadID(parent), Valid: true}
+	}
+
+	if reqBody.QuoteID != nil {
+		quoted, err := cfg.store.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{
+			ID:       *reqBody.QuoteID,
+			ViewerID: uuid.NullUUID{UUID: principal.UserID, Valid: true},
+		})
+		blocked := false
+		if err == nil {
+			blocked, err = cfg.store.IsBlockedEither(r.Context(), database.IsBlockedEitherParams{
+				BlockerID: quoted.UserID,
+				BlockedID: principal.UserID,
+			})
+		}
+		// Quoting follows the same rules as replying.
+		if errors.Is(err, sql.ErrNoRows) || (err == nil && blocked) {
+			apierror.Write(w, apierror.NotFound("The chirp being quoted was not found."))
+			return
+		}
+		if err != nil {
+			log.Printf("POST /api/chirps: Error retrieving quoted chirp: %v\n", err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+		dbParams.QuoteID = uuid.NullUUID{UUID: quoted.ID, Valid: true}
+	}
+
+	// The chirp, its poll and its webhooks are created together.
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("POST /api/chirps: Error starting transaction: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	defer tx.Rollback()
+
+	chirp, err := tx.CreateChirp(r.Context(), dbParams)
+	if err == nil && reqBody.Poll != nil {
+		err = createPoll(r.Context(), tx, chirp.ID, *reqBody.Poll)
+	}
+	if err == nil && chirp.Status == CHIRP_PUBLISHED {
+		err = enqueueWebhookEvent(r.Context(), tx, chirp.UserID, CHIRP_CREATED_EVENT, newChirpResponse(chirp))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Printf("POST /api/chirps: Error creating chirp: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Database error"`))
+		apierror.Write(w, apierror.Internal("Database error"))
 		return
 	}
 
+	err = queueLinkPreview(r.Context(), cfg.store, chirp.Body)
+	if err != nil {
+		log.Printf("POST /api/chirps: %v\n", err)
+	}
+
+	res, err := cfg.renderChirps(r.Context(), uuid.NullUUID{UUID: chirp.UserID, Valid: true}, nil, []database.Chirp{chirp})
+	if err != nil {
+		log.Printf("POST /api/chirps: Error rendering chirp: %v\n", err)
+		res = newChirpsResponse([]database.Chirp{chirp})
+	}
+
 	w.WriteHeader(http.StatusCreated)
-	err = json.NewEncoder(w).Encode(chirp)
+	err = json.NewEncoder(w).Encode(res[0])
 	if err != nil {
 		log.Printf("POST /api/chirps: Error writing chirp response: %v\n", err)
 	}
@@ -76,41 +372,79 @@ func (cfg *apiConfig) createChirp(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 	var chirps []database.Chirp
-	var err error
-	authorId := r.URL.Query().Get("author_id")
+	authorID, err := request.QueryUUID(r, "author_id")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+	sortBy, err := request.QueryEnum(r, "sort", "asc", "asc", "desc")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
 
-	if authorId != "" {
-		authorUUID := uuid.MustParse(authorId)
-		chirps, err = cfg.queries.GetChirpsByAuthorId(r.Context(), authorUUID)
+	viewer := chirpViewer(r)
+	hidden, err := cfg.hiddenAuthors(r.Context(), viewer)
+	if err != nil {
+		log.Printf("GET /api/chirps: Error retrieving blocks and mutes: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	var pinned []uuid.UUID
+	if authorID.Valid {
+		pinned, err = cfg.store.GetPinnedChirpIDs(r.Context(), authorID.UUID)
+		if err != nil {
+			log.Printf("GET /api/chirps: Error retrieving pinned chirps: %v\n", err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+		chirps, err = cfg.store.GetChirpsByAuthorId(r.Context(), database.GetChirpsByAuthorIdParams{
+			UserID:   authorID.UUID,
+			ViewerID: viewer,
+		})
 		if err != nil {
 			log.Printf("GET /api/chirps: Error retrieving chirps: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write(json.RawMessage(`"error": "Database error"`))
+			apierror.Write(w, apierror.Internal("Database error"))
 			return
 		}
 	} else {
-		chirps, err = cfg.queries.GetChirps(r.Context())
+		chirps, err = cfg.store.GetChirps(r.Context(), viewer)
 		if err != nil {
 			log.Printf("GET /api/chirps: Error retrieving chirps: %v\n", err)
-			w.WriteHeader(http.StatusInternalServerError)
-			w.Write(json.RawMessage(`"error": "Database error"`))
+			apierror.Write(w, apierror.Internal("Database error"))
 			return
 		}
 	}
 
-	sortBy := r.URL.Query().Get("sort")
+	chirps = filterHiddenChirps(chirps, hidden)
+
 	if sortBy == "desc" {
 		sort.Slice(chirps, func(i, j int) bool {
 			return chirps[i].CreatedAt.After(chirps[j].CreatedAt)
 		})
 	}
 
-	jsonRes, err := json.Marshal(chirps)
+	// Pinned chirps come first when listing an author's chirps.
+	chirps = pinnedFirst(chirps, pinned)
+
+	res, err := cfg.renderChirps(r.Context(), viewer, hidden, chirps)
+	if err != nil {
+		log.Printf("GET /api/chirps: Error rendering chirps: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	for i := range res {
+		res[i].Pinned = slices.Contains(pinned, res[i].ID)
+	}
+	cfg.recordImpressions(viewer, chirps)
+
+	jsonRes, err := json.Marshal(res)
 	if err != nil {
 		log.Printf("GET /api/chirps: Error encoding chirps response: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error encoding response"`))
+		apierror.Write(w, apierror.Internal("Error encoding response"))
 		return
 	}
 
@@ -120,19 +454,42 @@ func (cfg *apiConfig) getChirps(w http.ResponseWriter, r *http.Request) {
 }
 
 func (cfg *apiConfig) getChirp(w http.ResponseWriter, r *http.Request) {
-	log.Printf("UUID: %v\n", r.PathValue("id"))
-	id := uuid.MustParse(r.PathValue("id"))
-	chirp, err := cfg.queries.GetChirpById(r.Context(), id)
+	w.Header().Set("Content-Type", "application/json")
+	id, err := request.PathUUID(r, "id")
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write(json.RawMessage(`"error": "Chirp not found"`))
+		apierror.Write(w, err)
 		return
 	}
 
-	jsonRes, err := json.Marshal(chirp)
+	viewer := chirpViewer(r)
+	hidden, err := cfg.hiddenAuthors(r.Context(), viewer)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error encoding response"`))
+		log.Printf("GET /api/chirps/%s: Error retrieving blocks and mutes: %v\n", id, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	chirp, err := cfg.store.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{
+		ID:       id,
+		ViewerID: viewer,
+	})
+	_, isHidden := hidden[chirp.UserID]
+	if err != nil || isHidden {
+		apierror.Write(w, apierror.NotFound("Chirp not found"))
+		return
+	}
+
+	res, err := cfg.renderChirps(r.Context(), viewer, hidden, []database.Chirp{chirp})
+	if err != nil {
+		log.Printf("GET /api/chirps/%s: Error rendering chirp: %v\n", id, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	cfg.recordImpressions(viewer, []database.Chirp{chirp})
+
+	jsonRes, err := json.Marshal(res[0])
+	if err != nil {
+		apierror.Write(w, apierror.Internal("Error encoding response"))
 		return
 	}
 
@@ -141,47 +498,197 @@ func (cfg *apiConfig) getChirp(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
-func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
+// getChirpThread returns the published chirps in the thread a chirp belongs
+// to, oldest first.
+func (cfg *apiConfig) getChirpThread(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirpID, err := request.PathUUID(r, "chirpID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	viewer := chirpViewer(r)
+	hidden, err := cfg.hiddenAuthors(r.Context(), viewer)
+	if err != nil {
+		log.Printf("GET /api/chirps/%s/thread: Error retrieving blocks and mutes: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	chirp, err := cfg.store.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{
+		ID:       chirpID,
+		ViewerID: viewer,
+	})
+	_, isHidden := hidden[chirp.UserID]
+	if err != nil || isHidden {
+		apierror.Write(w, apierror.NotFound("Chirp not found"))
+		return
+	}
+
+	chirps, err := cfg.store.GetChirpThread(r.Context(), database.GetChirpThreadParams{
+		ID:       chirpThreadID(chirp),
+		ViewerID: viewer,
+	})
+	if err != nil {
+		log.Printf("GET /api/chirps/%s/thread: Error retrieving thread: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	chirps = filterHiddenChirps(chirps, hidden)
+	res, err := cfg.renderChirps(r.Context(), viewer, hidden, chirps)
+	if err != nil {
+		log.Printf("GET /api/chirps/%s/thread: Error rendering thread: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	cfg.recordImpressions(viewer, chirps)
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/chirps/%s/thread: Error writing response: %v\n", chirpID, err)
+	}
+}
+
+type updateChirpReqParams struct {
+	Body string `json:"body"`
+}
+
+// updateChirp edits the body of one of the user's chirps. Editing published
+// chirps is a Chirpy Red feature.
+func (cfg *apiConfig) updateChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 	chirpId := r.PathValue("chirpID")
 
-	chirpUUID := uuid.MustParse(chirpId)
-	chirp, err := cfg.queries.GetChirpById(r.Context(), chirpUUID)
+	chirpUUID, err := request.PathUUID(r, "chirpID")
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write(json.RawMessage(fmt.Sprintf(`{"error": "Could not find chirp with id %s"}`, chirpId)))
+		apierror.Write(w, err)
+		return
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+
+	chirp, err := cfg.store.GetChirpById(r.Context(), chirpUUID)
+	if err != nil {
+		apierror.Write(w, apierror.NotFound(fmt.Sprintf("Could not find chirp with id %s", chirpId)))
+		return
+	}
+
+	if principal.UserID != chirp.UserID {
+		apierror.Write(w, apierror.Forbidden("You do not have permission to edit this chirp."))
 		return
 	}
 
-	token, err := auth.GetBearerToken(r.Header)
+	plan, err := cfg.userPlan(r.Context(), principal.UserID)
 	if err != nil {
-		log.Printf("DELETE /api/chirps/%s: Error reading request headers: %v\n", chirpId, err)
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`{"error": "Missing/malformed access token."}`))
+		log.Printf("PUT /api/chirps/%s: Error retrieving plan: %v\n", chirpId, err)
+		apierror.Write(w, apierror.Internal("Database error"))
 		return
 	}
 
-	userId, err := auth.ValidateJWT(token, cfg.secretKey)
+	// Drafts and scheduled chirps can be edited on any plan.
+	var missing entitlements.MissingError
+	if chirp.Status == CHIRP_PUBLISHED && errors.As(plan.Require(entitlements.EDIT_CHIRPS), &missing) {
+		writeEntitlementError(w, missing)
+		return
+	}
+
+	reqBody := &updateChirpReqParams{}
+	err = request.DecodeJSON(w, r, reqBody)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`{"error": "Missing/malformed access token."}`))
+		apierror.Write(w, err)
 		return
 	}
 
-	if userId != chirp.UserID {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write(json.RawMessage(`{"error": "You do not have permission to delete this chirp."}`))
+	if errors.As(plan.CheckChirpLength(len(reqBody.Body)), &missing) {
+		writeEntitlementError(w, missing)
 		return
 	}
 
-	err = cfg.queries.DeleteChirpById(r.Context(), chirpUUID)
+	censoredChirp, ok := validateChirp(reqBody.Body, plan.MaxChirpLength)
+	if !ok {
+		apierror.Write(w, apierror.ValidationFailed(fmt.Sprintf("Chirp is too long. Max chirp length is %d characters.", plan.MaxChirpLength)))
+		return
+	}
+
+	chirp, err = cfg.store.UpdateChirpBody(r.Context(), database.UpdateChirpBodyParams{
+		ID:   chirpUUID,
+		Body: censoredChirp,
+	})
+	if err != nil {
+		log.Printf("PUT /api/chirps/%s: Error updating chirp: %v\n", chirpId, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	err = queueLinkPreview(r.Context(), cfg.store, chirp.Body)
+	if err != nil {
+		log.Printf("PUT /api/chirps/%s: %v\n", chirpId, err)
+	}
+
+	res, err := cfg.renderChirps(r.Context(), uuid.NullUUID{UUID: chirp.UserID, Valid: true}, nil, []database.Chirp{chirp})
+	if err != nil {
+		log.Printf("PUT /api/chirps/%s: Error rendering chirp: %v\n", chirpId, err)
+		res = newChirpsResponse([]database.Chirp{chirp})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res[0])
+	if err != nil {
+		log.Printf("PUT /api/chirps/%s: Error writing response: %v\n", chirpId, err)
+	}
+}
+
+func (cfg *apiConfig) deleteChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirpId := r.PathValue("chirpID")
+
+	chirpUUID, err := request.PathUUID(r, "chirpID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	chirp, err := cfg.store.GetChirpById(r.Context(), chirpUUID)
+	if err != nil {
+		apierror.Write(w, apierror.NotFound(fmt.Sprintf("Could not find chirp with id %s", chirpId)))
+		return
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+
+	if principal.UserID != chirp.UserID {
+		apierror.Write(w, apierror.Forbidden("You do not have permission to delete this chirp."))
+		return
+	}
+
+	// The chirp is deleted in the same transaction that queues its webhooks.
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("DELETE /api/chirps/%s: Error starting transaction: %v\n", chirpId, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	defer tx.Rollback()
+
+	err = tx.DeleteChirpById(r.Context(), chirpUUID)
+	if err == nil && chirp.Status == CHIRP_PUBLISHED {
+		err = enqueueWebhookEvent(r.Context(), tx, chirp.UserID, CHIRP_DELETED_EVENT, map[string]uuid.UUID{
+			"id":      chirp.ID,
+			"user_id": chirp.UserID,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
 	if err != nil {
 		log.Printf("DELETE /api/chirps/%s: Error deleting chirp from database: %v\n", chirpId, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Database error"}`))
+		apierror.Write(w, apierror.Internal("Database error"))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	w.Write(nil)
 	return
 }
@@ -0,0 +1,423 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+// Who may start a conversation with a user.
+const (
+	DM_EVERYONE  = "everyone"
+	DM_FOLLOWING = "following"
+	DM_NOBODY    = "nobody"
+)
+
+const (
+	MAX_DIRECT_MESSAGE_LENGTH = 1000
+	// Including the user who starts the conversation.
+	MAX_CONVERSATION_MEMBERS = 10
+)
+
+type createConversationReqParams struct {
+	MemberIDs []uuid.UUID `json:"member_ids"`
+	// An optional first message.
+	Body string `json:"body,omitempty"`
+}
+
+type sendDirectMessageReqParams struct {
+	Body string `json:"body"`
+}
+
+type conversationResponse struct {
+	ID          uuid.UUID   `json:"id"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+	MemberIDs   []uuid.UUID `json:"member_ids"`
+	LastReadAt  *time.Time  `json:"last_read_at"`
+	UnreadCount int64       `json:"unread_count"`
+}
+
+// mayStartConversation reports whether sender may start a conversation with
+// recipient, going by blocks and the recipient's DM policy.
+func (cfg *apiConfig) mayStartConversation(r *http.Request, sender uuid.UUID, recipient database.User) (bool, error) {
+	blocked, err := cfg.store.IsBlockedEither(r.Context(), database.IsBlockedEitherParams{
+		BlockerID: sender,
+		BlockedID: recipient.ID,
+	})
+	if err != nil || blocked {
+		return false, err
+	}
+
+	switch recipient.DmPolicy {
+	case DM_EVERYONE:
+		return true, nil
+	case DM_FOLLOWING:
+		return cfg.store.IsFollowing(r.Context(), database.IsFollowingParams{
+			FollowerID: recipient.ID,
+			FolloweeID: sender,
+		})
+	}
+	return false, nil
+}
+
+// checkDirectMessage moderates a message body as chirps are. It returns the
+// censored body, or a message explaining what is wrong with it.
+func checkDirectMessage(body string) (string, string) {
+	if strings.TrimSpace(body) == "" {
+		return "", "Messages can't be empty."
+	}
+	censored, ok := validateChirp(body, MAX_DIRECT_MESSAGE_LENGTH)
+	if !ok {
+		return "", fmt.Sprintf("Message is too long. Max message length is %d characters.", MAX_DIRECT_MESSAGE_LENGTH)
+	}
+	return censored, ""
+}
+
+// createConversation starts a conversation with one or more users. Starting a
+// one-to-one conversation that already exists returns the existing one.
+func (cfg *apiConfig) createConversation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	reqBody := &createConversationReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	memberIDs := []uuid.UUID{}
+	seen := map[uuid.UUID]struct{}{userID: {}}
+	for _, id := range reqBody.MemberIDs {
+		if _, ok := seen[id]; !ok {
+			seen[id] = struct{}{}
+			memberIDs = append(memberIDs, id)
+		}
+	}
+	if len(memberIDs) == 0 || len(memberIDs) >= MAX_CONVERSATION_MEMBERS {
+		apierror.Write(w, apierror.BadRequest(fmt.Sprintf("Conversations need between 1 and %d other members.", MAX_CONVERSATION_MEMBERS-1)))
+		return
+	}
+
+	var body string
+	if reqBody.Body != "" {
+		var msg string
+		body, msg = checkDirectMessage(reqBody.Body)
+		if msg != "" {
+			apierror.Write(w, apierror.BadRequest(msg))
+			return
+		}
+	}
+
+	for _, id := range memberIDs {
+		member, err := cfg.store.GetUserByID(r.Context(), id)
+		if errors.Is(err, sql.ErrNoRows) {
+			apierror.Write(w, apierror.NotFound(fmt.Sprintf("User %s not found", id)))
+			return
+		}
+		if err != nil {
+			log.Printf("POST /api/conversations: Error retrieving user %s: %v\n", id, err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+
+		ok, err := cfg.mayStartConversation(r, userID, member)
+		if err != nil {
+			log.Printf("POST /api/conversations: Error checking whether %s accepts messages: %v\n", id, err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+		// Blocks and DM policies get the same response, so users can't
+		// tell that they've been blocked.
+		if !ok {
+			apierror.Write(w, apierror.Forbidden(fmt.Sprintf("User %s doesn't accept messages from you.", id)))
+			return
+		}
+	}
+
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("POST /api/conversations: Error starting transaction: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	defer tx.Rollback()
+
+	status := http.StatusCreated
+	var conversation database.Conversation
+	if len(memberIDs) == 1 {
+		conversation, err = tx.GetDirectConversation(r.Context(), database.GetDirectConversationParams{
+			UserID:      userID,
+			OtherUserID: memberIDs[0],
+		})
+		if err == nil {
+			status = http.StatusOK
+		} else if errors.Is(err, sql.ErrNoRows) {
+			err = nil
+		}
+	}
+	if err == nil && status == http.StatusCreated {
+		conversation, err = tx.CreateConversation(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+		for _, id := range append([]uuid.UUID{userID}, memberIDs...) {
+			if err != nil {
+				break
+			}
+			err = tx.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+				ConversationID: conversation.ID,
+				UserID:         id,
+			})
+		}
+	}
+	if err == nil && body != "" {
+		_, err = tx.CreateDirectMessage(r.Context(), database.CreateDirectMessageParams{
+			ConversationID: conversation.ID,
+			SenderID:       userID,
+			Body:           body,
+		})
+		if err == nil {
+			err = tx.TouchConversation(r.Context(), conversation.ID)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("POST /api/conversations: Error creating conversation: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := conversationResponse{
+		ID:        conversation.ID,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		MemberIDs: append([]uuid.UUID{userID}, memberIDs...),
+	}
+	w.WriteHeader(status)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("POST /api/conversations: Error writing response: %v\n", err)
+	}
+}
+
+// getConversations lists the user's conversations, most recently active
+// first. Pages are fetched by passing the last updated_at seen as before.
+func (cfg *apiConfig) getConversations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	before, limit, ok := timePage(w, r)
+	if !ok {
+		return
+	}
+
+	conversations, err := cfg.store.GetConversationsByUser(r.Context(), database.GetConversationsByUserParams{
+		UserID: userID,
+		Before: before,
+		Limit:  limit,
+	})
+	if err != nil {
+		log.Printf("GET /api/conversations: Error retrieving conversations: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	ids := make([]uuid.UUID, 0, len(conversations))
+	for _, c := range conversations {
+		ids = append(ids, c.ID)
+	}
+	members, err := cfg.store.GetConversationMembers(r.Context(), ids)
+	if err != nil {
+		log.Printf("GET /api/conversations: Error retrieving members: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	memberIDs := map[uuid.UUID][]uuid.UUID{}
+	for _, m := range members {
+		memberIDs[m.ConversationID] = append(memberIDs[m.ConversationID], m.UserID)
+	}
+
+	res := make([]conversationResponse, 0, len(conversations))
+	for _, c := range conversations {
+		res = append(res, conversationResponse{
+			ID:          c.ID,
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+			MemberIDs:   memberIDs[c.ID],
+			LastReadAt:  nullTimePtr(c.LastReadAt),
+			UnreadCount: c.UnreadCount,
+		})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/conversations: Error writing response: %v\n", err)
+	}
+}
+
+// userConversation returns the id of the conversation named in the request
+// path and the user making the request, if they are a member. Otherwise it
+// writes an error response and returns false.
+func (cfg *apiConfig) userConversation(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	conversationID, err := request.PathUUID(r, "conversationID")
+	if err != nil {
+		apierror.Write(w, err)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	_, err = cfg.store.GetConversationMember(r.Context(), database.GetConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.NotFound("Conversation not found"))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+	if err != nil {
+		log.Printf("%s %s: Error retrieving conversation member: %v\n", r.Method, r.URL.Path, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return conversationID, userID, true
+}
+
+// getDirectMessages lists a conversation's messages, newest first. Pages are
+// fetched by passing the last created_at seen as before.
+func (cfg *apiConfig) getDirectMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	conversationID, _, ok := cfg.userConversation(w, r)
+	if !ok {
+		return
+	}
+
+	before, limit, ok := timePage(w, r)
+	if !ok {
+		return
+	}
+
+	messages, err := cfg.store.GetDirectMessages(r.Context(), database.GetDirectMessagesParams{
+		ConversationID: conversationID,
+		Before:         before,
+		Limit:          limit,
+	})
+	if err != nil {
+		log.Printf("GET /api/conversations/%s/messages: Error retrieving messages: %v\n", conversationID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if messages == nil {
+		messages = []database.DirectMessage{}
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(messages)
+	if err != nil {
+		log.Printf("GET /api/conversations/%s/messages: Error writing response: %v\n", conversationID, err)
+	}
+}
+
+func (cfg *apiConfig) sendDirectMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	conversationID, userID, ok := cfg.userConversation(w, r)
+	if !ok {
+		return
+	}
+
+	reqBody := &sendDirectMessageReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	body, msg := checkDirectMessage(reqBody.Body)
+	if msg != "" {
+		apierror.Write(w, apierror.BadRequest(msg))
+		return
+	}
+
+	// Blocks made after the conversation started stop it in both
+	// directions.
+	blocked, err := cfg.store.IsBlockedInConversation(r.Context(), database.IsBlockedInConversationParams{
+		UserID:         userID,
+		ConversationID: conversationID,
+	})
+	if err != nil {
+		log.Printf("POST /api/conversations/%s/messages: Error checking blocks: %v\n", conversationID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if blocked {
+		apierror.Write(w, apierror.Forbidden("You can't send messages to this conversation."))
+		return
+	}
+
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("POST /api/conversations/%s/messages: Error starting transaction: %v\n", conversationID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	defer tx.Rollback()
+
+	message, err := tx.CreateDirectMessage(r.Context(), database.CreateDirectMessageParams{
+		ConversationID: conversationID,
+		SenderID:       userID,
+		Body:           body,
+	})
+	if err == nil {
+		err = tx.TouchConversation(r.Context(), conversationID)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("POST /api/conversations/%s/messages: Error sending message: %v\n", conversationID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(message)
+	if err != nil {
+		log.Printf("POST /api/conversations/%s/messages: Error writing response: %v\n", conversationID, err)
+	}
+}
+
+// markConversationRead marks every message in the conversation as read by
+// the user.
+func (cfg *apiConfig) markConversationRead(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	conversationID, userID, ok := cfg.userConversation(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.store.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		log.Printf("POST /api/conversations/%s/read: Error marking conversation read: %v\n", conversationID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
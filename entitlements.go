@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// Posting limits are per plan, counted over this window.
+const CHIRP_RATE_WINDOW = time.Hour
+
+// entitlementErrorDetails are the details of errors about what a user's
+// plan allows.
+type entitlementErrorDetails struct {
+	Entitlement  string `json:"entitlement,omitempty"`
+	Plan         string `json:"plan"`
+	RequiredPlan string `json:"required_plan,omitempty"`
+}
+
+func newEntitlementErrorDetails(err entitlements.MissingError) entitlementErrorDetails {
+	details := entitlementErrorDetails{
+		Entitlement: string(err.Entitlement),
+		Plan:        err.Plan,
+	}
+	if err.UpgradeTo != nil {
+		details.RequiredPlan = err.UpgradeTo.Name
+	}
+	return details
+}
+
+// userPlan looks up the plan userID is on. The database is checked rather
+// than the access token, so upgrades and downgrades apply immediately.
+func (cfg *apiConfig) userPlan(ctx context.Context, userID uuid.UUID) (entitlements.Plan, error) {
+	user, err := cfg.store.GetUserByID(ctx, userID)
+	if err != nil {
+		return entitlements.Plan{}, err
+	}
+	return entitlements.ForUser(user.IsChirpyRed), nil
+}
+
+// entitlementError is a 402 or 403, naming the missing entitlement and the
+// plan that would grant it.
+func entitlementError(err entitlements.MissingError) *apierror.Error {
+	code := apierror.ENTITLEMENT_REQUIRED
+	if err.StatusCode() == http.StatusForbidden {
+		code = apierror.FORBIDDEN
+	}
+	return apierror.New(err.StatusCode(), code, err.Error()).WithDetails(newEntitlementErrorDetails(err))
+}
+
+func writeEntitlementError(w http.ResponseWriter, err entitlements.MissingError) {
+	apierror.Write(w, entitlementError(err))
+}
+
+// chirpRateLimitError returns a 429 *apierror.Error if userID has published
+// as many chirps as plan allows in the last CHIRP_RATE_WINDOW.
+func chirpRateLimitError(ctx context.Context, q database.Querier, userID uuid.UUID, plan entitlements.Plan) error {
+	count, err := q.CountChirpsByAuthorSince(ctx, database.CountChirpsByAuthorSinceParams{
+		UserID:    userID,
+		CreatedAt: time.Now().UTC().Add(-CHIRP_RATE_WINDOW),
+	})
+	if err != nil {
+		return fmt.Errorf("Error counting recent chirps: %v", err)
+	}
+	if count < int64(plan.ChirpsPerHour) {
+		return nil
+	}
+
+	message := fmt.Sprintf("You can post %d chirps per hour.", plan.ChirpsPerHour)
+	details := entitlementErrorDetails{Plan: plan.Name}
+	if missing, ok := plan.Require(entitlements.HIGH_RATE_LIMIT).(entitlements.MissingError); ok {
+		message += " " + missing.Error()
+		details = newEntitlementErrorDetails(missing)
+	}
+	return apierror.New(http.StatusTooManyRequests, apierror.RATE_LIMITED, message).WithDetails(details)
+}
+
+// checkChirpRateLimit reports whether userID may publish another chirp on
+// plan. If not, it responds with 429.
+func checkChirpRateLimit(w http.ResponseWriter, r *http.Request, q database.Querier, userID uuid.UUID, plan entitlements.Plan) bool {
+	err := chirpRateLimitError(r.Context(), q, userID, plan)
+	if err == nil {
+		return true
+	}
+	writeChirpCheckError(w, r, err)
+	return false
+}
+
+// writeChirpCheckError responds with an error from checking whether a chirp
+// may be published. Rate limit errors say when to retry. Errors that aren't
+// an *apierror.Error are logged and reported as database errors.
+func writeChirpCheckError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		log.Printf("%s %s: %v\n", r.Method, r.URL.Path, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if apiErr.Code == apierror.RATE_LIMITED {
+		w.Header().Set("Retry-After", fmt.Sprint(int(CHIRP_RATE_WINDOW.Seconds())))
+	}
+	apierror.Write(w, apiErr)
+}
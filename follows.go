@@ -0,0 +1,228 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+)
+
+func (cfg *apiConfig) followUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	followeeID, err := request.PathUUID(r, "userID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	if followeeID == userID {
+		apierror.Write(w, apierror.BadRequest("You can't follow yourself."))
+		return
+	}
+
+	followee, err := cfg.store.GetUserByID(r.Context(), followeeID)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.NotFound("User not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("POST /api/users/%s/follow: Error retrieving user: %v\n", followeeID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	blocked, err := cfg.store.IsBlockedEither(r.Context(), database.IsBlockedEitherParams{
+		BlockerID: followeeID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		log.Printf("POST /api/users/%s/follow: Error checking blocks: %v\n", followeeID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if blocked {
+		apierror.Write(w, apierror.Forbidden("You can't follow this user."))
+		return
+	}
+
+	following, err := cfg.store.IsFollowing(r.Context(), database.IsFollowingParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("POST /api/users/%s/follow: Error checking follows: %v\n", followeeID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	// Protected users approve their followers, so following them only
+	// sends a request.
+	if followee.Protected && !following {
+		err = cfg.store.CreateFollowRequest(r.Context(), database.CreateFollowRequestParams{
+			RequesterID: userID,
+			TargetID:    followeeID,
+		})
+		if err != nil {
+			log.Printf("POST /api/users/%s/follow: Error requesting follow: %v\n", followeeID, err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+
+		w.WriteHeader(http.StatusAccepted)
+		w.Write(json.RawMessage(`{"status": "requested"}`))
+		return
+	}
+
+	err = cfg.store.FollowUser(r.Context(), database.FollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	if err != nil {
+		log.Printf("POST /api/users/%s/follow: Error following user: %v\n", followeeID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unfollowUser(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	followeeID, err := request.PathUUID(r, "userID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	rows, err := cfg.store.UnfollowUser(r.Context(), database.UnfollowUserParams{
+		FollowerID: userID,
+		FolloweeID: followeeID,
+	})
+	// Unfollowing also withdraws a pending request.
+	if err == nil && rows == 0 {
+		rows, err = cfg.store.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+			RequesterID: userID,
+			TargetID:    followeeID,
+		})
+	}
+	if err != nil {
+		log.Printf("DELETE /api/users/%s/follow: Error unfollowing user: %v\n", followeeID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("You don't follow this user."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// getFollowRequests lists the users waiting for the user to approve them as
+// followers, newest first.
+func (cfg *apiConfig) getFollowRequests(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	requests, err := cfg.store.GetFollowRequestsByTarget(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/follow-requests: Error retrieving follow requests: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := make([]relationshipResponse, 0, len(requests))
+	for _, req := range requests {
+		res = append(res, relationshipResponse{UserID: req.RequesterID, CreatedAt: req.CreatedAt})
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/follow-requests: Error writing response: %v\n", err)
+	}
+}
+
+// approveFollowRequest makes the requester one of the user's followers.
+func (cfg *apiConfig) approveFollowRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	requesterID, err := request.PathUUID(r, "userID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("POST /api/follow-requests/%s: Error starting transaction: %v\n", requesterID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+		RequesterID: requesterID,
+		TargetID:    userID,
+	})
+	if err == nil && rows == 0 {
+		apierror.Write(w, apierror.NotFound("This user hasn't asked to follow you."))
+		return
+	}
+	if err == nil {
+		err = tx.FollowUser(r.Context(), database.FollowUserParams{
+			FollowerID: requesterID,
+			FolloweeID: userID,
+		})
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("POST /api/follow-requests/%s: Error approving follow request: %v\n", requesterID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) rejectFollowRequest(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	requesterID, err := request.PathUUID(r, "userID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	rows, err := cfg.store.DeleteFollowRequest(r.Context(), database.DeleteFollowRequestParams{
+		RequesterID: requesterID,
+		TargetID:    userID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/follow-requests/%s: Error rejecting follow request: %v\n", requesterID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("This user hasn't asked to follow you."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,136 @@
+// Package apierror defines the errors handlers return to API clients and
+// writes them in one shape:
+//
+//	{"error": {"code": "not_found", "message": "Chirp not found", "details": null}}
+//
+// Codes are stable and meant for programs; messages are for people and may
+// change.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+type Code string
+
+const (
+	BAD_REQUEST          Code = "bad_request"
+	INVALID_JSON         Code = "invalid_json"
+	VALIDATION_FAILED    Code = "validation_failed"
+	BODY_TOO_LARGE       Code = "body_too_large"
+	UNAUTHORIZED         Code = "unauthorized"
+	FORBIDDEN            Code = "forbidden"
+	INSUFFICIENT_SCOPE   Code = "insufficient_scope"
+	NOT_FOUND            Code = "not_found"
+	CONFLICT             Code = "conflict"
+	UNPROCESSABLE        Code = "unprocessable"
+	ENTITLEMENT_REQUIRED Code = "entitlement_required"
+	RATE_LIMITED         Code = "rate_limited"
+	INTERNAL             Code = "internal_error"
+	UNAVAILABLE          Code = "unavailable"
+)
+
+type Error struct {
+	Status  int
+	Code    Code
+	Message string
+	// Anything that helps clients act on the error, such as which fields
+	// were invalid. Encoded as JSON.
+	Details any
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// WithDetails returns a copy of e with details attached.
+func (e *Error) WithDetails(details any) *Error {
+	withDetails := *e
+	withDetails.Details = details
+	return &withDetails
+}
+
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, BAD_REQUEST, message)
+}
+
+func InvalidJSON(message string) *Error {
+	return New(http.StatusBadRequest, INVALID_JSON, message)
+}
+
+// ValidationFailed is for requests that are well formed but have invalid
+// values. Details usually say which fields are invalid.
+func ValidationFailed(message string) *Error {
+	return New(http.StatusBadRequest, VALIDATION_FAILED, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, UNAUTHORIZED, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, FORBIDDEN, message)
+}
+
+// InsufficientScope is for valid tokens that weren't granted the scope a
+// request needs.
+func InsufficientScope(message string) *Error {
+	return New(http.StatusForbidden, INSUFFICIENT_SCOPE, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, NOT_FOUND, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CONFLICT, message)
+}
+
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, INTERNAL, message)
+}
+
+func Unavailable(message string) *Error {
+	return New(http.StatusServiceUnavailable, UNAVAILABLE, message)
+}
+
+type body struct {
+	Error envelope `json:"error"`
+}
+
+type envelope struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	Details any    `json:"details"`
+}
+
+// Write responds with err. Errors that aren't an *Error are reported as an
+// internal error without revealing their message.
+func Write(w http.ResponseWriter, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal("Server error")
+	}
+
+	raw, marshalErr := json.Marshal(body{Error: envelope{
+		Code:    apiErr.Code,
+		Message: apiErr.Message,
+		Details: apiErr.Details,
+	}})
+	if marshalErr != nil {
+		log.Printf("Error marshalling API error %q: %v\n", apiErr.Message, marshalErr)
+		apiErr = Internal("Server error")
+		raw, _ = json.Marshal(body{Error: envelope{Code: apiErr.Code, Message: apiErr.Message}})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(apiErr.Status)
+	w.Write(raw)
+}
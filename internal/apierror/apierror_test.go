@@ -0,0 +1,89 @@
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func decode(t *testing.T, rec *httptest.ResponseRecorder) map[string]map[string]any {
+	t.Helper()
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Expected Content-Type application/json, got %q", ct)
+	}
+	var res map[string]map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+		t.Fatalf("Expected a JSON body, got %q: %v", rec.Body.String(), err)
+	}
+	return res
+}
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, NotFound("Chirp not found"))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("Expected 404, got %d", rec.Code)
+	}
+	res := decode(t, rec)
+	if res["error"]["code"] != "not_found" || res["error"]["message"] != "Chirp not found" {
+		t.Fatalf("Unexpected body %v", res)
+	}
+	if details, ok := res["error"]["details"]; !ok || details != nil {
+		t.Fatalf("Expected null details, got %v", details)
+	}
+}
+
+func TestWriteDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, BadRequest("Invalid chirp").WithDetails(map[string]string{"body": "is required"}))
+
+	res := decode(t, rec)
+	details, ok := res["error"]["details"].(map[string]any)
+	if !ok || details["body"] != "is required" {
+		t.Fatalf("Unexpected details %v", res["error"]["details"])
+	}
+}
+
+func TestWithDetailsCopies(t *testing.T) {
+	base := BadRequest("Invalid")
+	base.WithDetails("details")
+	if base.Details != nil {
+		t.Fatal("Expected WithDetails to leave the original error unchanged")
+	}
+}
+
+func TestWriteWrapped(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, fmt.Errorf("creating chirp: %w", Conflict("Already published")))
+
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("Expected 409, got %d", rec.Code)
+	}
+}
+
+func TestWriteOtherErrors(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, errors.New("pq: connection refused"))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rec.Code)
+	}
+	res := decode(t, rec)
+	if res["error"]["code"] != "internal_error" || res["error"]["message"] != "Server error" {
+		t.Fatalf("Expected the error message to be hidden, got %v", res)
+	}
+}
+
+func TestWriteUnmarshalableDetails(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Write(rec, BadRequest("Invalid").WithDetails(func() {}))
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("Expected 500, got %d", rec.Code)
+	}
+	decode(t, rec)
+}
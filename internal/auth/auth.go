@@ -2,10 +2,12 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"strings"
 	"time"
 
@@ -31,14 +33,129 @@ func CheckPasswordHash(password, hash string) error {
 	return nil
 }
 
-func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
+const (
+	TOKEN_ISSUER      = "chirpy"
+	TOKEN_AUDIENCE    = "chirpy-api"
+	DEFAULT_TOKEN_TTL = time.Hour
+)
+
+// Claims are the claims carried by a Chirpy access token. UserID is parsed
+// from the subject when the token is validated.
+type Claims struct {
+	jwt.RegisteredClaims
+	Scopes    []string  `json:"scopes,omitempty"`
+	Roles     []string  `json:"roles,omitempty"`
+	ChirpyRed bool      `json:"chirpy_red,omitempty"`
+	ClientID  string    `json:"client_id,omitempty"`
+	UserID    uuid.UUID `json:"-"`
+}
+
+// HasScope reports whether the token was granted scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Allows reports whether the token may be used for an action requiring
+// scope. Tokens issued without scopes to first-party sessions are not
+// restricted.
+func (c *Claims) Allows(scope string) bool {
+	if c.Scopes == nil && c.ClientID == "" {
+		return true
+	}
+	return c.HasScope(scope)
+}
+
+// HasRole reports whether the token carries role.
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+type jwtOptions struct {
+	ttl       time.Duration
+	audience  []string
+	scopes    []string
+	roles     []string
+	chirpyRed bool
+	clientID  string
+}
+
+type JWTOption func(*jwtOptions)
+
+// WithTTL sets how long the token is valid for. Non-positive durations are
+// ignored.
+func WithTTL(ttl time.Duration) JWTOption {
+	return func(o *jwtOptions) {
+		if ttl > 0 {
+			o.ttl = ttl
+		}
+	}
+}
+
+// WithAudience replaces the default audience of the token.
+func WithAudience(audience ...string) JWTOption {
+	return func(o *jwtOptions) {
+		o.audience = audience
+	}
+}
+
+func WithScopes(scopes ...string) JWTOption {
+	return func(o *jwtOptions) {
+		o.scopes = scopes
+	}
+}
+
+func WithRoles(roles ...string) JWTOption {
+	return func(o *jwtOptions) {
+		o.roles = roles
+	}
+}
+
+func WithChirpyRed(isChirpyRed bool) JWTOption {
+	return func(o *jwtOptions) {
+		o.chirpyRed = isChirpyRed
+	}
+}
+
+// WithClientID records the OAuth client a token was issued to.
+func WithClientID(clientID string) JWTOption {
+	return func(o *jwtOptions) {
+		o.clientID = clientID
+	}
+}
+
+func MakeJWT(userID uuid.UUID, tokenSecret string, opts ...JWTOption) (string, error) {
+	options := jwtOptions{
+		ttl:      DEFAULT_TOKEN_TTL,
+		audience: []string{TOKEN_AUDIENCE},
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
 	issueTime := time.Now().UTC()
-	expireTime := issueTime.Add(time.Hour)
-	claims := jwt.RegisteredClaims{
-		Issuer:    "chirpy",
-		IssuedAt:  jwt.NewNumericDate(issueTime),
-		ExpiresAt: jwt.NewNumericDate(expireTime),
-		Subject:   userID.String(),
+	expireTime := issueTime.Add(options.ttl)
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    TOKEN_ISSUER,
+			Audience:  options.audience,
+			IssuedAt:  jwt.NewNumericDate(issueTime),
+			ExpiresAt: jwt.NewNumericDate(expireTime),
+			Subject:   userID.String(),
+		},
+		Scopes:    options.scopes,
+		Roles:     options.roles,
+		ChirpyRed: options.chirpyRed,
+		ClientID:  options.clientID,
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -52,24 +169,61 @@ func MakeJWT(userID uuid.UUID, tokenSecret string) (string, error) {
 	return signedToken, nil
 }
 
-func ValidateJWT(tokenString, tokenSecret string) (uuid.UUID, error) {
-	claims := &jwt.RegisteredClaims{}
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+type validateOptions struct {
+	audience []string
+}
+
+// ValidateOption changes what ValidateJWT expects of a token. Issuing options
+// (JWTOption) describe a token being made and aren't accepted here.
+type ValidateOption func(*validateOptions)
+
+// WithExpectedAudience replaces the default expected audience. The token must
+// be issued to every audience listed.
+func WithExpectedAudience(audience ...string) ValidateOption {
+	return func(o *validateOptions) {
+		o.audience = audience
+	}
+}
+
+// ValidateJWT verifies the signature, algorithm, issuer, audience and expiry
+// of tokenString and returns its claims. The expected audience defaults to
+// TOKEN_AUDIENCE and can be changed with WithExpectedAudience.
+func ValidateJWT(tokenString, tokenSecret string, opts ...ValidateOption) (*Claims, error) {
+	options := validateOptions{audience: []string{TOKEN_AUDIENCE}}
+	for _, opt := range opts {
+		opt(&options)
+	}
+	if len(options.audience) == 0 {
+		return nil, fmt.Errorf("No expected audience given")
+	}
+
+	parserOpts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(TOKEN_ISSUER),
+		jwt.WithExpirationRequired(),
+	}
+
+	claims := &Claims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
 		return []byte(tokenSecret), nil
-	})
+	}, parserOpts...)
 
 	if err != nil {
-		return uuid.UUID{}, err
+		return nil, err
+	}
+	for _, aud := range options.audience {
+		if !slices.Contains(claims.Audience, aud) {
+			return nil, fmt.Errorf("%w: not issued to %s", jwt.ErrTokenInvalidAudience, aud)
+		}
 	}
 
-	subject, err := token.Claims.GetSubject()
+	id, err := uuid.Parse(claims.Subject)
 	if err != nil {
-		log.Printf("Error getting token subject: %v\n", err)
-		return uuid.UUID{}, err
+		return nil, fmt.Errorf("Invalid token subject: %v", err)
 	}
+	claims.UserID = id
 
-	id := uuid.MustParse(subject)
-	return id, nil
+	return claims, nil
 }
 
 type HeaderNotFoundError struct {
@@ -113,6 +267,45 @@ func MakeRefreshToken() (string, error) {
 	return hex.EncodeToString(token), nil
 }
 
+const (
+	SCOPE_CHIRPS_READ  = "chirps:read"
+	SCOPE_CHIRPS_WRITE = "chirps:write"
+)
+
+// Scopes that may be granted to personal access tokens and OAuth clients,
+// with the description shown to users when they grant them.
+var Scopes = map[string]string{
+	SCOPE_CHIRPS_READ:  "Read chirps on your behalf",
+	SCOPE_CHIRPS_WRITE: "Post and delete chirps on your behalf",
+}
+
+const PAT_PREFIX = "chirpy_pat_"
+const PAT_LENGTH = 32
+
+// MakePersonalAccessToken returns a new personal access token along with the
+// hash that should be stored in its place.
+func MakePersonalAccessToken() (string, string, error) {
+	raw := make([]byte, PAT_LENGTH)
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", "", fmt.Errorf("Error creating personal access token: %v", err)
+	}
+
+	token := PAT_PREFIX + hex.EncodeToString(raw)
+	return token, HashToken(token), nil
+}
+
+func IsPersonalAccessToken(token string) bool {
+	return strings.HasPrefix(token, PAT_PREFIX)
+}
+
+// HashToken returns the hex encoded SHA-256 digest of a high-entropy token.
+// Unlike passwords, random tokens don't need a slow hash.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func GetAPIKey(headers http.Header) (string, error) {
 	authHeader := headers.Get("Authorization")
 	if authHeader == "" {
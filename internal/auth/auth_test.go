@@ -3,7 +3,9 @@ package auth
 import (
 	"net/http"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 )
 
@@ -38,12 +40,84 @@ func TestMakeJWT(t *testing.T) {
 }
 
 func TestValidateJWT(t *testing.T) {
-	retrievedId, err := ValidateJWT(token, secret)
+	claims, err := ValidateJWT(token, secret)
 	if err != nil {
 		t.Fatalf("Error validating JWT: %v\n", err)
 	}
-	if id != retrievedId {
-		t.Fatalf("IDs don't match: expected: %s got: %s\n", id, retrievedId)
+	if id != claims.UserID {
+		t.Fatalf("IDs don't match: expected: %s got: %s\n", id, claims.UserID)
+	}
+}
+
+func TestMakeJWTWithOptions(t *testing.T) {
+	token, err := MakeJWT(id, secret,
+		WithTTL(5*time.Minute),
+		WithScopes("chirps:read"),
+		WithRoles("admin"),
+		WithChirpyRed(true),
+	)
+	if err != nil {
+		t.Fatalf("Error making JWT: %v\n", err)
+	}
+
+	claims, err := ValidateJWT(token, secret)
+	if err != nil {
+		t.Fatalf("Error validating JWT: %v\n", err)
+	}
+	if !claims.HasScope("chirps:read") || !claims.HasRole("admin") || !claims.ChirpyRed {
+		t.Fatalf("Claims not carried through token: %+v\n", claims)
+	}
+	if ttl := claims.ExpiresAt.Sub(claims.IssuedAt.Time); ttl != 5*time.Minute {
+		t.Fatalf("Wrong token lifetime: expected %v got %v\n", 5*time.Minute, ttl)
+	}
+}
+
+func TestValidateJWTRejects(t *testing.T) {
+	expired, _ := MakeJWT(id, secret, WithTTL(time.Nanosecond))
+	wrongAudience, _ := MakeJWT(id, secret, WithAudience("someone-else"))
+	badSubject, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    TOKEN_ISSUER,
+		Audience:  []string{TOKEN_AUDIENCE},
+		Subject:   "not-a-uuid",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(secret))
+	wrongAlg, _ := jwt.NewWithClaims(jwt.SigningMethodHS512, jwt.RegisteredClaims{
+		Issuer:    TOKEN_ISSUER,
+		Audience:  []string{TOKEN_AUDIENCE},
+		Subject:   id.String(),
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(secret))
+	time.Sleep(time.Second)
+
+	cases := map[string]string{
+		"wrong secret":    token,
+		"expired":         expired,
+		"wrong audience":  wrongAudience,
+		"invalid subject": badSubject,
+		"wrong algorithm": wrongAlg,
+	}
+	for name, tok := range cases {
+		key := secret
+		if name == "wrong secret" {
+			key = "wrong"
+		}
+		if _, err := ValidateJWT(tok, key); err == nil {
+			t.Errorf("%s: expected token to be rejected\n", name)
+		}
+	}
+}
+
+func TestValidateJWTExpectedAudience(t *testing.T) {
+	both, _ := MakeJWT(id, secret, WithAudience(TOKEN_AUDIENCE, "chirpy-admin"))
+
+	if _, err := ValidateJWT(both, secret, WithExpectedAudience(TOKEN_AUDIENCE, "chirpy-admin")); err != nil {
+		t.Fatalf("Error validating JWT: %v\n", err)
+	}
+	if _, err := ValidateJWT(token, secret, WithExpectedAudience(TOKEN_AUDIENCE, "chirpy-admin")); err == nil {
+		t.Fatal("Expected a token missing an audience to be rejected")
+	}
+	if _, err := ValidateJWT(token, secret, WithExpectedAudience()); err == nil {
+		t.Fatal("Expected validation without an audience to fail")
 	}
 }
 
@@ -71,3 +145,43 @@ func TestMakeRefreshToken(t *testing.T) {
 		t.Fatal("Token should be 32 bytes (16 hex characters)")
 	}
 }
+
+func TestMakePersonalAccessToken(t *testing.T) {
+	token, hash, err := MakePersonalAccessToken()
+	if err != nil {
+		t.Fatalf("Error creating personal access token: %v\n", err)
+	}
+	if !IsPersonalAccessToken(token) {
+		t.Fatalf("Token %s is missing the %s prefix", token, PAT_PREFIX)
+	}
+	if hash == token || HashToken(token) != hash {
+		t.Fatal("Stored hash should be the SHA-256 digest of the token")
+	}
+}
+
+func TestClaimsAllows(t *testing.T) {
+	session := &Claims{}
+	if !session.Allows(SCOPE_CHIRPS_WRITE) {
+		t.Fatal("Session tokens without scopes should be unrestricted")
+	}
+
+	readOnly := &Claims{Scopes: []string{SCOPE_CHIRPS_READ}}
+	if readOnly.Allows(SCOPE_CHIRPS_WRITE) {
+		t.Fatal("Read-only token should not allow writes")
+	}
+}
+
+func TestVerifyPKCE(t *testing.T) {
+	verifier := "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	challenge := "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+
+	if PKCEChallenge(verifier) != challenge {
+		t.Fatalf("Wrong challenge: expected %s got %s", challenge, PKCEChallenge(verifier))
+	}
+	if !VerifyPKCE(verifier, challenge) {
+		t.Fatal("Verifier should match its challenge")
+	}
+	if VerifyPKCE(verifier[1:]+"x", challenge) {
+		t.Fatal("Wrong verifier should not match")
+	}
+}
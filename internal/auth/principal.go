@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TokenKind says what sort of access token authenticated a request.
+type TokenKind string
+
+const (
+	// A JWT issued to the user by a first-party login. Not restricted by
+	// scopes.
+	TOKEN_SESSION TokenKind = "session"
+	// A JWT issued to an OAuth client on the user's behalf.
+	TOKEN_OAUTH TokenKind = "oauth"
+	// A personal access token the user created.
+	TOKEN_PERSONAL TokenKind = "personal"
+)
+
+// Principal is the authenticated user making a request.
+type Principal struct {
+	UserID    uuid.UUID
+	Kind      TokenKind
+	Roles     []string
+	Scopes    []string
+	ClientID  string
+	ChirpyRed bool
+	// When the token was issued. Zero for personal access tokens.
+	IssuedAt time.Time
+}
+
+// PrincipalFromClaims returns the principal authenticated by a validated
+// JWT.
+func PrincipalFromClaims(claims *Claims) *Principal {
+	p := &Principal{
+		UserID:    claims.UserID,
+		Kind:      TOKEN_SESSION,
+		Roles:     claims.Roles,
+		Scopes:    claims.Scopes,
+		ClientID:  claims.ClientID,
+		ChirpyRed: claims.ChirpyRed,
+	}
+	if claims.Scopes != nil || claims.ClientID != "" {
+		p.Kind = TOKEN_OAUTH
+	}
+	if claims.IssuedAt != nil {
+		p.IssuedAt = claims.IssuedAt.Time
+	}
+	return p
+}
+
+// Allows reports whether the principal may take an action requiring scope.
+// Session tokens are not restricted.
+func (p *Principal) Allows(scope string) bool {
+	return p.Kind == TOKEN_SESSION || slices.Contains(p.Scopes, scope)
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return slices.Contains(p.Roles, role)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a copy of ctx carrying p.
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFrom returns the principal stored in ctx, or nil if the request
+// is anonymous.
+func PrincipalFrom(ctx context.Context) *Principal {
+	p, _ := ctx.Value(principalKey{}).(*Principal)
+	return p
+}
@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPrincipalFromClaims(t *testing.T) {
+	session := PrincipalFromClaims(&Claims{UserID: id})
+	if session.Kind != TOKEN_SESSION || session.UserID != id {
+		t.Fatalf("Expected a session principal for %s, got %+v\n", id, session)
+	}
+	if !session.Allows(SCOPE_CHIRPS_WRITE) {
+		t.Fatal("Session principals should be unrestricted")
+	}
+
+	oauth := PrincipalFromClaims(&Claims{UserID: id, ClientID: "client", Scopes: []string{SCOPE_CHIRPS_READ}})
+	if oauth.Kind != TOKEN_OAUTH {
+		t.Fatalf("Expected an OAuth principal, got %s\n", oauth.Kind)
+	}
+	if !oauth.Allows(SCOPE_CHIRPS_READ) || oauth.Allows(SCOPE_CHIRPS_WRITE) {
+		t.Fatal("OAuth principals should only allow their scopes")
+	}
+}
+
+func TestPrincipalContext(t *testing.T) {
+	if p := PrincipalFrom(context.Background()); p != nil {
+		t.Fatalf("Expected no principal, got %+v\n", p)
+	}
+
+	p := &Principal{UserID: id, Kind: TOKEN_PERSONAL}
+	if got := PrincipalFrom(WithPrincipal(context.Background(), p)); got != p {
+		t.Fatalf("Expected %+v, got %+v\n", p, got)
+	}
+}
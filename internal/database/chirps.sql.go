@@ -7,29 +7,75 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const countChirpsByAuthorSince = `-- name: CountChirpsByAuthorSince :one
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND status = 'published' AND created_at > $2
+`
+
+type CountChirpsByAuthorSinceParams struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Only published chirps count. Publishing a draft or scheduled chirp resets
+// its created_at, so it counts from when it was published.
+func (q *Queries) CountChirpsByAuthorSince(ctx context.Context, arg CountChirpsByAuthorSinceParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countChirpsByAuthorSince, arg.UserID, arg.CreatedAt)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id
 `
 
 type CreateChirpParams struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body       string        `json:"body"`
+	UserID     uuid.UUID     `json:"user_id"`
+	Status     string        `json:"status"`
+	PublishAt  sql.NullTime  `json:"publish_at"`
+	ReplyToID  uuid.NullUUID `json:"reply_to_id"`
+	ThreadID   uuid.NullUUID `json:"thread_id"`
+	Visibility string        `json:"visibility"`
+	Mentions   []uuid.UUID   `json:"mentions"`
+	QuoteID    uuid.NullUUID `json:"quote_id"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.Status,
+		arg.PublishAt,
+		arg.ReplyToID,
+		arg.ThreadID,
+		arg.Visibility,
+		pq.Array(arg.Mentions),
+		arg.QuoteID,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -37,6 +83,13 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.Visibility,
+		pq.Array(&i.Mentions),
+		&i.QuoteID,
 	)
 	return i, err
 }
@@ -51,7 +104,7 @@ func (q *Queries) DeleteChirpById(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirpById = `-- name: GetChirpById :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -63,16 +116,74 @@ func (q *Queries) GetChirpById(ctx context.Context, id uuid.UUID) (Chirp, error)
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.Visibility,
+		pq.Array(&i.Mentions),
+		&i.QuoteID,
 	)
 	return i, err
 }
 
+const getChirpThread = `-- name: GetChirpThread :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps
+WHERE (id = $1 OR thread_id = $1) AND status = 'published'
+    AND chirp_visible_to(id, $2::UUID)
+ORDER BY created_at ASC
+`
+
+type GetChirpThreadParams struct {
+	ID       uuid.UUID     `json:"id"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirpThread(ctx context.Context, arg GetChirpThreadParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpThread, arg.ID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.Visibility,
+			pq.Array(&i.Mentions),
+			&i.QuoteID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at ASC
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps
+WHERE status = 'published' AND chirp_visible_to(id, $1::UUID)
+ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+// Only chirps the viewer may see are returned. A NULL viewer is anonymous.
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -86,6 +197,13 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.Visibility,
+			pq.Array(&i.Mentions),
+			&i.QuoteID,
 		); err != nil {
 			return nil, err
 		}
@@ -101,13 +219,137 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsByAuthorId = `-- name: GetChirpsByAuthorId :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
-WHERE user_id = $1 
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps 
+WHERE user_id = $1 AND status = 'published' AND chirp_visible_to(id, $2::UUID)
 ORDER BY created_at ASC
 `
 
-func (q *Queries) GetChirpsByAuthorId(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorId, userID)
+type GetChirpsByAuthorIdParams struct {
+	UserID   uuid.UUID     `json:"user_id"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirpsByAuthorId(ctx context.Context, arg GetChirpsByAuthorIdParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsByAuthorId, arg.UserID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.Visibility,
+			pq.Array(&i.Mentions),
+			&i.QuoteID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUnpublishedChirpsByAuthor = `-- name: GetUnpublishedChirpsByAuthor :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps
+WHERE user_id = $1 AND status <> 'published'
+ORDER BY created_at DESC
+`
+
+func (q *Queries) GetUnpublishedChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUnpublishedChirpsByAuthor, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.Visibility,
+			pq.Array(&i.Mentions),
+			&i.QuoteID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVisibleChirpById = `-- name: GetVisibleChirpById :one
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps
+WHERE id = $1 AND status = 'published' AND chirp_visible_to(id, $2::UUID)
+`
+
+type GetVisibleChirpByIdParams struct {
+	ID       uuid.UUID     `json:"id"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetVisibleChirpById(ctx context.Context, arg GetVisibleChirpByIdParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirpById, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.Visibility,
+		pq.Array(&i.Mentions),
+		&i.QuoteID,
+	)
+	return i, err
+}
+
+const getVisibleChirpsByIds = `-- name: GetVisibleChirpsByIds :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps
+WHERE id = ANY($1::UUID[]) AND status = 'published'
+    AND chirp_visible_to(id, $2::UUID)
+`
+
+type GetVisibleChirpsByIdsParams struct {
+	Ids      []uuid.UUID   `json:"ids"`
+	ViewerID uuid.NullUUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetVisibleChirpsByIds(ctx context.Context, arg GetVisibleChirpsByIdsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getVisibleChirpsByIds, pq.Array(arg.Ids), arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -121,6 +363,13 @@ func (q *Queries) GetChirpsByAuthorId(ctx context.Context, userID uuid.UUID) ([]
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.Visibility,
+			pq.Array(&i.Mentions),
+			&i.QuoteID,
 		); err != nil {
 			return nil, err
 		}
@@ -134,3 +383,167 @@ func (q *Queries) GetChirpsByAuthorId(ctx context.Context, userID uuid.UUID) ([]
 	}
 	return items, nil
 }
+
+const publishChirp = `-- name: PublishChirp :one
+UPDATE chirps
+SET status = 'published', publish_at = NOW(), created_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status <> 'published'
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id
+`
+
+func (q *Queries) PublishChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, publishChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.Visibility,
+		pq.Array(&i.Mentions),
+		&i.QuoteID,
+	)
+	return i, err
+}
+
+const lockDueChirps = `-- name: LockDueChirps :many
+SELECT id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id FROM chirps
+WHERE status = 'scheduled' AND publish_at <= NOW()
+ORDER BY publish_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED
+`
+
+// Locks the scheduled chirps that are due, oldest first, until the
+// transaction ends. Chirps another publisher has locked are skipped.
+func (q *Queries) LockDueChirps(ctx context.Context, limit int32) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, lockDueChirps, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.Status,
+			&i.PublishAt,
+			&i.ReplyToID,
+			&i.ThreadID,
+			&i.Visibility,
+			pq.Array(&i.Mentions),
+			&i.QuoteID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const scheduleChirp = `-- name: ScheduleChirp :one
+UPDATE chirps
+SET status = 'scheduled', publish_at = $2, updated_at = NOW()
+WHERE id = $1 AND status <> 'published'
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id
+`
+
+type ScheduleChirpParams struct {
+	ID        uuid.UUID    `json:"id"`
+	PublishAt sql.NullTime `json:"publish_at"`
+}
+
+func (q *Queries) ScheduleChirp(ctx context.Context, arg ScheduleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, scheduleChirp, arg.ID, arg.PublishAt)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.Visibility,
+		pq.Array(&i.Mentions),
+		&i.QuoteID,
+	)
+	return i, err
+}
+
+const unscheduleChirp = `-- name: UnscheduleChirp :one
+UPDATE chirps
+SET status = 'draft', publish_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'scheduled'
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id
+`
+
+func (q *Queries) UnscheduleChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, unscheduleChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.Visibility,
+		pq.Array(&i.Mentions),
+		&i.QuoteID,
+	)
+	return i, err
+}
+
+const updateChirpBody = `-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id
+`
+
+type UpdateChirpBodyParams struct {
+	ID   uuid.UUID `json:"id"`
+	Body string    `json:"body"`
+}
+
+func (q *Queries) UpdateChirpBody(ctx context.Context, arg UpdateChirpBodyParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, updateChirpBody, arg.ID, arg.Body)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.Status,
+		&i.PublishAt,
+		&i.ReplyToID,
+		&i.ThreadID,
+		&i.Visibility,
+		pq.Array(&i.Mentions),
+		&i.QuoteID,
+	)
+	return i, err
+}
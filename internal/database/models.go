@@ -6,33 +6,292 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Block struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID `json:"user_id"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Chirp struct {
+	ID         uuid.UUID     `json:"id"`
+	CreatedAt  time.Time     `json:"created_at"`
+	UpdatedAt  time.Time     `json:"updated_at"`
+	Body       string        `json:"body"`
+	UserID     uuid.UUID     `json:"user_id"`
+	Status     string        `json:"status"`
+	PublishAt  sql.NullTime  `json:"publish_at"`
+	ReplyToID  uuid.NullUUID `json:"reply_to_id"`
+	ThreadID   uuid.NullUUID `json:"thread_id"`
+	Visibility string        `json:"visibility"`
+	Mentions   []uuid.UUID   `json:"mentions"`
+	QuoteID    uuid.NullUUID `json:"quote_id"`
+}
+
+type ChirpEvent struct {
+	ID         int64       `json:"id"`
+	CreatedAt  time.Time   `json:"created_at"`
+	Event      string      `json:"event"`
+	ChirpID    uuid.UUID   `json:"chirp_id"`
+	UserID     uuid.UUID   `json:"user_id"`
+	ThreadID   uuid.UUID   `json:"thread_id"`
+	Tags       []string    `json:"tags"`
+	Visibility string      `json:"visibility"`
+	Mentions   []uuid.UUID `json:"mentions"`
+}
+
+type ChirpImpression struct {
+	ChirpID     uuid.UUID `json:"chirp_id"`
+	Day         time.Time `json:"day"`
+	Impressions int64     `json:"impressions"`
+}
+
+type Conversation struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	CreatedBy uuid.NullUUID `json:"created_by"`
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID    `json:"conversation_id"`
+	UserID         uuid.UUID    `json:"user_id"`
+	JoinedAt       time.Time    `json:"joined_at"`
+	LastReadAt     sql.NullTime `json:"last_read_at"`
+}
+
+type DataExport struct {
+	ID          uuid.UUID      `json:"id"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	UserID      uuid.UUID      `json:"user_id"`
+	Status      string         `json:"status"`
+	Archive     []byte         `json:"archive"`
+	Error       sql.NullString `json:"error"`
+	CompletedAt sql.NullTime   `json:"completed_at"`
+	ExpiresAt   sql.NullTime   `json:"expires_at"`
+}
+
+type DirectMessage struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+type ExternalIdentity struct {
 	ID        uuid.UUID `json:"id"`
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
 	UserID    uuid.UUID `json:"user_id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+}
+
+type Follow struct {
+	FollowerID uuid.UUID `json:"follower_id"`
+	FolloweeID uuid.UUID `json:"followee_id"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type FollowRequest struct {
+	RequesterID uuid.UUID `json:"requester_id"`
+	TargetID    uuid.UUID `json:"target_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+type LinkPreview struct {
+	Url         string       `json:"url"`
+	CreatedAt   time.Time    `json:"created_at"`
+	Status      string       `json:"status"`
+	LeaseUntil  sql.NullTime `json:"lease_until"`
+	FetchedAt   sql.NullTime `json:"fetched_at"`
+	Title       string       `json:"title"`
+	Description string       `json:"description"`
+	ImageUrl    string       `json:"image_url"`
+	SiteName    string       `json:"site_name"`
+}
+
+type Mute struct {
+	MuterID   uuid.UUID `json:"muter_id"`
+	MutedID   uuid.UUID `json:"muted_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Notification struct {
+	ID        int64         `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	Type      string        `json:"type"`
+	ActorID   uuid.UUID     `json:"actor_id"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+}
+
+type OauthAuthorizationCode struct {
+	CodeHash      string       `json:"code_hash"`
+	CreatedAt     time.Time    `json:"created_at"`
+	ClientID      uuid.UUID    `json:"client_id"`
+	UserID        uuid.UUID    `json:"user_id"`
+	RedirectUri   string       `json:"redirect_uri"`
+	Scopes        []string     `json:"scopes"`
+	CodeChallenge string       `json:"code_challenge"`
+	ExpiresAt     time.Time    `json:"expires_at"`
+	UsedAt        sql.NullTime `json:"used_at"`
+}
+
+type OauthClient struct {
+	ID           uuid.UUID      `json:"id"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	UserID       uuid.UUID      `json:"user_id"`
+	Name         string         `json:"name"`
+	SecretHash   sql.NullString `json:"secret_hash"`
+	RedirectUris []string       `json:"redirect_uris"`
+	Scopes       []string       `json:"scopes"`
+}
+
+type OutboundWebhook struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	UpdatedAt     time.Time       `json:"updated_at"`
+	EndpointID    uuid.UUID       `json:"endpoint_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     sql.NullString  `json:"last_error"`
+	DeliveredAt   sql.NullTime    `json:"delivered_at"`
+}
+
+type OutboundWebhookAttempt struct {
+	ID                uuid.UUID      `json:"id"`
+	CreatedAt         time.Time      `json:"created_at"`
+	OutboundWebhookID uuid.UUID      `json:"outbound_webhook_id"`
+	Attempt           int32          `json:"attempt"`
+	StatusCode        sql.NullInt32  `json:"status_code"`
+	Error             sql.NullString `json:"error"`
+	DurationMs        int32          `json:"duration_ms"`
+}
+
+type PersonalAccessToken struct {
+	ID         uuid.UUID    `json:"id"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+	UserID     uuid.UUID    `json:"user_id"`
+	Name       string       `json:"name"`
+	TokenHash  string       `json:"token_hash"`
+	Scopes     []string     `json:"scopes"`
+	ExpiresAt  sql.NullTime `json:"expires_at"`
+	LastUsedAt sql.NullTime `json:"last_used_at"`
+	RevokedAt  sql.NullTime `json:"revoked_at"`
+}
+
+type PinnedChirp struct {
+	UserID    uuid.UUID `json:"user_id"`
+	Position  int16     `json:"position"`
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PolkaEvent struct {
+	EventID    string    `json:"event_id"`
+	Event      string    `json:"event"`
+	ReceivedAt time.Time `json:"received_at"`
+}
+
+type Poll struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+	ClosesAt  time.Time `json:"closes_at"`
+}
+
+type PollOption struct {
+	ChirpID  uuid.UUID `json:"chirp_id"`
+	Position int16     `json:"position"`
+	Text     string    `json:"text"`
+}
+
+type PollVote struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Position  int16     `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type RefreshToken struct {
-	Token     string       `json:"token"`
-	CreatedAt time.Time    `json:"created_at"`
-	UpdatedAt time.Time    `json:"updated_at"`
-	UserID    uuid.UUID    `json:"user_id"`
-	ExpiresAt time.Time    `json:"expires_at"`
-	RevokedAt sql.NullTime `json:"revoked_at"`
+	Token     string        `json:"token"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ExpiresAt time.Time     `json:"expires_at"`
+	RevokedAt sql.NullTime  `json:"revoked_at"`
+	ClientID  uuid.NullUUID `json:"client_id"`
+	Scopes    []string      `json:"scopes"`
+}
+
+type Subscription struct {
+	UserID           uuid.UUID `json:"user_id"`
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+	Status           string    `json:"status"`
+	CurrentPeriodEnd time.Time `json:"current_period_end"`
+}
+
+type SubscriptionEvent struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Event     string    `json:"event"`
+	Status    string    `json:"status"`
+	PeriodEnd time.Time `json:"period_end"`
 }
 
 type User struct {
-	ID             uuid.UUID `json:"id"`
-	CreatedAt      time.Time `json:"created_at"`
-	UpdatedAt      time.Time `json:"updated_at"`
-	Email          string    `json:"email"`
-	HashedPassword string    `json:"hashed_password"`
-	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	ID                  uuid.UUID    `json:"id"`
+	CreatedAt           time.Time    `json:"created_at"`
+	UpdatedAt           time.Time    `json:"updated_at"`
+	Email               string       `json:"email"`
+	HashedPassword      string       `json:"hashed_password"`
+	IsChirpyRed         bool         `json:"is_chirpy_red"`
+	DeletionScheduledAt sql.NullTime `json:"deletion_scheduled_at"`
+	DmPolicy            string       `json:"dm_policy"`
+	Protected           bool         `json:"protected"`
+}
+
+type WebhookDelivery struct {
+	ID          uuid.UUID       `json:"id"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Source      string          `json:"source"`
+	Headers     json.RawMessage `json:"headers"`
+	Body        []byte          `json:"body"`
+	Status      string          `json:"status"`
+	EventID     sql.NullString  `json:"event_id"`
+	Event       sql.NullString  `json:"event"`
+	Error       sql.NullString  `json:"error"`
+	ProcessedAt sql.NullTime    `json:"processed_at"`
+	ReplayCount int32           `json:"replay_count"`
+}
+
+type WebhookEndpoint struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Url       string    `json:"url"`
+	Secret    string    `json:"secret"`
+	Events    []string  `json:"events"`
 }
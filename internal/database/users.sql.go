@@ -11,8 +11,47 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const cancelUserDeletion = `-- name: CancelUserDeletion :execrows
+UPDATE users
+SET deletion_scheduled_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deletion_scheduled_at IS NOT NULL
+`
+
+func (q *Queries) CancelUserDeletion(ctx context.Context, id uuid.UUID) (int64, error) {
+	result, err := q.db.ExecContext(ctx, cancelUserDeletion, id)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const countMentionableUsers = `-- name: CountMentionableUsers :one
+SELECT COUNT(*) FROM users
+WHERE id = ANY($1::UUID[])
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocker_id = users.id AND blocked_id = $2)
+            OR (blocker_id = $2 AND blocked_id = users.id)
+    )
+`
+
+type CountMentionableUsersParams struct {
+	Ids    []uuid.UUID `json:"ids"`
+	UserID uuid.UUID   `json:"user_id"`
+}
+
+// Counts the users in ids that exist and aren't on either side of a block
+// with user_id.
+func (q *Queries) CountMentionableUsers(ctx context.Context, arg CountMentionableUsersParams) (int64, error) {
+	row := q.db.QueryRowContext(ctx, countMentionableUsers, pq.Array(arg.Ids), arg.UserID)
+	var count int64
+	err := row.Scan(&count)
+	return count, err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users(id, created_at, updated_at, email, hashed_password)
 VALUES (
@@ -22,7 +61,7 @@ VALUES (
     $1,
     $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_scheduled_at, dm_policy, protected
 `
 
 type CreateUserParams struct {
@@ -40,6 +79,9 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletionScheduledAt,
+		&i.DmPolicy,
+		&i.Protected,
 	)
 	return i, err
 }
@@ -52,8 +94,21 @@ func (q *Queries) DeleteUsers(ctx context.Context) (sql.Result, error) {
 	return q.db.ExecContext(ctx, deleteUsers)
 }
 
+const deleteUsersPastGracePeriod = `-- name: DeleteUsersPastGracePeriod :execrows
+DELETE FROM users
+WHERE deletion_scheduled_at <= NOW()
+`
+
+func (q *Queries) DeleteUsersPastGracePeriod(ctx context.Context) (int64, error) {
+	result, err := q.db.ExecContext(ctx, deleteUsersPastGracePeriod)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const getUserByEmail = `-- name: GetUserByEmail :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red from users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_scheduled_at, dm_policy, protected from users
 WHERE email = $1
 `
 
@@ -67,12 +122,15 @@ func (q *Queries) GetUserByEmail(ctx context.Context, email string) (User, error
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletionScheduledAt,
+		&i.DmPolicy,
+		&i.Protected,
 	)
 	return i, err
 }
 
 const getUserByID = `-- name: GetUserByID :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red from users
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_scheduled_at, dm_policy, protected from users
 WHERE ID = $1
 `
 
@@ -86,10 +144,90 @@ func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.DeletionScheduledAt,
+		&i.DmPolicy,
+		&i.Protected,
 	)
 	return i, err
 }
 
+const scheduleUserDeletion = `-- name: ScheduleUserDeletion :one
+UPDATE users
+SET deletion_scheduled_at = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, deletion_scheduled_at, dm_policy, protected
+`
+
+type ScheduleUserDeletionParams struct {
+	ID                  uuid.UUID    `json:"id"`
+	DeletionScheduledAt sql.NullTime `json:"deletion_scheduled_at"`
+}
+
+func (q *Queries) ScheduleUserDeletion(ctx context.Context, arg ScheduleUserDeletionParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, scheduleUserDeletion, arg.ID, arg.DeletionScheduledAt)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.DeletionScheduledAt,
+		&i.DmPolicy,
+		&i.Protected,
+	)
+	return i, err
+}
+
+const setChirpyRed = `-- name: SetChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetChirpyRedParams struct {
+	ID          uuid.UUID `json:"id"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+}
+
+func (q *Queries) SetChirpyRed(ctx context.Context, arg SetChirpyRedParams) error {
+	_, err := q.db.ExecContext(ctx, setChirpyRed, arg.ID, arg.IsChirpyRed)
+	return err
+}
+
+const setDMPolicy = `-- name: SetDMPolicy :exec
+UPDATE users
+SET dm_policy = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetDMPolicyParams struct {
+	ID       uuid.UUID `json:"id"`
+	DmPolicy string    `json:"dm_policy"`
+}
+
+func (q *Queries) SetDMPolicy(ctx context.Context, arg SetDMPolicyParams) error {
+	_, err := q.db.ExecContext(ctx, setDMPolicy, arg.ID, arg.DmPolicy)
+	return err
+}
+
+const setProtected = `-- name: SetProtected :exec
+UPDATE users
+SET protected = $2, updated_at = NOW()
+WHERE id = $1
+`
+
+type SetProtectedParams struct {
+	ID        uuid.UUID `json:"id"`
+	Protected bool      `json:"protected"`
+}
+
+func (q *Queries) SetProtected(ctx context.Context, arg SetProtectedParams) error {
+	_, err := q.db.ExecContext(ctx, setProtected, arg.ID, arg.Protected)
+	return err
+}
+
 const updateUsernamePassword = `-- name: UpdateUsernamePassword :one
 UPDATE users
 SET email = $2, hashed_password = $3, updated_at = NOW()
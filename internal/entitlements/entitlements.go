@@ -0,0 +1,152 @@
+// Package entitlements decides which premium features a user's plan
+// includes. Handlers look up the user's Plan and ask it for what they need
+// rather than checking is_chirpy_red themselves.
+package entitlements
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+)
+
+type Entitlement string
+
+const (
+	EDIT_CHIRPS     Entitlement = "edit_chirps"
+	SCHEDULE_CHIRPS Entitlement = "schedule_chirps"
+	LONG_CHIRPS     Entitlement = "long_chirps"
+	HIGH_RATE_LIMIT Entitlement = "high_rate_limit"
+	LONG_ANALYTICS  Entitlement = "long_analytics"
+)
+
+// Descriptions are phrased to complete "... requires Chirpy Red."
+var Descriptions = map[Entitlement]string{
+	EDIT_CHIRPS:     "Editing chirps",
+	SCHEDULE_CHIRPS: "Scheduling chirps",
+	LONG_CHIRPS:     "Posting long chirps",
+	HIGH_RATE_LIMIT: "A higher posting limit",
+	LONG_ANALYTICS:  "A longer analytics history",
+}
+
+type Plan struct {
+	Name         string
+	DisplayName  string
+	Entitlements []Entitlement
+	// Limits that differ between plans.
+	MaxChirpLength int
+	ChirpsPerHour  int
+	// How many days of chirp analytics authors can look back over.
+	AnalyticsDays int
+}
+
+var FREE = Plan{
+	Name:           "free",
+	DisplayName:    "Chirpy",
+	MaxChirpLength: 140,
+	ChirpsPerHour:  30,
+	AnalyticsDays:  7,
+}
+
+var CHIRPY_RED = Plan{
+	Name:        "chirpy_red",
+	DisplayName: "Chirpy Red",
+	Entitlements: []Entitlement{
+		EDIT_CHIRPS,
+		SCHEDULE_CHIRPS,
+		LONG_CHIRPS,
+		HIGH_RATE_LIMIT,
+		LONG_ANALYTICS,
+	},
+	MaxChirpLength: 280,
+	ChirpsPerHour:  300,
+	AnalyticsDays:  90,
+}
+
+// Plans from cheapest to most expensive.
+var Plans = []Plan{FREE, CHIRPY_RED}
+
+// ForUser returns the plan a user is on.
+func ForUser(isChirpyRed bool) Plan {
+	if isChirpyRed {
+		return CHIRPY_RED
+	}
+	return FREE
+}
+
+func (p Plan) Has(e Entitlement) bool {
+	return slices.Contains(p.Entitlements, e)
+}
+
+// Require returns a MissingError if p doesn't include e.
+func (p Plan) Require(e Entitlement) error {
+	if p.Has(e) {
+		return nil
+	}
+
+	err := MissingError{Entitlement: e, Plan: p.Name}
+	for _, plan := range Plans {
+		if plan.Has(e) {
+			err.UpgradeTo = &plan
+			break
+		}
+	}
+	return err
+}
+
+// CheckChirpLength returns a MissingError if a chirp of length n is longer
+// than p allows but a plan with LONG_CHIRPS would allow it. Chirps too long
+// for any plan are the caller's to reject.
+func (p Plan) CheckChirpLength(n int) error {
+	if n <= p.MaxChirpLength {
+		return nil
+	}
+	for _, plan := range Plans {
+		if plan.Has(LONG_CHIRPS) && n <= plan.MaxChirpLength {
+			return p.Require(LONG_CHIRPS)
+		}
+	}
+	return nil
+}
+
+// CheckAnalyticsDays returns a MissingError if looking back n days is
+// further than p allows but a plan with LONG_ANALYTICS would allow it.
+// Histories too long for any plan are the caller's to reject.
+func (p Plan) CheckAnalyticsDays(n int) error {
+	if n <= p.AnalyticsDays {
+		return nil
+	}
+	for _, plan := range Plans {
+		if plan.Has(LONG_ANALYTICS) && n <= plan.AnalyticsDays {
+			return p.Require(LONG_ANALYTICS)
+		}
+	}
+	return nil
+}
+
+// MissingError reports that a user's plan doesn't include an entitlement.
+type MissingError struct {
+	Entitlement Entitlement
+	Plan        string
+	// The cheapest plan that includes the entitlement, if any.
+	UpgradeTo *Plan
+}
+
+func (e MissingError) Error() string {
+	feature := Descriptions[e.Entitlement]
+	if feature == "" {
+		feature = string(e.Entitlement)
+	}
+	if e.UpgradeTo == nil {
+		return fmt.Sprintf("%s is not available on your plan.", feature)
+	}
+	return fmt.Sprintf("%s requires %s.", feature, e.UpgradeTo.DisplayName)
+}
+
+// StatusCode is 402 Payment Required when upgrading would grant the
+// entitlement and 403 Forbidden when no plan does.
+func (e MissingError) StatusCode() int {
+	if e.UpgradeTo == nil {
+		return http.StatusForbidden
+	}
+	return http.StatusPaymentRequired
+}
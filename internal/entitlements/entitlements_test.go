@@ -0,0 +1,90 @@
+package entitlements
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestForUser(t *testing.T) {
+	if ForUser(false).Name != FREE.Name || ForUser(true).Name != CHIRPY_RED.Name {
+		t.Fatal("Expected free users on FREE and red users on CHIRPY_RED")
+	}
+	if ForUser(false).ChirpsPerHour >= ForUser(true).ChirpsPerHour {
+		t.Fatal("Expected Chirpy Red to have a higher rate limit")
+	}
+}
+
+func TestRequire(t *testing.T) {
+	for e := range Descriptions {
+		if err := CHIRPY_RED.Require(e); err != nil {
+			t.Errorf("Expected Chirpy Red to include %s: %v", e, err)
+		}
+
+		err := FREE.Require(e)
+		var missing MissingError
+		if !errors.As(err, &missing) {
+			t.Fatalf("Expected MissingError for %s, got %v", e, err)
+		}
+		if missing.StatusCode() != http.StatusPaymentRequired || missing.UpgradeTo.Name != CHIRPY_RED.Name {
+			t.Errorf("Expected %s to be available by upgrading to Chirpy Red", e)
+		}
+		if missing.Error() != Descriptions[e]+" requires Chirpy Red." {
+			t.Errorf("Unexpected message: %s", missing.Error())
+		}
+	}
+}
+
+func TestRequireUnavailable(t *testing.T) {
+	err := CHIRPY_RED.Require("time_travel")
+	var missing MissingError
+	if !errors.As(err, &missing) || missing.StatusCode() != http.StatusForbidden {
+		t.Fatalf("Expected 403 for an entitlement no plan has, got %v", err)
+	}
+}
+
+func TestCheckChirpLength(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    Plan
+		length  int
+		missing bool
+	}{
+		{"free within limit", FREE, 140, false},
+		{"free over limit", FREE, 141, true},
+		{"red long chirp", CHIRPY_RED, 280, false},
+		{"too long for any plan", FREE, 281, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.plan.CheckChirpLength(tc.length)
+			if (err != nil) != tc.missing {
+				t.Fatalf("CheckChirpLength(%d) = %v, want missing=%v", tc.length, err, tc.missing)
+			}
+		})
+	}
+}
+
+func TestCheckAnalyticsDays(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    Plan
+		days    int
+		missing bool
+	}{
+		{"free within limit", FREE, 7, false},
+		{"free over limit", FREE, 8, true},
+		{"red long history", CHIRPY_RED, 90, false},
+		{"too long for any plan", FREE, 91, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.plan.CheckAnalyticsDays(tc.days)
+			if (err != nil) != tc.missing {
+				t.Fatalf("CheckAnalyticsDays(%d) = %v, want missing=%v", tc.days, err, tc.missing)
+			}
+		})
+	}
+}
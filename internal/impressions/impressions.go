@@ -0,0 +1,84 @@
+// Package impressions counts how often chirps are shown. Counts are buffered
+// in memory and written out in batches, so that serving chirps doesn't turn
+// every read into a database write.
+package impressions
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DEFAULT_MAX_PENDING caps how many chirp-days a Counter buffers. Impressions
+// of chirp-days past the cap are dropped until the next flush.
+const DEFAULT_MAX_PENDING = 100_000
+
+// Key identifies one chirp's impressions on one UTC day.
+type Key struct {
+	ChirpID uuid.UUID
+	Day     time.Time
+}
+
+type Counter struct {
+	// Zero means DEFAULT_MAX_PENDING.
+	MaxPending int
+	// Defaults to time.Now.
+	Now func() time.Time
+
+	mu     sync.Mutex
+	counts map[Key]int64
+}
+
+// Day returns the UTC day t falls on, as midnight UTC.
+func Day(t time.Time) time.Time {
+	return t.UTC().Truncate(24 * time.Hour)
+}
+
+// Record counts one impression of each chirp.
+func (c *Counter) Record(chirpIDs ...uuid.UUID) {
+	now := time.Now
+	if c.Now != nil {
+		now = c.Now
+	}
+	day := Day(now())
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, id := range chirpIDs {
+		c.add(Key{ChirpID: id, Day: day}, 1)
+	}
+}
+
+// Take returns the buffered counts and empties the buffer.
+func (c *Counter) Take() map[Key]int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	counts := c.counts
+	c.counts = nil
+	return counts
+}
+
+// Restore puts back counts that couldn't be written, so that they are tried
+// again on the next flush.
+func (c *Counter) Restore(counts map[Key]int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for k, n := range counts {
+		c.add(k, n)
+	}
+}
+
+func (c *Counter) add(k Key, n int64) {
+	if c.counts == nil {
+		c.counts = map[Key]int64{}
+	}
+	max := c.MaxPending
+	if max == 0 {
+		max = DEFAULT_MAX_PENDING
+	}
+	if _, ok := c.counts[k]; !ok && len(c.counts) >= max {
+		return
+	}
+	c.counts[k] += n
+}
@@ -0,0 +1,64 @@
+package impressions
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestRecordAndTake(t *testing.T) {
+	now := time.Date(2026, 3, 4, 23, 30, 0, 0, time.UTC)
+	c := &Counter{Now: func() time.Time { return now }}
+	a, b := uuid.New(), uuid.New()
+
+	c.Record(a, b)
+	c.Record(a)
+	now = now.Add(time.Hour)
+	c.Record(a)
+
+	counts := c.Take()
+	day := time.Date(2026, 3, 4, 0, 0, 0, 0, time.UTC)
+	next := day.Add(24 * time.Hour)
+	if counts[Key{a, day}] != 2 || counts[Key{b, day}] != 1 || counts[Key{a, next}] != 1 || len(counts) != 3 {
+		t.Fatalf("Unexpected counts %v", counts)
+	}
+
+	if counts := c.Take(); len(counts) != 0 {
+		t.Fatalf("Expected Take to empty the buffer, got %v", counts)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	c := &Counter{}
+	id := uuid.New()
+	c.Record(id)
+	failed := c.Take()
+
+	c.Record(id)
+	c.Restore(failed)
+
+	if n := c.Take()[Key{id, Day(time.Now())}]; n != 2 {
+		t.Fatalf("Expected restored counts to be added back, got %d", n)
+	}
+}
+
+func TestMaxPending(t *testing.T) {
+	c := &Counter{MaxPending: 1}
+	a, b := uuid.New(), uuid.New()
+	c.Record(a, b, a)
+
+	counts := c.Take()
+	if len(counts) != 1 || counts[Key{a, Day(time.Now())}] != 2 {
+		t.Fatalf("Expected only the first chirp to be counted, got %v", counts)
+	}
+}
+
+func TestDay(t *testing.T) {
+	loc := time.FixedZone("UTC-5", -5*60*60)
+	got := Day(time.Date(2026, 3, 4, 22, 0, 0, 0, loc))
+	want := time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC)
+	if !got.Equal(want) {
+		t.Fatalf("Expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,194 @@
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const clientID = "chirpy"
+const clientSecret = "shh"
+const redirectURL = "http://localhost:8080/api/oidc/callback"
+
+// mockIdP is a minimal OpenID provider that issues an ID token for a fixed
+// user whenever the code "good-code" is redeemed.
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	nonce  string
+	// How many times the key set has been fetched.
+	keyFetches atomic.Int32
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("Error generating key: %v\n", err)
+	}
+
+	idp := &mockIdP{key: key}
+	mux := http.NewServeMux()
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+
+	mux.HandleFunc("GET "+DISCOVERY_PATH, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 idp.server.URL,
+			"authorization_endpoint": idp.server.URL + "/authorize",
+			"token_endpoint":         idp.server.URL + "/token",
+			"jwks_uri":               idp.server.URL + "/jwks",
+		})
+	})
+
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		idp.keyFetches.Add(1)
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "test-key",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+
+	mux.HandleFunc("POST /token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, ok := r.BasicAuth()
+		if !ok || id != clientID || secret != clientSecret {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.FormValue("code") != "good-code" || r.FormValue("code_verifier") == "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"access_token": "unused",
+			"id_token":     idp.idToken(t, idp.nonce),
+		})
+	})
+
+	return idp
+}
+
+func (idp *mockIdP) idToken(t *testing.T, nonce string) string {
+	return idp.idTokenWithKey(t, nonce, "test-key")
+}
+
+// idTokenWithKey returns an ID token whose header names kid as its key.
+func (idp *mockIdP) idTokenWithKey(t *testing.T, nonce, kid string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, idTokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    idp.server.URL,
+			Subject:   "user-123",
+			Audience:  []string{clientID},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute)),
+		},
+		Nonce:         nonce,
+		Email:         "joe.mama@gotem.com",
+		EmailVerified: true,
+	})
+	token.Header["kid"] = kid
+
+	signed, err := token.SignedString(idp.key)
+	if err != nil {
+		t.Fatalf("Error signing ID token: %v\n", err)
+	}
+	return signed
+}
+
+func newTestProvider(t *testing.T, idp *mockIdP) *Provider {
+	p, err := NewProvider(context.Background(), Config{
+		Issuer:       idp.server.URL,
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		RedirectURL:  redirectURL,
+	})
+	if err != nil {
+		t.Fatalf("Error discovering provider: %v\n", err)
+	}
+	return p
+}
+
+func TestAuthCodeURL(t *testing.T) {
+	idp := newMockIdP(t)
+	p := newTestProvider(t, idp)
+
+	authURL, err := url.Parse(p.AuthCodeURL("state", "nonce", "challenge"))
+	if err != nil {
+		t.Fatalf("Error parsing auth URL: %v\n", err)
+	}
+
+	query := authURL.Query()
+	if query.Get("client_id") != clientID || query.Get("redirect_uri") != redirectURL {
+		t.Fatalf("Auth URL is missing client parameters: %s", authURL)
+	}
+	if query.Get("scope") != "openid email" || query.Get("code_challenge_method") != "S256" {
+		t.Fatalf("Auth URL has wrong scope or PKCE method: %s", authURL)
+	}
+}
+
+func TestExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	p := newTestProvider(t, idp)
+	idp.nonce = "expected-nonce"
+
+	idToken, err := p.Exchange(context.Background(), "good-code", "verifier", "expected-nonce")
+	if err != nil {
+		t.Fatalf("Error exchanging code: %v\n", err)
+	}
+	if idToken.Subject != "user-123" || idToken.Email != "joe.mama@gotem.com" || !idToken.EmailVerified {
+		t.Fatalf("Unexpected ID token claims: %+v", idToken)
+	}
+
+	_, err = p.Exchange(context.Background(), "bad-code", "verifier", "expected-nonce")
+	if err == nil {
+		t.Fatal("Expected bad code to be rejected")
+	}
+}
+
+func TestVerifyIDTokenRejectsWrongNonce(t *testing.T) {
+	idp := newMockIdP(t)
+	p := newTestProvider(t, idp)
+
+	_, err := p.VerifyIDToken(context.Background(), idp.idToken(t, "replayed"), "expected-nonce")
+	if err == nil {
+		t.Fatal("Expected nonce mismatch to be rejected")
+	}
+}
+
+func TestVerifyIDTokenLimitsKeyRefreshes(t *testing.T) {
+	idp := newMockIdP(t)
+	p := newTestProvider(t, idp)
+
+	for range 3 {
+		_, err := p.VerifyIDToken(context.Background(), idp.idTokenWithKey(t, "nonce", "unknown-key"), "nonce")
+		if err == nil {
+			t.Fatal("Expected a token signed with an unknown key to be rejected")
+		}
+	}
+	if n := idp.keyFetches.Load(); n != 1 {
+		t.Fatalf("Expected the key set to be fetched once, got %d\n", n)
+	}
+
+	// Keys fetched for the unknown one are still used.
+	_, err := p.VerifyIDToken(context.Background(), idp.idToken(t, "nonce"), "nonce")
+	if err != nil {
+		t.Fatalf("Error verifying ID token: %v\n", err)
+	}
+	if n := idp.keyFetches.Load(); n != 1 {
+		t.Fatalf("Expected no more fetches, got %d\n", n)
+	}
+}
@@ -0,0 +1,76 @@
+package request
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PathUUID parses the path parameter name as a UUID.
+func PathUUID(r *http.Request, name string) (uuid.UUID, error) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		return uuid.UUID{}, fieldError(name, "must be a UUID")
+	}
+	return id, nil
+}
+
+// QueryUUID parses the query parameter name as a UUID. The result is not
+// valid if the parameter is absent.
+func QueryUUID(r *http.Request, name string) (uuid.NullUUID, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.Parse(raw)
+	if err != nil {
+		return uuid.NullUUID{}, fieldError(name, "must be a UUID")
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// QueryInt parses the query parameter name as an integer between min and
+// max, returning def if it is absent.
+func QueryInt(r *http.Request, name string, def, min, max int) (int, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < min || n > max {
+		return 0, fieldError(name, fmt.Sprintf("must be between %d and %d", min, max))
+	}
+	return n, nil
+}
+
+// QueryTime parses the query parameter name as an RFC 3339 timestamp,
+// returning def if it is absent.
+func QueryTime(r *http.Request, name string, def time.Time) (time.Time, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, raw)
+	if err != nil {
+		return time.Time{}, fieldError(name, "must be an RFC 3339 timestamp")
+	}
+	return t.UTC(), nil
+}
+
+// QueryEnum returns the query parameter name, which must be one of allowed,
+// or def if it is absent.
+func QueryEnum(r *http.Request, name, def string, allowed ...string) (string, error) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return def, nil
+	}
+	if !slices.Contains(allowed, raw) {
+		return "", fieldError(name, "must be one of "+strings.Join(allowed, ", "))
+	}
+	return raw, nil
+}
@@ -0,0 +1,85 @@
+package request
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPathUUID(t *testing.T) {
+	id := uuid.New()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.SetPathValue("chirpID", id.String())
+	if got, err := PathUUID(r, "chirpID"); err != nil || got != id {
+		t.Fatalf("PathUUID = %v, %v", got, err)
+	}
+
+	r.SetPathValue("chirpID", "not-a-uuid")
+	if _, err := PathUUID(r, "chirpID"); err == nil {
+		t.Fatal("Expected an error for a malformed id")
+	}
+}
+
+func TestQueryUUID(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?author_id=nope", nil)
+	if _, err := QueryUUID(r, "author_id"); err == nil {
+		t.Fatal("Expected an error for a malformed id")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/", nil)
+	if got, err := QueryUUID(r, "author_id"); err != nil || got.Valid {
+		t.Fatalf("Expected an absent id to be invalid, got %v, %v", got, err)
+	}
+}
+
+func TestQueryInt(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+		ok    bool
+	}{
+		{"", 50, true},
+		{"limit=1", 1, true},
+		{"limit=200", 200, true},
+		{"limit=0", 0, false},
+		{"limit=201", 0, false},
+		{"limit=ten", 0, false},
+	}
+	for _, tc := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/?"+tc.query, nil)
+		got, err := QueryInt(r, "limit", 50, 1, 200)
+		if (err == nil) != tc.ok || (tc.ok && got != tc.want) {
+			t.Errorf("QueryInt(%q) = %d, %v", tc.query, got, err)
+		}
+	}
+}
+
+func TestQueryTime(t *testing.T) {
+	def := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	r := httptest.NewRequest(http.MethodGet, "/?before=2026-03-04T05:06:07.5-05:00", nil)
+	got, err := QueryTime(r, "before", def)
+	want := time.Date(2026, 3, 4, 10, 6, 7, 500000000, time.UTC)
+	if err != nil || !got.Equal(want) {
+		t.Fatalf("QueryTime = %v, %v", got, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?before=yesterday", nil)
+	if _, err := QueryTime(r, "before", def); err == nil {
+		t.Fatal("Expected an error for a malformed time")
+	}
+}
+
+func TestQueryEnum(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/?sort=desc", nil)
+	if got, err := QueryEnum(r, "sort", "asc", "asc", "desc"); err != nil || got != "desc" {
+		t.Fatalf("QueryEnum = %q, %v", got, err)
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/?sort=random", nil)
+	if _, err := QueryEnum(r, "sort", "asc", "asc", "desc"); err == nil {
+		t.Fatal("Expected an error for an unknown value")
+	}
+}
@@ -0,0 +1,139 @@
+// Package request decodes and validates what clients send: JSON bodies,
+// path parameters and query parameters. Every failure is an *apierror.Error
+// that says which field was wrong, so handlers can write it as is.
+package request
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+)
+
+// Bodies larger than this are rejected unless a handler asks for another
+// limit.
+const DEFAULT_MAX_BODY_BYTES = 1 << 20
+
+// FieldErrors maps field names to what is wrong with them.
+type FieldErrors map[string]string
+
+// Add records a problem with field. The first problem found is kept.
+func (e FieldErrors) Add(field, problem string) {
+	if _, ok := e[field]; !ok {
+		e[field] = problem
+	}
+}
+
+// Err returns a validation error listing the fields, or nil if there are
+// none.
+func (e FieldErrors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return apierror.ValidationFailed(e.message()).WithDetails(map[string]FieldErrors{"fields": e})
+}
+
+func (e FieldErrors) message() string {
+	if len(e) == 1 {
+		for field, problem := range e {
+			return fmt.Sprintf("%s %s.", field, problem)
+		}
+	}
+	fields := make([]string, 0, len(e))
+	for field := range e {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fmt.Sprintf("Invalid fields: %s.", strings.Join(fields, ", "))
+}
+
+// fieldError returns a validation error for a single field.
+func fieldError(field, problem string) error {
+	return FieldErrors{field: problem}.Err()
+}
+
+var errTrailingData = errors.New("trailing data after JSON value")
+
+// Validator is implemented by request bodies that check their own fields
+// once decoded.
+type Validator interface {
+	Validate() FieldErrors
+}
+
+// DecodeJSON decodes a JSON object from r's body into dst, allowing at most
+// DEFAULT_MAX_BODY_BYTES. Unknown fields and trailing data are rejected. If
+// dst is a Validator, it is validated too.
+func DecodeJSON(w http.ResponseWriter, r *http.Request, dst any) error {
+	return DecodeJSONLimit(w, r, dst, DEFAULT_MAX_BODY_BYTES)
+}
+
+// DecodeJSONLimit is DecodeJSON with a body size limit of maxBytes.
+func DecodeJSONLimit(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) error {
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBytes))
+	dec.DisallowUnknownFields()
+
+	err := dec.Decode(dst)
+	if err == nil {
+		if _, tokenErr := dec.Token(); tokenErr != io.EOF {
+			err = errTrailingData
+		}
+	}
+	if err != nil {
+		return decodeError(err)
+	}
+
+	if v, ok := dst.(Validator); ok {
+		return v.Validate().Err()
+	}
+	return nil
+}
+
+func decodeError(err error) error {
+	var tooLarge *http.MaxBytesError
+	var syntaxErr *json.SyntaxError
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &tooLarge):
+		return apierror.New(http.StatusRequestEntityTooLarge, apierror.BODY_TOO_LARGE,
+			fmt.Sprintf("Request body must be at most %d bytes.", tooLarge.Limit))
+	case errors.Is(err, errTrailingData):
+		return apierror.InvalidJSON("Request body must be a single JSON object.")
+	case errors.Is(err, io.EOF):
+		return apierror.InvalidJSON("Request body must be a JSON object.")
+	case errors.As(err, &syntaxErr):
+		return apierror.InvalidJSON(fmt.Sprintf("Request body is not valid JSON (at byte %d).", syntaxErr.Offset))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return apierror.InvalidJSON("Request body is not valid JSON.")
+	case errors.As(err, &typeErr):
+		if typeErr.Field == "" {
+			return apierror.InvalidJSON("Request body must be a JSON object.")
+		}
+		return fieldError(typeErr.Field, fmt.Sprintf("must be %s", jsonType(typeErr.Type.Kind().String())))
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return fieldError(field, "is not a known field")
+	}
+	return apierror.InvalidJSON(fmt.Sprintf("Request body is not valid: %v.", err))
+}
+
+// jsonType names a Go kind the way a client would think of it.
+func jsonType(kind string) string {
+	switch {
+	case strings.HasPrefix(kind, "int"), strings.HasPrefix(kind, "uint"), strings.HasPrefix(kind, "float"):
+		return "a number"
+	case kind == "string":
+		return "a string"
+	case kind == "bool":
+		return "true or false"
+	case kind == "slice", kind == "array":
+		return "an array"
+	case kind == "map", kind == "struct":
+		return "an object"
+	}
+	return "a different type"
+}
@@ -0,0 +1,107 @@
+package request
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+)
+
+type testBody struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+func (b testBody) Validate() FieldErrors {
+	errs := FieldErrors{}
+	if b.Name == "" {
+		errs.Add("name", "is required")
+	}
+	return errs
+}
+
+func decode(t *testing.T, body string, dst any) *apierror.Error {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	err := DecodeJSONLimit(httptest.NewRecorder(), r, dst, 64)
+	if err == nil {
+		return nil
+	}
+	var apiErr *apierror.Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("Expected an *apierror.Error, got %v", err)
+	}
+	return apiErr
+}
+
+func TestDecodeJSON(t *testing.T) {
+	var b testBody
+	if err := decode(t, `{"name": "chirp", "count": 2}`, &b); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if b.Name != "chirp" || b.Count != 2 {
+		t.Fatalf("Unexpected body %+v", b)
+	}
+}
+
+func TestDecodeJSONErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   apierror.Code
+		field  string
+	}{
+		{"empty", ``, http.StatusBadRequest, apierror.INVALID_JSON, ""},
+		{"syntax", `{"name": `, http.StatusBadRequest, apierror.INVALID_JSON, ""},
+		{"not an object", `[1]`, http.StatusBadRequest, apierror.INVALID_JSON, ""},
+		{"trailing data", `{"name": "a"} {}`, http.StatusBadRequest, apierror.INVALID_JSON, ""},
+		{"unknown field", `{"name": "a", "colour": "red"}`, http.StatusBadRequest, apierror.VALIDATION_FAILED, "colour"},
+		{"wrong type", `{"name": "a", "count": "two"}`, http.StatusBadRequest, apierror.VALIDATION_FAILED, "count"},
+		{"invalid", `{"count": 1}`, http.StatusBadRequest, apierror.VALIDATION_FAILED, "name"},
+		{"too large", `{"name": "` + strings.Repeat("a", 100) + `"}`, http.StatusRequestEntityTooLarge, apierror.BODY_TOO_LARGE, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := decode(t, tc.body, &testBody{})
+			if err == nil {
+				t.Fatal("Expected an error")
+			}
+			if err.Status != tc.status || err.Code != tc.code {
+				t.Fatalf("Expected %d %s, got %d %s: %s", tc.status, tc.code, err.Status, err.Code, err.Message)
+			}
+			if tc.field == "" {
+				return
+			}
+			details, ok := err.Details.(map[string]FieldErrors)
+			if _, found := details["fields"][tc.field]; !ok || !found {
+				t.Fatalf("Expected an error for field %s, got %v", tc.field, err.Details)
+			}
+		})
+	}
+}
+
+func TestFieldErrors(t *testing.T) {
+	errs := FieldErrors{}
+	if errs.Err() != nil {
+		t.Fatal("Expected no error without fields")
+	}
+
+	errs.Add("body", "is required")
+	errs.Add("body", "is too long")
+	if errs["body"] != "is required" {
+		t.Fatalf("Expected the first problem to be kept, got %q", errs["body"])
+	}
+	if msg := errs.Err().Error(); msg != "body is required." {
+		t.Fatalf("Unexpected message %q", msg)
+	}
+
+	errs.Add("poll.options", "must have 2 to 4 options")
+	if msg := errs.Err().Error(); msg != "Invalid fields: body, poll.options." {
+		t.Fatalf("Unexpected message %q", msg)
+	}
+}
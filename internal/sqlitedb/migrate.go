@@ -0,0 +1,89 @@
+package sqlitedb
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migrations are applied by the server rather than goose, so a SQLite
+// deployment is a single binary and a database file.
+//
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Migrate applies the migrations db hasn't had yet, in order. Each one runs
+// in its own transaction.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INTEGER PRIMARY KEY,
+    applied_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+)`)
+	if err != nil {
+		return fmt.Errorf("Error creating schema_migrations: %v", err)
+	}
+
+	var current int
+	err = db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current)
+	if err != nil {
+		return fmt.Errorf("Error reading schema version: %v", err)
+	}
+
+	names, err := fs.Glob(migrations, "migrations/*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		version, err := migrationVersion(name)
+		if err != nil {
+			return err
+		}
+		if version <= current {
+			continue
+		}
+
+		err = applyMigration(ctx, db, name, version)
+		if err != nil {
+			return fmt.Errorf("Error applying migration %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// migrationVersion parses the number a migration's file name starts with.
+func migrationVersion(name string) (int, error) {
+	base := strings.TrimPrefix(name, "migrations/")
+	prefix, _, ok := strings.Cut(base, "_")
+	if !ok {
+		return 0, fmt.Errorf("Migration %s isn't named <version>_<name>.sql", name)
+	}
+	return strconv.Atoi(prefix)
+}
+
+func applyMigration(ctx context.Context, db *sql.DB, name string, version int) error {
+	stmts, err := migrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, string(stmts))
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", version)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	return err
+}
@@ -0,0 +1,20 @@
+-- SQLite has no UUID type or gen_random_uuid(), so ids are stored as text
+-- and default to a random version 4 UUID built from randomblob().
+-- Timestamps are stored as UTC text with millisecond precision, which
+-- covers what ALTER DATABASE ... SET timezone does on Postgres.
+CREATE TABLE users (
+    id TEXT PRIMARY KEY DEFAULT (
+        lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4'
+        || substr(lower(hex(randomblob(2))), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(lower(hex(randomblob(2))), 2) || '-'
+        || lower(hex(randomblob(6)))
+    ),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    email TEXT NOT NULL UNIQUE,
+    hashed_password TEXT NOT NULL DEFAULT 'unset',
+    is_chirpy_red BOOLEAN NOT NULL DEFAULT FALSE,
+    deletion_scheduled_at TIMESTAMP,
+    dm_policy TEXT NOT NULL DEFAULT 'everyone',
+    protected BOOLEAN NOT NULL DEFAULT FALSE
+);
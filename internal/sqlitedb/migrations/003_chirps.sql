@@ -0,0 +1,26 @@
+-- Mentions are a JSON array of user ids, in place of a UUID[].
+CREATE TABLE chirps (
+    id TEXT PRIMARY KEY DEFAULT (
+        lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4'
+        || substr(lower(hex(randomblob(2))), 2) || '-'
+        || substr('89ab', 1 + abs(random()) % 4, 1) || substr(lower(hex(randomblob(2))), 2) || '-'
+        || lower(hex(randomblob(6)))
+    ),
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    body TEXT NOT NULL,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    status TEXT NOT NULL DEFAULT 'published'
+        CHECK (status IN ('draft', 'scheduled', 'published')),
+    publish_at TIMESTAMP,
+    reply_to_id TEXT REFERENCES chirps(id) ON DELETE SET NULL,
+    thread_id TEXT,
+    visibility TEXT NOT NULL DEFAULT 'public'
+        CHECK (visibility IN ('public', 'followers', 'mentioned')),
+    mentions TEXT NOT NULL DEFAULT '[]',
+    quote_id TEXT REFERENCES chirps(id) ON DELETE SET NULL,
+    CHECK (status <> 'scheduled' OR publish_at IS NOT NULL)
+);
+
+CREATE INDEX chirps_user_id_idx ON chirps(user_id, created_at);
+CREATE INDEX chirps_thread_id_idx ON chirps(thread_id);
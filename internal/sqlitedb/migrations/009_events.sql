@@ -0,0 +1,124 @@
+-- The chirp event log and notifications are written by triggers, as on
+-- Postgres. Ids autoincrement so they are never reused once old rows are
+-- deleted, which live streams rely on. chirp_tags() is registered by the
+-- store on every connection, and it tells listeners about new rows when
+-- their transaction commits in place of NOTIFY.
+CREATE TABLE chirp_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    event TEXT NOT NULL,
+    chirp_id TEXT NOT NULL,
+    user_id TEXT NOT NULL,
+    thread_id TEXT NOT NULL,
+    tags TEXT NOT NULL DEFAULT '[]',
+    visibility TEXT NOT NULL DEFAULT 'public',
+    mentions TEXT NOT NULL DEFAULT '[]'
+);
+
+CREATE INDEX chirp_events_created_at_idx ON chirp_events(created_at);
+
+-- Events record who the chirp was shown to, taking the author being
+-- protected into account.
+CREATE TRIGGER chirps_record_created
+AFTER INSERT ON chirps
+WHEN NEW.status = 'published'
+BEGIN
+    INSERT INTO chirp_events (event, chirp_id, user_id, thread_id, tags, visibility, mentions)
+    VALUES ('chirp.created', NEW.id, NEW.user_id, COALESCE(NEW.thread_id, NEW.id), chirp_tags(NEW.body),
+        CASE WHEN NEW.visibility = 'public' AND (SELECT protected FROM users WHERE id = NEW.user_id)
+            THEN 'followers' ELSE NEW.visibility END,
+        NEW.mentions);
+END;
+
+CREATE TRIGGER chirps_record_published
+AFTER UPDATE OF status ON chirps
+WHEN NEW.status = 'published' AND OLD.status <> 'published'
+BEGIN
+    INSERT INTO chirp_events (event, chirp_id, user_id, thread_id, tags, visibility, mentions)
+    VALUES ('chirp.created', NEW.id, NEW.user_id, COALESCE(NEW.thread_id, NEW.id), chirp_tags(NEW.body),
+        CASE WHEN NEW.visibility = 'public' AND (SELECT protected FROM users WHERE id = NEW.user_id)
+            THEN 'followers' ELSE NEW.visibility END,
+        NEW.mentions);
+END;
+
+CREATE TRIGGER chirps_record_deleted
+AFTER DELETE ON chirps
+WHEN OLD.status = 'published'
+BEGIN
+    INSERT INTO chirp_events (event, chirp_id, user_id, thread_id, tags, visibility, mentions)
+    VALUES ('chirp.deleted', OLD.id, OLD.user_id, COALESCE(OLD.thread_id, OLD.id), chirp_tags(OLD.body),
+        CASE WHEN OLD.visibility = 'public' AND (SELECT protected FROM users WHERE id = OLD.user_id)
+            THEN 'followers' ELSE OLD.visibility END,
+        OLD.mentions);
+END;
+
+CREATE TABLE notifications (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL
+        CHECK (type IN ('follow', 'follow_request', 'reply')),
+    actor_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id TEXT REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications(user_id, id);
+CREATE INDEX notifications_created_at_idx ON notifications(created_at);
+
+CREATE TRIGGER follows_notify
+AFTER INSERT ON follows
+BEGIN
+    INSERT INTO notifications (user_id, type, actor_id)
+    VALUES (NEW.followee_id, 'follow', NEW.follower_id);
+END;
+
+CREATE TRIGGER follow_requests_notify
+AFTER INSERT ON follow_requests
+BEGIN
+    INSERT INTO notifications (user_id, type, actor_id)
+    VALUES (NEW.target_id, 'follow_request', NEW.requester_id);
+END;
+
+-- Users are notified when a reply to one of their chirps is published,
+-- unless they replied to themselves or can't see the reply.
+CREATE TRIGGER chirps_notify_reply
+AFTER INSERT ON chirps
+WHEN NEW.reply_to_id IS NOT NULL AND NEW.status = 'published'
+BEGIN
+    INSERT INTO notifications (user_id, type, actor_id, chirp_id)
+    SELECT parent.user_id, 'reply', NEW.user_id, NEW.id
+    FROM chirps parent
+    WHERE parent.id = NEW.reply_to_id AND parent.user_id <> NEW.user_id
+        AND (
+            EXISTS (SELECT 1 FROM json_each(NEW.mentions) WHERE value = parent.user_id)
+            OR CASE
+                WHEN NEW.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = NEW.user_id) THEN TRUE
+                WHEN NEW.visibility = 'mentioned' THEN FALSE
+                ELSE EXISTS (
+                    SELECT 1 FROM follows
+                    WHERE follower_id = parent.user_id AND followee_id = NEW.user_id
+                )
+            END
+        );
+END;
+
+CREATE TRIGGER chirps_notify_published_reply
+AFTER UPDATE OF status ON chirps
+WHEN NEW.reply_to_id IS NOT NULL AND NEW.status = 'published' AND OLD.status <> 'published'
+BEGIN
+    INSERT INTO notifications (user_id, type, actor_id, chirp_id)
+    SELECT parent.user_id, 'reply', NEW.user_id, NEW.id
+    FROM chirps parent
+    WHERE parent.id = NEW.reply_to_id AND parent.user_id <> NEW.user_id
+        AND (
+            EXISTS (SELECT 1 FROM json_each(NEW.mentions) WHERE value = parent.user_id)
+            OR CASE
+                WHEN NEW.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = NEW.user_id) THEN TRUE
+                WHEN NEW.visibility = 'mentioned' THEN FALSE
+                ELSE EXISTS (
+                    SELECT 1 FROM follows
+                    WHERE follower_id = parent.user_id AND followee_id = NEW.user_id
+                )
+            END
+        );
+END;
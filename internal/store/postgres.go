@@ -0,0 +1,152 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/lib/pq"
+)
+
+const (
+	LISTENER_MIN_RECONNECT = time.Second
+	LISTENER_MAX_RECONNECT = time.Minute
+	// Listeners ping the server this often, so a dropped connection is
+	// noticed and re-established.
+	LISTENER_PING_INTERVAL = 30 * time.Second
+)
+
+// Postgres is a Repository backed by the sqlc-generated queries. Errors are
+// translated to the ones the other implementations return.
+type Postgres struct {
+	postgresQueries
+	db  *sql.DB
+	url string
+}
+
+var _ Repository = (*Postgres)(nil)
+
+// OpenPostgres connects to the database at url, whose schema is migrated
+// with goose.
+func OpenPostgres(url string) (*Postgres, error) {
+	db, err := sql.Open("postgres", url)
+	if err != nil {
+		return nil, err
+	}
+	return &Postgres{
+		postgresQueries: postgresQueries{database.New(db)},
+		db:              db,
+		url:             url,
+	}, nil
+}
+
+func (p *Postgres) Begin(ctx context.Context) (Tx, error) {
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresTx{postgresQueries: postgresQueries{p.Queries.WithTx(tx)}, tx: tx}, nil
+}
+
+// Listen uses LISTEN on its own connection, which is re-established if it
+// drops.
+func (p *Postgres) Listen(ctx context.Context, channels ...string) (<-chan string, error) {
+	listener := pq.NewListener(p.url, LISTENER_MIN_RECONNECT, LISTENER_MAX_RECONNECT, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("Listener: %v\n", err)
+		}
+	})
+	for _, channel := range channels {
+		err := listener.Listen(channel)
+		if err != nil {
+			listener.Close()
+			return nil, err
+		}
+	}
+
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer listener.Close()
+
+		ticker := time.NewTicker(LISTENER_PING_INTERVAL)
+		defer ticker.Stop()
+		for {
+			var channel string
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-listener.Notify:
+				// A nil notification means the connection was
+				// re-established and notifications may have been missed.
+				if n != nil {
+					channel = n.Channel
+				}
+			case <-ticker.C:
+				go listener.Ping()
+				continue
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case out <- channel:
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (p *Postgres) Close() error {
+	return p.db.Close()
+}
+
+type postgresTx struct {
+	postgresQueries
+	tx *sql.Tx
+}
+
+func (t *postgresTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *postgresTx) Rollback() error {
+	err := t.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+// postgresQueries are the generated queries, with a duplicate email reported
+// as ErrDuplicateEmail.
+type postgresQueries struct {
+	*database.Queries
+}
+
+// pqUniqueViolation is the SQLSTATE Postgres reports a violated UNIQUE
+// constraint with.
+const pqUniqueViolation = "23505"
+
+// emailViolation translates a violation of the users' email constraint into
+// ErrDuplicateEmail.
+func emailViolation(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == pqUniqueViolation && pqErr.Constraint == "users_email_key" {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+func (q postgresQueries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	user, err := q.Queries.CreateUser(ctx, arg)
+	return user, emailViolation(err)
+}
+
+func (q postgresQueries) UpdateUsernamePassword(ctx context.Context, arg database.UpdateUsernamePasswordParams) (database.UpdateUsernamePasswordRow, error) {
+	user, err := q.Queries.UpdateUsernamePassword(ctx, arg)
+	return user, emailViolation(err)
+}
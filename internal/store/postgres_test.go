@@ -0,0 +1,24 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestEmailViolation(t *testing.T) {
+	dup := &pq.Error{Code: pqUniqueViolation, Constraint: "users_email_key"}
+	if err := emailViolation(fmt.Errorf("wrapped: %w", dup)); !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("Expected ErrDuplicateEmail, got %v\n", err)
+	}
+
+	other := &pq.Error{Code: pqUniqueViolation, Constraint: "external_identities_issuer_subject_key"}
+	if err := emailViolation(other); err != other {
+		t.Fatalf("Expected other violations to pass through, got %v\n", err)
+	}
+	if err := emailViolation(nil); err != nil {
+		t.Fatalf("Expected nil, got %v\n", err)
+	}
+}
@@ -0,0 +1,503 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/sqlitedb"
+	"github.com/google/uuid"
+	"github.com/mattn/go-sqlite3"
+)
+
+// SQLite is a Repository backed by a SQLite database, for local development,
+// small deployments without a Postgres server, and tests.
+type SQLite struct {
+	sqliteQueries
+	notifier *notifier
+}
+
+var _ Repository = (*SQLite)(nil)
+
+// OpenSQLite opens the database at path, creating it if it doesn't exist,
+// and brings its schema up to date.
+func OpenSQLite(ctx context.Context, path string) (*SQLite, error) {
+	return openSQLite(ctx, "file:"+path+"?_foreign_keys=on&_busy_timeout=5000")
+}
+
+// OpenMemory opens an empty database that lives in memory until it's
+// closed.
+func OpenMemory(ctx context.Context) (*SQLite, error) {
+	return openSQLite(ctx, "file::memory:?_foreign_keys=on")
+}
+
+func openSQLite(ctx context.Context, dsn string) (*SQLite, error) {
+	n := &notifier{listeners: map[*listener]struct{}{}}
+	db := sql.OpenDB(&sqliteConnector{
+		dsn:    dsn,
+		driver: &sqlite3.SQLiteDriver{ConnectHook: n.connect},
+	})
+	// SQLite allows one writer at a time. A single connection queues them
+	// instead of failing with SQLITE_BUSY, and keeps an in-memory database
+	// alive.
+	db.SetMaxOpenConns(1)
+	db.SetConnMaxIdleTime(0)
+	db.SetConnMaxLifetime(0)
+
+	err := sqlitedb.Migrate(ctx, db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLite{
+		sqliteQueries: sqliteQueries{queries: sqlitedb.New(db), db: db},
+		notifier:      n,
+	}, nil
+}
+
+func (s *SQLite) Begin(ctx context.Context) (Tx, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &sqliteTx{sqliteQueries: sqliteQueries{queries: s.queries.WithTx(tx)}, tx: tx}, nil
+}
+
+// Listen is notified by the commit of each transaction that inserted rows
+// into a table with the channel's name, in place of Postgres' NOTIFY.
+func (s *SQLite) Listen(ctx context.Context, channels ...string) (<-chan string, error) {
+	l := s.notifier.add(channels)
+	out := make(chan string)
+	go func() {
+		defer close(out)
+		defer s.notifier.remove(l)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-l.wake:
+			}
+			for _, channel := range s.notifier.take(l) {
+				select {
+				case <-ctx.Done():
+					return
+				case out <- channel:
+				}
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (s *SQLite) Close() error {
+	return s.db.Close()
+}
+
+type sqliteTx struct {
+	sqliteQueries
+	tx *sql.Tx
+}
+
+func (t *sqliteTx) Commit() error {
+	return t.tx.Commit()
+}
+
+func (t *sqliteTx) Rollback() error {
+	err := t.tx.Rollback()
+	if errors.Is(err, sql.ErrTxDone) {
+		return nil
+	}
+	return err
+}
+
+// sqliteQueries implements database.Querier with the SQLite queries,
+// converting between their types and the database package's.
+type sqliteQueries struct {
+	queries *sqlitedb.Queries
+	// db runs queries that take several statements in a transaction. It's
+	// nil in a transaction, where they run in that one.
+	db *sql.DB
+}
+
+// inTx calls f in a transaction, committing it if f succeeds.
+func (q *sqliteQueries) inTx(ctx context.Context, f func(qtx *sqlitedb.Queries) error) error {
+	if q.db == nil {
+		return f(q.queries)
+	}
+	tx, err := q.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = f(q.queries.WithTx(tx))
+	if err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteConnector opens connections with the hooks of a notifier.
+type sqliteConnector struct {
+	dsn    string
+	driver *sqlite3.SQLiteDriver
+}
+
+func (c *sqliteConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c *sqliteConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// notifier tells listeners which tables have had rows inserted, once the
+// transaction inserting them commits.
+type notifier struct {
+	mu        sync.Mutex
+	listeners map[*listener]struct{}
+}
+
+type listener struct {
+	channels []string
+	// Channels notified since the listener last took them.
+	pending map[string]struct{}
+	// wake has room for one value, sent when pending stops being empty.
+	wake chan struct{}
+}
+
+// connect registers the chirp_tags function and the hooks that notify
+// listeners on a new connection.
+func (n *notifier) connect(conn *sqlite3.SQLiteConn) error {
+	err := conn.RegisterFunc("chirp_tags", chirpTags, true)
+	if err != nil {
+		return err
+	}
+
+	// Hooks run on the goroutine using the connection, so inserted needs
+	// no lock.
+	inserted := map[string]struct{}{}
+	conn.RegisterUpdateHook(func(op int, db string, table string, rowid int64) {
+		if op == sqlite3.SQLITE_INSERT {
+			inserted[table] = struct{}{}
+		}
+	})
+	// The commit hook runs just before the commit finishes, but readers
+	// wait for the only connection, so they see what was committed.
+	conn.RegisterCommitHook(func() int {
+		n.notify(inserted)
+		clear(inserted)
+		return 0
+	})
+	conn.RegisterRollbackHook(func() {
+		clear(inserted)
+	})
+	return nil
+}
+
+func (n *notifier) add(channels []string) *listener {
+	l := &listener{
+		channels: channels,
+		pending:  map[string]struct{}{},
+		wake:     make(chan struct{}, 1),
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.listeners[l] = struct{}{}
+	return l
+}
+
+func (n *notifier) remove(l *listener) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.listeners, l)
+}
+
+func (n *notifier) notify(tables map[string]struct{}) {
+	if len(tables) == 0 {
+		return
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	for l := range n.listeners {
+		for _, channel := range l.channels {
+			if _, ok := tables[channel]; !ok {
+				continue
+			}
+			l.pending[channel] = struct{}{}
+			select {
+			case l.wake <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// take returns the channels notified since l last took them.
+func (n *notifier) take(l *listener) []string {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	channels := make([]string, 0, len(l.pending))
+	for channel := range l.pending {
+		channels = append(channels, channel)
+	}
+	clear(l.pending)
+	slices.Sort(channels)
+	return channels
+}
+
+var hashtagPattern = regexp.MustCompile(`#([\p{L}\p{Nd}_]+)`)
+
+// chirpTags returns the distinct hashtags in body, lowercased and sorted, as
+// a JSON array. The triggers recording chirp events call it.
+func chirpTags(body string) (string, error) {
+	tags := []string{}
+	for _, m := range hashtagPattern.FindAllStringSubmatch(body, -1) {
+		tags = append(tags, strings.ToLower(m[1]))
+	}
+	slices.Sort(tags)
+	return jsonArray(slices.Compact(tags))
+}
+
+// uniqueViolation translates a violated UNIQUE constraint on the users'
+// email into ErrDuplicateEmail.
+func uniqueViolation(err error) error {
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique &&
+		strings.Contains(sqliteErr.Error(), "users.email") {
+		return ErrDuplicateEmail
+	}
+	return err
+}
+
+// jsonArray encodes values as a JSON array, treating nil as empty.
+func jsonArray[T any](values []T) (string, error) {
+	if values == nil {
+		values = []T{}
+	}
+	raw, err := json.Marshal(values)
+	return string(raw), err
+}
+
+// jsonNullArray encodes values as a JSON array, or NULL if they're nil.
+func jsonNullArray[T any](values []T) (sql.NullString, error) {
+	if values == nil {
+		return sql.NullString{}, nil
+	}
+	raw, err := jsonArray(values)
+	return sql.NullString{String: raw, Valid: true}, err
+}
+
+func decodeArray[T any](raw string) ([]T, error) {
+	values := []T{}
+	err := json.Unmarshal([]byte(raw), &values)
+	return values, err
+}
+
+func decodeNullArray[T any](raw sql.NullString) ([]T, error) {
+	if !raw.Valid {
+		return nil, nil
+	}
+	return decodeArray[T](raw.String)
+}
+
+// convertRows converts each row with convert. A nil slice stays nil, as the
+// generated queries return when nothing matches.
+func convertRows[S, D any](rows []S, convert func(S) D) []D {
+	if rows == nil {
+		return nil
+	}
+	converted := make([]D, 0, len(rows))
+	for _, row := range rows {
+		converted = append(converted, convert(row))
+	}
+	return converted
+}
+
+// decodeRow decodes a row with decode unless reading it failed.
+func decodeRow[S, D any](row S, err error, decode func(S) (D, error)) (D, error) {
+	if err != nil {
+		var zero D
+		return zero, err
+	}
+	return decode(row)
+}
+
+// decodeRows decodes each row with decode unless reading them failed.
+func decodeRows[S, D any](rows []S, err error, decode func(S) (D, error)) ([]D, error) {
+	if err != nil || rows == nil {
+		return nil, err
+	}
+	decoded := make([]D, 0, len(rows))
+	for _, row := range rows {
+		d, err := decode(row)
+		if err != nil {
+			return nil, err
+		}
+		decoded = append(decoded, d)
+	}
+	return decoded, nil
+}
+
+// newChirp converts a chirp row, whose mentions are a JSON array.
+func newChirp(c sqlitedb.Chirp) (database.Chirp, error) {
+	mentions, err := decodeArray[uuid.UUID](c.Mentions)
+	if err != nil {
+		return database.Chirp{}, fmt.Errorf("Error decoding mentions of chirp %s: %v", c.ID, err)
+	}
+	return database.Chirp{
+		ID:         c.ID,
+		CreatedAt:  c.CreatedAt,
+		UpdatedAt:  c.UpdatedAt,
+		Body:       c.Body,
+		UserID:     c.UserID,
+		Status:     c.Status,
+		PublishAt:  c.PublishAt,
+		ReplyToID:  c.ReplyToID,
+		ThreadID:   c.ThreadID,
+		Visibility: c.Visibility,
+		Mentions:   mentions,
+		QuoteID:    c.QuoteID,
+	}, nil
+}
+
+func newChirpEvent(e sqlitedb.ChirpEvent) (database.ChirpEvent, error) {
+	tags, err := decodeArray[string](e.Tags)
+	if err != nil {
+		return database.ChirpEvent{}, fmt.Errorf("Error decoding tags of chirp event %d: %v", e.ID, err)
+	}
+	mentions, err := decodeArray[uuid.UUID](e.Mentions)
+	if err != nil {
+		return database.ChirpEvent{}, fmt.Errorf("Error decoding mentions of chirp event %d: %v", e.ID, err)
+	}
+	return database.ChirpEvent{
+		ID:         e.ID,
+		CreatedAt:  e.CreatedAt,
+		Event:      e.Event,
+		ChirpID:    e.ChirpID,
+		UserID:     e.UserID,
+		ThreadID:   e.ThreadID,
+		Tags:       tags,
+		Visibility: e.Visibility,
+		Mentions:   mentions,
+	}, nil
+}
+
+// newChirpAnalyticsRow parses a row of analytics, whose chirp id and day are
+// text.
+func newChirpAnalyticsRow(row sqlitedb.GetChirpAnalyticsRow) (database.GetChirpAnalyticsRow, error) {
+	chirpID, err := uuid.Parse(row.ChirpID)
+	if err != nil {
+		return database.GetChirpAnalyticsRow{}, err
+	}
+	day, err := time.Parse(time.DateOnly, row.Day)
+	if err != nil {
+		return database.GetChirpAnalyticsRow{}, err
+	}
+	return database.GetChirpAnalyticsRow{
+		ChirpID:     chirpID,
+		Day:         day,
+		Impressions: row.Impressions,
+		Replies:     row.Replies,
+	}, nil
+}
+
+func newRefreshToken(t sqlitedb.RefreshToken) (database.RefreshToken, error) {
+	scopes, err := decodeNullArray[string](t.Scopes)
+	if err != nil {
+		return database.RefreshToken{}, fmt.Errorf("Error decoding scopes of refresh token: %v", err)
+	}
+	return database.RefreshToken{
+		Token:     t.Token,
+		CreatedAt: t.CreatedAt,
+		UpdatedAt: t.UpdatedAt,
+		UserID:    t.UserID,
+		ExpiresAt: t.ExpiresAt,
+		RevokedAt: t.RevokedAt,
+		ClientID:  t.ClientID,
+		Scopes:    scopes,
+	}, nil
+}
+
+func newPersonalAccessToken(t sqlitedb.PersonalAccessToken) (database.PersonalAccessToken, error) {
+	scopes, err := decodeArray[string](t.Scopes)
+	if err != nil {
+		return database.PersonalAccessToken{}, fmt.Errorf("Error decoding scopes of token %s: %v", t.ID, err)
+	}
+	return database.PersonalAccessToken{
+		ID:         t.ID,
+		CreatedAt:  t.CreatedAt,
+		UpdatedAt:  t.UpdatedAt,
+		UserID:     t.UserID,
+		Name:       t.Name,
+		TokenHash:  t.TokenHash,
+		Scopes:     scopes,
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		RevokedAt:  t.RevokedAt,
+	}, nil
+}
+
+func newOAuthClient(c sqlitedb.OauthClient) (database.OauthClient, error) {
+	redirectURIs, err := decodeArray[string](c.RedirectUris)
+	if err != nil {
+		return database.OauthClient{}, fmt.Errorf("Error decoding redirect URIs of client %s: %v", c.ID, err)
+	}
+	scopes, err := decodeArray[string](c.Scopes)
+	if err != nil {
+		return database.OauthClient{}, fmt.Errorf("Error decoding scopes of client %s: %v", c.ID, err)
+	}
+	return database.OauthClient{
+		ID:           c.ID,
+		CreatedAt:    c.CreatedAt,
+		UpdatedAt:    c.UpdatedAt,
+		UserID:       c.UserID,
+		Name:         c.Name,
+		SecretHash:   c.SecretHash,
+		RedirectUris: redirectURIs,
+		Scopes:       scopes,
+	}, nil
+}
+
+func newAuthorizationCode(c sqlitedb.OauthAuthorizationCode) (database.OauthAuthorizationCode, error) {
+	scopes, err := decodeArray[string](c.Scopes)
+	if err != nil {
+		return database.OauthAuthorizationCode{}, fmt.Errorf("Error decoding scopes of authorization code: %v", err)
+	}
+	return database.OauthAuthorizationCode{
+		CodeHash:      c.CodeHash,
+		CreatedAt:     c.CreatedAt,
+		ClientID:      c.ClientID,
+		UserID:        c.UserID,
+		RedirectUri:   c.RedirectUri,
+		Scopes:        scopes,
+		CodeChallenge: c.CodeChallenge,
+		ExpiresAt:     c.ExpiresAt,
+		UsedAt:        c.UsedAt,
+	}, nil
+}
+
+func newWebhookEndpoint(e sqlitedb.WebhookEndpoint) (database.WebhookEndpoint, error) {
+	events, err := decodeArray[string](e.Events)
+	if err != nil {
+		return database.WebhookEndpoint{}, fmt.Errorf("Error decoding events of webhook endpoint %s: %v", e.ID, err)
+	}
+	return database.WebhookEndpoint{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		UpdatedAt: e.UpdatedAt,
+		UserID:    e.UserID,
+		Url:       e.Url,
+		Secret:    e.Secret,
+		Events:    events,
+	}, nil
+}
@@ -0,0 +1,926 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/sqlitedb"
+	"github.com/google/uuid"
+)
+
+// The SQLite queries are adapted to the database package's types, so the
+// handlers can't tell which database they run on. Most rows convert directly;
+// the rest have JSON arrays or dates to decode.
+var _ database.Querier = (*sqliteQueries)(nil)
+
+func (q *sqliteQueries) BlockUser(ctx context.Context, arg database.BlockUserParams) error {
+	return q.queries.BlockUser(ctx, sqlitedb.BlockUserParams(arg))
+}
+
+func (q *sqliteQueries) GetBlocksByUser(ctx context.Context, blockerID uuid.UUID) ([]database.Block, error) {
+	rows, err := q.queries.GetBlocksByUser(ctx, blockerID)
+	return convertRows(rows, func(row sqlitedb.Block) database.Block { return database.Block(row) }), err
+}
+
+func (q *sqliteQueries) GetHiddenUserIDs(ctx context.Context, blockerID uuid.UUID) ([]uuid.UUID, error) {
+	return q.queries.GetHiddenUserIDs(ctx, blockerID)
+}
+
+func (q *sqliteQueries) IsBlockedEither(ctx context.Context, arg database.IsBlockedEitherParams) (bool, error) {
+	return q.queries.IsBlockedEither(ctx, sqlitedb.IsBlockedEitherParams(arg))
+}
+
+func (q *sqliteQueries) UnblockUser(ctx context.Context, arg database.UnblockUserParams) (int64, error) {
+	return q.queries.UnblockUser(ctx, sqlitedb.UnblockUserParams(arg))
+}
+
+func (q *sqliteQueries) BookmarkChirp(ctx context.Context, arg database.BookmarkChirpParams) error {
+	return q.queries.BookmarkChirp(ctx, sqlitedb.BookmarkChirpParams(arg))
+}
+
+func (q *sqliteQueries) DeleteBookmark(ctx context.Context, arg database.DeleteBookmarkParams) (int64, error) {
+	return q.queries.DeleteBookmark(ctx, sqlitedb.DeleteBookmarkParams(arg))
+}
+
+func (q *sqliteQueries) GetBookmarkedChirps(ctx context.Context, arg database.GetBookmarkedChirpsParams) ([]database.GetBookmarkedChirpsRow, error) {
+	rows, err := q.queries.GetBookmarkedChirps(ctx, sqlitedb.GetBookmarkedChirpsParams{
+		UserID: arg.UserID,
+		Before: arg.Before,
+		Limit:  int64(arg.Limit),
+	})
+	return decodeRows(rows, err, func(row sqlitedb.GetBookmarkedChirpsRow) (database.GetBookmarkedChirpsRow, error) {
+		chirp, err := newChirp(row.Chirp)
+		return database.GetBookmarkedChirpsRow{BookmarkedAt: row.BookmarkedAt, Chirp: chirp}, err
+	})
+}
+
+func (q *sqliteQueries) GetBookmarksByUser(ctx context.Context, userID uuid.UUID) ([]database.Bookmark, error) {
+	rows, err := q.queries.GetBookmarksByUser(ctx, userID)
+	return convertRows(rows, func(row sqlitedb.Bookmark) database.Bookmark { return database.Bookmark(row) }), err
+}
+
+func (q *sqliteQueries) DeleteChirpEventsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	return q.queries.DeleteChirpEventsBefore(ctx, createdAt)
+}
+
+func (q *sqliteQueries) GetChirpEventsAfter(ctx context.Context, arg database.GetChirpEventsAfterParams) ([]database.ChirpEvent, error) {
+	rows, err := q.queries.GetChirpEventsAfter(ctx, sqlitedb.GetChirpEventsAfterParams{
+		ID:    arg.ID,
+		Limit: int64(arg.Limit),
+	})
+	return decodeRows(rows, err, newChirpEvent)
+}
+
+func (q *sqliteQueries) GetLatestChirpEventID(ctx context.Context) (int64, error) {
+	return q.queries.GetLatestChirpEventID(ctx)
+}
+
+// AddChirpImpressions adds the impressions for each chirp and day in turn.
+func (q *sqliteQueries) AddChirpImpressions(ctx context.Context, arg database.AddChirpImpressionsParams) error {
+	return q.inTx(ctx, func(qtx *sqlitedb.Queries) error {
+		for i, chirpID := range arg.ChirpIds {
+			err := qtx.AddChirpImpressions(ctx, sqlitedb.AddChirpImpressionsParams{
+				Day:         arg.Days[i],
+				Impressions: arg.Counts[i],
+				ChirpID:     chirpID,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (q *sqliteQueries) GetChirpAnalytics(ctx context.Context, arg database.GetChirpAnalyticsParams) ([]database.GetChirpAnalyticsRow, error) {
+	rows, err := q.queries.GetChirpAnalytics(ctx, sqlitedb.GetChirpAnalyticsParams{
+		UserID: arg.UserID,
+		Since:  arg.Since,
+	})
+	return decodeRows(rows, err, newChirpAnalyticsRow)
+}
+
+func (q *sqliteQueries) CountChirpsByAuthorSince(ctx context.Context, arg database.CountChirpsByAuthorSinceParams) (int64, error) {
+	return q.queries.CountChirpsByAuthorSince(ctx, sqlitedb.CountChirpsByAuthorSinceParams{
+		UserID: arg.UserID,
+		Since:  arg.CreatedAt,
+	})
+}
+
+func (q *sqliteQueries) CreateChirp(ctx context.Context, arg database.CreateChirpParams) (database.Chirp, error) {
+	mentions, err := jsonArray(arg.Mentions)
+	if err != nil {
+		return database.Chirp{}, err
+	}
+	row, err := q.queries.CreateChirp(ctx, sqlitedb.CreateChirpParams{
+		Body:       arg.Body,
+		UserID:     arg.UserID,
+		Status:     arg.Status,
+		PublishAt:  arg.PublishAt,
+		ReplyToID:  arg.ReplyToID,
+		ThreadID:   arg.ThreadID,
+		Visibility: arg.Visibility,
+		Mentions:   mentions,
+		QuoteID:    arg.QuoteID,
+	})
+	return decodeRow(row, err, newChirp)
+}
+
+func (q *sqliteQueries) DeleteChirpById(ctx context.Context, id uuid.UUID) error {
+	return q.queries.DeleteChirpById(ctx, id)
+}
+
+func (q *sqliteQueries) GetChirpById(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	row, err := q.queries.GetChirpById(ctx, id)
+	return decodeRow(row, err, newChirp)
+}
+
+func (q *sqliteQueries) GetChirpThread(ctx context.Context, arg database.GetChirpThreadParams) ([]database.Chirp, error) {
+	rows, err := q.queries.GetChirpThread(ctx, sqlitedb.GetChirpThreadParams{
+		ID:       arg.ID,
+		ViewerID: arg.ViewerID.UUID,
+	})
+	return decodeRows(rows, err, newChirp)
+}
+
+func (q *sqliteQueries) GetChirps(ctx context.Context, viewerID uuid.NullUUID) ([]database.Chirp, error) {
+	rows, err := q.queries.GetChirps(ctx, viewerID.UUID)
+	return decodeRows(rows, err, newChirp)
+}
+
+func (q *sqliteQueries) GetChirpsByAuthorId(ctx context.Context, arg database.GetChirpsByAuthorIdParams) ([]database.Chirp, error) {
+	rows, err := q.queries.GetChirpsByAuthorId(ctx, sqlitedb.GetChirpsByAuthorIdParams{
+		UserID:   arg.UserID,
+		ViewerID: arg.ViewerID.UUID,
+	})
+	return decodeRows(rows, err, newChirp)
+}
+
+func (q *sqliteQueries) GetUnpublishedChirpsByAuthor(ctx context.Context, userID uuid.UUID) ([]database.Chirp, error) {
+	rows, err := q.queries.GetUnpublishedChirpsByAuthor(ctx, userID)
+	return decodeRows(rows, err, newChirp)
+}
+
+func (q *sqliteQueries) GetVisibleChirpById(ctx context.Context, arg database.GetVisibleChirpByIdParams) (database.Chirp, error) {
+	row, err := q.queries.GetVisibleChirpById(ctx, sqlitedb.GetVisibleChirpByIdParams{
+		ID:       arg.ID,
+		ViewerID: arg.ViewerID.UUID,
+	})
+	return decodeRow(row, err, newChirp)
+}
+
+func (q *sqliteQueries) GetVisibleChirpsByIds(ctx context.Context, arg database.GetVisibleChirpsByIdsParams) ([]database.Chirp, error) {
+	rows, err := q.queries.GetVisibleChirpsByIds(ctx, sqlitedb.GetVisibleChirpsByIdsParams{
+		ViewerID: arg.ViewerID.UUID,
+		Ids:      arg.Ids,
+	})
+	return decodeRows(rows, err, newChirp)
+}
+
+func (q *sqliteQueries) PublishChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	row, err := q.queries.PublishChirp(ctx, id)
+	return decodeRow(row, err, newChirp)
+}
+
+func (q *sqliteQueries) LockDueChirps(ctx context.Context, limit int32) ([]database.Chirp, error) {
+	rows, err := q.queries.LockDueChirps(ctx, int64(limit))
+	return decodeRows(rows, err, newChirp)
+}
+
+func (q *sqliteQueries) ScheduleChirp(ctx context.Context, arg database.ScheduleChirpParams) (database.Chirp, error) {
+	row, err := q.queries.ScheduleChirp(ctx, sqlitedb.ScheduleChirpParams{
+		PublishAt: arg.PublishAt,
+		ID:        arg.ID,
+	})
+	return decodeRow(row, err, newChirp)
+}
+
+func (q *sqliteQueries) UnscheduleChirp(ctx context.Context, id uuid.UUID) (database.Chirp, error) {
+	row, err := q.queries.UnscheduleChirp(ctx, id)
+	return decodeRow(row, err, newChirp)
+}
+
+func (q *sqliteQueries) UpdateChirpBody(ctx context.Context, arg database.UpdateChirpBodyParams) (database.Chirp, error) {
+	row, err := q.queries.UpdateChirpBody(ctx, sqlitedb.UpdateChirpBodyParams{
+		Body: arg.Body,
+		ID:   arg.ID,
+	})
+	return decodeRow(row, err, newChirp)
+}
+
+func (q *sqliteQueries) ClaimDataExport(ctx context.Context, staleBefore time.Time) (database.ClaimDataExportRow, error) {
+	row, err := q.queries.ClaimDataExport(ctx, staleBefore)
+	return database.ClaimDataExportRow(row), err
+}
+
+func (q *sqliteQueries) CompleteDataExport(ctx context.Context, arg database.CompleteDataExportParams) error {
+	return q.queries.CompleteDataExport(ctx, sqlitedb.CompleteDataExportParams{
+		Archive:   arg.Archive,
+		ExpiresAt: arg.ExpiresAt,
+		ID:        arg.ID,
+	})
+}
+
+func (q *sqliteQueries) CreateDataExport(ctx context.Context, userID uuid.UUID) (database.CreateDataExportRow, error) {
+	row, err := q.queries.CreateDataExport(ctx, userID)
+	return database.CreateDataExportRow(row), err
+}
+
+func (q *sqliteQueries) DeleteExpiredDataExports(ctx context.Context) error {
+	return q.queries.DeleteExpiredDataExports(ctx)
+}
+
+func (q *sqliteQueries) FailDataExport(ctx context.Context, arg database.FailDataExportParams) error {
+	return q.queries.FailDataExport(ctx, sqlitedb.FailDataExportParams{
+		Error: arg.Error,
+		ID:    arg.ID,
+	})
+}
+
+func (q *sqliteQueries) GetDataExportArchive(ctx context.Context, arg database.GetDataExportArchiveParams) ([]byte, error) {
+	return q.queries.GetDataExportArchive(ctx, sqlitedb.GetDataExportArchiveParams(arg))
+}
+
+func (q *sqliteQueries) GetLatestDataExport(ctx context.Context, userID uuid.UUID) (database.GetLatestDataExportRow, error) {
+	row, err := q.queries.GetLatestDataExport(ctx, userID)
+	return database.GetLatestDataExportRow(row), err
+}
+
+func (q *sqliteQueries) AddConversationMember(ctx context.Context, arg database.AddConversationMemberParams) error {
+	return q.queries.AddConversationMember(ctx, sqlitedb.AddConversationMemberParams(arg))
+}
+
+func (q *sqliteQueries) CreateConversation(ctx context.Context, createdBy uuid.NullUUID) (database.Conversation, error) {
+	row, err := q.queries.CreateConversation(ctx, createdBy)
+	return database.Conversation(row), err
+}
+
+func (q *sqliteQueries) CreateDirectMessage(ctx context.Context, arg database.CreateDirectMessageParams) (database.DirectMessage, error) {
+	row, err := q.queries.CreateDirectMessage(ctx, sqlitedb.CreateDirectMessageParams(arg))
+	return database.DirectMessage(row), err
+}
+
+func (q *sqliteQueries) GetConversationMember(ctx context.Context, arg database.GetConversationMemberParams) (database.ConversationMember, error) {
+	row, err := q.queries.GetConversationMember(ctx, sqlitedb.GetConversationMemberParams(arg))
+	return database.ConversationMember(row), err
+}
+
+func (q *sqliteQueries) GetConversationMembers(ctx context.Context, conversationIds []uuid.UUID) ([]database.GetConversationMembersRow, error) {
+	rows, err := q.queries.GetConversationMembers(ctx, conversationIds)
+	return convertRows(rows, func(row sqlitedb.GetConversationMembersRow) database.GetConversationMembersRow {
+		return database.GetConversationMembersRow(row)
+	}), err
+}
+
+func (q *sqliteQueries) GetConversationsByUser(ctx context.Context, arg database.GetConversationsByUserParams) ([]database.GetConversationsByUserRow, error) {
+	rows, err := q.queries.GetConversationsByUser(ctx, sqlitedb.GetConversationsByUserParams{
+		UserID: arg.UserID,
+		Before: arg.Before,
+		Limit:  int64(arg.Limit),
+	})
+	return convertRows(rows, func(row sqlitedb.GetConversationsByUserRow) database.GetConversationsByUserRow {
+		return database.GetConversationsByUserRow(row)
+	}), err
+}
+
+func (q *sqliteQueries) GetDirectConversation(ctx context.Context, arg database.GetDirectConversationParams) (database.Conversation, error) {
+	row, err := q.queries.GetDirectConversation(ctx, sqlitedb.GetDirectConversationParams(arg))
+	return database.Conversation(row), err
+}
+
+func (q *sqliteQueries) GetDirectMessages(ctx context.Context, arg database.GetDirectMessagesParams) ([]database.DirectMessage, error) {
+	rows, err := q.queries.GetDirectMessages(ctx, sqlitedb.GetDirectMessagesParams{
+		ConversationID: arg.ConversationID,
+		Before:         arg.Before,
+		Limit:          int64(arg.Limit),
+	})
+	return convertRows(rows, func(row sqlitedb.DirectMessage) database.DirectMessage { return database.DirectMessage(row) }), err
+}
+
+func (q *sqliteQueries) GetDirectMessagesBySender(ctx context.Context, senderID uuid.UUID) ([]database.DirectMessage, error) {
+	rows, err := q.queries.GetDirectMessagesBySender(ctx, senderID)
+	return convertRows(rows, func(row sqlitedb.DirectMessage) database.DirectMessage { return database.DirectMessage(row) }), err
+}
+
+func (q *sqliteQueries) IsBlockedInConversation(ctx context.Context, arg database.IsBlockedInConversationParams) (bool, error) {
+	return q.queries.IsBlockedInConversation(ctx, sqlitedb.IsBlockedInConversationParams(arg))
+}
+
+func (q *sqliteQueries) MarkConversationRead(ctx context.Context, arg database.MarkConversationReadParams) error {
+	return q.queries.MarkConversationRead(ctx, sqlitedb.MarkConversationReadParams(arg))
+}
+
+func (q *sqliteQueries) TouchConversation(ctx context.Context, id uuid.UUID) error {
+	return q.queries.TouchConversation(ctx, id)
+}
+
+func (q *sqliteQueries) CreateExternalIdentity(ctx context.Context, arg database.CreateExternalIdentityParams) (database.ExternalIdentity, error) {
+	row, err := q.queries.CreateExternalIdentity(ctx, sqlitedb.CreateExternalIdentityParams(arg))
+	return database.ExternalIdentity(row), err
+}
+
+func (q *sqliteQueries) GetExternalIdentitiesByUser(ctx context.Context, userID uuid.UUID) ([]database.ExternalIdentity, error) {
+	rows, err := q.queries.GetExternalIdentitiesByUser(ctx, userID)
+	return convertRows(rows, func(row sqlitedb.ExternalIdentity) database.ExternalIdentity { return database.ExternalIdentity(row) }), err
+}
+
+func (q *sqliteQueries) GetUserByExternalIdentity(ctx context.Context, arg database.GetUserByExternalIdentityParams) (database.User, error) {
+	row, err := q.queries.GetUserByExternalIdentity(ctx, sqlitedb.GetUserByExternalIdentityParams(arg))
+	return database.User(row), err
+}
+
+func (q *sqliteQueries) AcceptAllFollowRequests(ctx context.Context, targetID uuid.UUID) error {
+	return q.inTx(ctx, func(qtx *sqlitedb.Queries) error {
+		err := qtx.FollowAllRequesters(ctx, targetID)
+		if err != nil {
+			return err
+		}
+		return qtx.DeleteFollowRequestsByTarget(ctx, targetID)
+	})
+}
+
+func (q *sqliteQueries) CreateFollowRequest(ctx context.Context, arg database.CreateFollowRequestParams) error {
+	return q.queries.CreateFollowRequest(ctx, sqlitedb.CreateFollowRequestParams(arg))
+}
+
+func (q *sqliteQueries) DeleteFollowRequest(ctx context.Context, arg database.DeleteFollowRequestParams) (int64, error) {
+	return q.queries.DeleteFollowRequest(ctx, sqlitedb.DeleteFollowRequestParams(arg))
+}
+
+func (q *sqliteQueries) DeleteFollowRequestsBetween(ctx context.Context, arg database.DeleteFollowRequestsBetweenParams) error {
+	return q.queries.DeleteFollowRequestsBetween(ctx, sqlitedb.DeleteFollowRequestsBetweenParams(arg))
+}
+
+func (q *sqliteQueries) GetFollowRequestsByTarget(ctx context.Context, targetID uuid.UUID) ([]database.FollowRequest, error) {
+	rows, err := q.queries.GetFollowRequestsByTarget(ctx, targetID)
+	return convertRows(rows, func(row sqlitedb.FollowRequest) database.FollowRequest { return database.FollowRequest(row) }), err
+}
+
+func (q *sqliteQueries) DeleteFollowsBetween(ctx context.Context, arg database.DeleteFollowsBetweenParams) error {
+	return q.queries.DeleteFollowsBetween(ctx, sqlitedb.DeleteFollowsBetweenParams(arg))
+}
+
+func (q *sqliteQueries) FollowUser(ctx context.Context, arg database.FollowUserParams) error {
+	return q.queries.FollowUser(ctx, sqlitedb.FollowUserParams(arg))
+}
+
+func (q *sqliteQueries) GetFolloweeIDs(ctx context.Context, followerID uuid.UUID) ([]uuid.UUID, error) {
+	return q.queries.GetFolloweeIDs(ctx, followerID)
+}
+
+func (q *sqliteQueries) GetFollowsByUser(ctx context.Context, followerID uuid.UUID) ([]database.Follow, error) {
+	rows, err := q.queries.GetFollowsByUser(ctx, followerID)
+	return convertRows(rows, func(row sqlitedb.Follow) database.Follow { return database.Follow(row) }), err
+}
+
+func (q *sqliteQueries) IsFollowing(ctx context.Context, arg database.IsFollowingParams) (bool, error) {
+	return q.queries.IsFollowing(ctx, sqlitedb.IsFollowingParams(arg))
+}
+
+func (q *sqliteQueries) UnfollowUser(ctx context.Context, arg database.UnfollowUserParams) (int64, error) {
+	return q.queries.UnfollowUser(ctx, sqlitedb.UnfollowUserParams(arg))
+}
+
+func (q *sqliteQueries) ClaimLinkPreview(ctx context.Context, leaseUntil time.Time) (string, error) {
+	return q.queries.ClaimLinkPreview(ctx, leaseUntil)
+}
+
+func (q *sqliteQueries) FailLinkPreview(ctx context.Context, url string) error {
+	return q.queries.FailLinkPreview(ctx, url)
+}
+
+func (q *sqliteQueries) GetLinkPreviews(ctx context.Context, urls []string) ([]database.LinkPreview, error) {
+	rows, err := q.queries.GetLinkPreviews(ctx, urls)
+	return convertRows(rows, func(row sqlitedb.LinkPreview) database.LinkPreview { return database.LinkPreview(row) }), err
+}
+
+func (q *sqliteQueries) QueueLinkPreview(ctx context.Context, arg database.QueueLinkPreviewParams) error {
+	return q.inTx(ctx, func(qtx *sqlitedb.Queries) error {
+		err := qtx.QueueLinkPreview(ctx, arg.Url)
+		if err != nil {
+			return err
+		}
+		return qtx.RequeueStaleLinkPreview(ctx, sqlitedb.RequeueStaleLinkPreviewParams{
+			Url:         arg.Url,
+			StaleBefore: arg.StaleBefore,
+		})
+	})
+}
+
+func (q *sqliteQueries) SaveLinkPreview(ctx context.Context, arg database.SaveLinkPreviewParams) error {
+	return q.queries.SaveLinkPreview(ctx, sqlitedb.SaveLinkPreviewParams{
+		Title:       arg.Title,
+		Description: arg.Description,
+		ImageUrl:    arg.ImageUrl,
+		SiteName:    arg.SiteName,
+		Url:         arg.Url,
+	})
+}
+
+func (q *sqliteQueries) GetMutesByUser(ctx context.Context, muterID uuid.UUID) ([]database.Mute, error) {
+	rows, err := q.queries.GetMutesByUser(ctx, muterID)
+	return convertRows(rows, func(row sqlitedb.Mute) database.Mute { return database.Mute(row) }), err
+}
+
+func (q *sqliteQueries) MuteUser(ctx context.Context, arg database.MuteUserParams) error {
+	return q.queries.MuteUser(ctx, sqlitedb.MuteUserParams(arg))
+}
+
+func (q *sqliteQueries) UnmuteUser(ctx context.Context, arg database.UnmuteUserParams) (int64, error) {
+	return q.queries.UnmuteUser(ctx, sqlitedb.UnmuteUserParams(arg))
+}
+
+func (q *sqliteQueries) DeleteNotificationsBefore(ctx context.Context, createdAt time.Time) (int64, error) {
+	return q.queries.DeleteNotificationsBefore(ctx, createdAt)
+}
+
+func (q *sqliteQueries) GetLatestNotificationID(ctx context.Context) (int64, error) {
+	return q.queries.GetLatestNotificationID(ctx)
+}
+
+func (q *sqliteQueries) GetNotificationsAfter(ctx context.Context, arg database.GetNotificationsAfterParams) ([]database.Notification, error) {
+	rows, err := q.queries.GetNotificationsAfter(ctx, sqlitedb.GetNotificationsAfterParams{
+		ID:    arg.ID,
+		Limit: int64(arg.Limit),
+	})
+	return convertRows(rows, func(row sqlitedb.Notification) database.Notification { return database.Notification(row) }), err
+}
+
+func (q *sqliteQueries) GetNotificationsByUser(ctx context.Context, arg database.GetNotificationsByUserParams) ([]database.Notification, error) {
+	rows, err := q.queries.GetNotificationsByUser(ctx, sqlitedb.GetNotificationsByUserParams{
+		UserID: arg.UserID,
+		ID:     arg.ID,
+		Limit:  int64(arg.Limit),
+	})
+	return convertRows(rows, func(row sqlitedb.Notification) database.Notification { return database.Notification(row) }), err
+}
+
+func (q *sqliteQueries) ConsumeAuthorizationCode(ctx context.Context, codeHash string) (database.OauthAuthorizationCode, error) {
+	row, err := q.queries.ConsumeAuthorizationCode(ctx, codeHash)
+	return decodeRow(row, err, newAuthorizationCode)
+}
+
+func (q *sqliteQueries) CreateAuthorizationCode(ctx context.Context, arg database.CreateAuthorizationCodeParams) error {
+	scopes, err := jsonArray(arg.Scopes)
+	if err != nil {
+		return err
+	}
+	return q.queries.CreateAuthorizationCode(ctx, sqlitedb.CreateAuthorizationCodeParams{
+		CodeHash:      arg.CodeHash,
+		ClientID:      arg.ClientID,
+		UserID:        arg.UserID,
+		RedirectUri:   arg.RedirectUri,
+		Scopes:        scopes,
+		CodeChallenge: arg.CodeChallenge,
+		ExpiresAt:     arg.ExpiresAt,
+	})
+}
+
+func (q *sqliteQueries) CreateOAuthClient(ctx context.Context, arg database.CreateOAuthClientParams) (database.OauthClient, error) {
+	redirectUris, err := jsonArray(arg.RedirectUris)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	scopes, err := jsonArray(arg.Scopes)
+	if err != nil {
+		return database.OauthClient{}, err
+	}
+	row, err := q.queries.CreateOAuthClient(ctx, sqlitedb.CreateOAuthClientParams{
+		UserID:       arg.UserID,
+		Name:         arg.Name,
+		SecretHash:   arg.SecretHash,
+		RedirectUris: redirectUris,
+		Scopes:       scopes,
+	})
+	return decodeRow(row, err, newOAuthClient)
+}
+
+func (q *sqliteQueries) DeleteOAuthClient(ctx context.Context, arg database.DeleteOAuthClientParams) (int64, error) {
+	return q.queries.DeleteOAuthClient(ctx, sqlitedb.DeleteOAuthClientParams(arg))
+}
+
+func (q *sqliteQueries) GetOAuthClientByID(ctx context.Context, id uuid.UUID) (database.OauthClient, error) {
+	row, err := q.queries.GetOAuthClientByID(ctx, id)
+	return decodeRow(row, err, newOAuthClient)
+}
+
+func (q *sqliteQueries) GetOAuthClientsByUser(ctx context.Context, userID uuid.UUID) ([]database.OauthClient, error) {
+	rows, err := q.queries.GetOAuthClientsByUser(ctx, userID)
+	return decodeRows(rows, err, newOAuthClient)
+}
+
+// ClaimOutboundWebhook looks up the endpoint of the webhook it claims in the
+// same transaction.
+func (q *sqliteQueries) ClaimOutboundWebhook(ctx context.Context, nextAttemptAt time.Time) (database.ClaimOutboundWebhookRow, error) {
+	var claimed database.ClaimOutboundWebhookRow
+	err := q.inTx(ctx, func(qtx *sqlitedb.Queries) error {
+		webhook, err := qtx.ClaimOutboundWebhook(ctx, nextAttemptAt)
+		if err != nil {
+			return err
+		}
+		endpoint, err := qtx.GetWebhookEndpointTarget(ctx, webhook.EndpointID)
+		if err != nil {
+			return err
+		}
+		claimed = database.ClaimOutboundWebhookRow{
+			ID:         webhook.ID,
+			EndpointID: webhook.EndpointID,
+			Event:      webhook.Event,
+			Payload:    webhook.Payload,
+			Attempts:   webhook.Attempts,
+			Url:        endpoint.Url,
+			Secret:     endpoint.Secret,
+		}
+		return nil
+	})
+	return claimed, err
+}
+
+func (q *sqliteQueries) CreateOutboundWebhookAttempt(ctx context.Context, arg database.CreateOutboundWebhookAttemptParams) error {
+	return q.queries.CreateOutboundWebhookAttempt(ctx, sqlitedb.CreateOutboundWebhookAttemptParams(arg))
+}
+
+func (q *sqliteQueries) CreateWebhookEndpoint(ctx context.Context, arg database.CreateWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	events, err := jsonArray(arg.Events)
+	if err != nil {
+		return database.WebhookEndpoint{}, err
+	}
+	row, err := q.queries.CreateWebhookEndpoint(ctx, sqlitedb.CreateWebhookEndpointParams{
+		UserID: arg.UserID,
+		Url:    arg.Url,
+		Secret: arg.Secret,
+		Events: events,
+	})
+	return decodeRow(row, err, newWebhookEndpoint)
+}
+
+func (q *sqliteQueries) DeadLetterOutboundWebhook(ctx context.Context, arg database.DeadLetterOutboundWebhookParams) error {
+	return q.queries.DeadLetterOutboundWebhook(ctx, sqlitedb.DeadLetterOutboundWebhookParams{
+		LastError: arg.LastError,
+		ID:        arg.ID,
+	})
+}
+
+func (q *sqliteQueries) DeleteWebhookEndpoint(ctx context.Context, arg database.DeleteWebhookEndpointParams) (int64, error) {
+	return q.queries.DeleteWebhookEndpoint(ctx, sqlitedb.DeleteWebhookEndpointParams(arg))
+}
+
+func (q *sqliteQueries) EnqueueOutboundWebhooks(ctx context.Context, arg database.EnqueueOutboundWebhooksParams) (int64, error) {
+	return q.queries.EnqueueOutboundWebhooks(ctx, sqlitedb.EnqueueOutboundWebhooksParams{
+		Event:   arg.Event,
+		Payload: arg.Payload,
+		UserID:  arg.UserID,
+	})
+}
+
+func (q *sqliteQueries) GetOutboundWebhook(ctx context.Context, arg database.GetOutboundWebhookParams) (database.OutboundWebhook, error) {
+	row, err := q.queries.GetOutboundWebhook(ctx, sqlitedb.GetOutboundWebhookParams(arg))
+	return database.OutboundWebhook(row), err
+}
+
+func (q *sqliteQueries) GetOutboundWebhookAttempts(ctx context.Context, outboundWebhookID uuid.UUID) ([]database.OutboundWebhookAttempt, error) {
+	rows, err := q.queries.GetOutboundWebhookAttempts(ctx, outboundWebhookID)
+	return convertRows(rows, func(row sqlitedb.OutboundWebhookAttempt) database.OutboundWebhookAttempt {
+		return database.OutboundWebhookAttempt(row)
+	}), err
+}
+
+func (q *sqliteQueries) GetOutboundWebhooksByEndpoint(ctx context.Context, arg database.GetOutboundWebhooksByEndpointParams) ([]database.OutboundWebhook, error) {
+	rows, err := q.queries.GetOutboundWebhooksByEndpoint(ctx, sqlitedb.GetOutboundWebhooksByEndpointParams{
+		EndpointID: arg.EndpointID,
+		Limit:      int64(arg.Limit),
+	})
+	return convertRows(rows, func(row sqlitedb.OutboundWebhook) database.OutboundWebhook { return database.OutboundWebhook(row) }), err
+}
+
+func (q *sqliteQueries) GetWebhookEndpoint(ctx context.Context, arg database.GetWebhookEndpointParams) (database.WebhookEndpoint, error) {
+	row, err := q.queries.GetWebhookEndpoint(ctx, sqlitedb.GetWebhookEndpointParams(arg))
+	return decodeRow(row, err, newWebhookEndpoint)
+}
+
+func (q *sqliteQueries) GetWebhookEndpointsByUser(ctx context.Context, userID uuid.UUID) ([]database.WebhookEndpoint, error) {
+	rows, err := q.queries.GetWebhookEndpointsByUser(ctx, userID)
+	return decodeRows(rows, err, newWebhookEndpoint)
+}
+
+func (q *sqliteQueries) MarkOutboundWebhookDelivered(ctx context.Context, id uuid.UUID) error {
+	return q.queries.MarkOutboundWebhookDelivered(ctx, id)
+}
+
+func (q *sqliteQueries) RequeueOutboundWebhook(ctx context.Context, arg database.RequeueOutboundWebhookParams) (int64, error) {
+	return q.queries.RequeueOutboundWebhook(ctx, sqlitedb.RequeueOutboundWebhookParams(arg))
+}
+
+func (q *sqliteQueries) RetryOutboundWebhook(ctx context.Context, arg database.RetryOutboundWebhookParams) error {
+	return q.queries.RetryOutboundWebhook(ctx, sqlitedb.RetryOutboundWebhookParams{
+		NextAttemptAt: arg.NextAttemptAt,
+		LastError:     arg.LastError,
+		ID:            arg.ID,
+	})
+}
+
+func (q *sqliteQueries) CreatePersonalAccessToken(ctx context.Context, arg database.CreatePersonalAccessTokenParams) (database.PersonalAccessToken, error) {
+	scopes, err := jsonArray(arg.Scopes)
+	if err != nil {
+		return database.PersonalAccessToken{}, err
+	}
+	row, err := q.queries.CreatePersonalAccessToken(ctx, sqlitedb.CreatePersonalAccessTokenParams{
+		UserID:    arg.UserID,
+		Name:      arg.Name,
+		TokenHash: arg.TokenHash,
+		Scopes:    scopes,
+		ExpiresAt: arg.ExpiresAt,
+	})
+	return decodeRow(row, err, newPersonalAccessToken)
+}
+
+func (q *sqliteQueries) GetPersonalAccessTokenByHash(ctx context.Context, tokenHash string) (database.PersonalAccessToken, error) {
+	row, err := q.queries.GetPersonalAccessTokenByHash(ctx, tokenHash)
+	return decodeRow(row, err, newPersonalAccessToken)
+}
+
+func (q *sqliteQueries) GetPersonalAccessTokensByUser(ctx context.Context, userID uuid.UUID) ([]database.PersonalAccessToken, error) {
+	rows, err := q.queries.GetPersonalAccessTokensByUser(ctx, userID)
+	return decodeRows(rows, err, newPersonalAccessToken)
+}
+
+func (q *sqliteQueries) RevokePersonalAccessToken(ctx context.Context, arg database.RevokePersonalAccessTokenParams) (int64, error) {
+	return q.queries.RevokePersonalAccessToken(ctx, sqlitedb.RevokePersonalAccessTokenParams(arg))
+}
+
+func (q *sqliteQueries) TouchPersonalAccessToken(ctx context.Context, id uuid.UUID) error {
+	return q.queries.TouchPersonalAccessToken(ctx, id)
+}
+
+func (q *sqliteQueries) GetPinnedChirpIDs(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error) {
+	return q.queries.GetPinnedChirpIDs(ctx, userID)
+}
+
+func (q *sqliteQueries) PinChirp(ctx context.Context, arg database.PinChirpParams) (int64, error) {
+	return q.queries.PinChirp(ctx, sqlitedb.PinChirpParams(arg))
+}
+
+func (q *sqliteQueries) UnpinChirp(ctx context.Context, arg database.UnpinChirpParams) (int64, error) {
+	return q.queries.UnpinChirp(ctx, sqlitedb.UnpinChirpParams(arg))
+}
+
+func (q *sqliteQueries) RecordPolkaEvent(ctx context.Context, arg database.RecordPolkaEventParams) (int64, error) {
+	return q.queries.RecordPolkaEvent(ctx, sqlitedb.RecordPolkaEventParams(arg))
+}
+
+func (q *sqliteQueries) CreatePoll(ctx context.Context, arg database.CreatePollParams) error {
+	return q.queries.CreatePoll(ctx, sqlitedb.CreatePollParams{
+		ChirpID:  arg.ChirpID,
+		ClosesAt: arg.ClosesAt,
+	})
+}
+
+func (q *sqliteQueries) CreatePollOption(ctx context.Context, arg database.CreatePollOptionParams) error {
+	return q.queries.CreatePollOption(ctx, sqlitedb.CreatePollOptionParams(arg))
+}
+
+func (q *sqliteQueries) CreatePollVote(ctx context.Context, arg database.CreatePollVoteParams) (int64, error) {
+	return q.queries.CreatePollVote(ctx, sqlitedb.CreatePollVoteParams(arg))
+}
+
+func (q *sqliteQueries) GetPoll(ctx context.Context, chirpID uuid.UUID) (database.Poll, error) {
+	row, err := q.queries.GetPoll(ctx, chirpID)
+	return database.Poll(row), err
+}
+
+func (q *sqliteQueries) GetPollOptions(ctx context.Context, chirpIds []uuid.UUID) ([]database.GetPollOptionsRow, error) {
+	rows, err := q.queries.GetPollOptions(ctx, chirpIds)
+	return convertRows(rows, func(row sqlitedb.GetPollOptionsRow) database.GetPollOptionsRow {
+		return database.GetPollOptionsRow(row)
+	}), err
+}
+
+func (q *sqliteQueries) GetPollVotesByUser(ctx context.Context, arg database.GetPollVotesByUserParams) ([]database.PollVote, error) {
+	rows, err := q.queries.GetPollVotesByUser(ctx, sqlitedb.GetPollVotesByUserParams(arg))
+	return convertRows(rows, func(row sqlitedb.PollVote) database.PollVote { return database.PollVote(row) }), err
+}
+
+func (q *sqliteQueries) CreateOAuthRefreshToken(ctx context.Context, arg database.CreateOAuthRefreshTokenParams) (database.RefreshToken, error) {
+	scopes, err := jsonNullArray(arg.Scopes)
+	if err != nil {
+		return database.RefreshToken{}, err
+	}
+	row, err := q.queries.CreateOAuthRefreshToken(ctx, sqlitedb.CreateOAuthRefreshTokenParams{
+		Token:     arg.Token,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+		ClientID:  arg.ClientID,
+		Scopes:    scopes,
+	})
+	return decodeRow(row, err, newRefreshToken)
+}
+
+func (q *sqliteQueries) CreateRefreshToken(ctx context.Context, arg database.CreateRefreshTokenParams) (database.RefreshToken, error) {
+	row, err := q.queries.CreateRefreshToken(ctx, sqlitedb.CreateRefreshTokenParams{
+		Token:     arg.Token,
+		UserID:    arg.UserID,
+		ExpiresAt: arg.ExpiresAt,
+	})
+	return decodeRow(row, err, newRefreshToken)
+}
+
+func (q *sqliteQueries) GetRefreshTokenById(ctx context.Context, token string) (database.RefreshToken, error) {
+	row, err := q.queries.GetRefreshTokenById(ctx, token)
+	return decodeRow(row, err, newRefreshToken)
+}
+
+func (q *sqliteQueries) GetRefreshTokensByUser(ctx context.Context, userID uuid.UUID) ([]database.RefreshToken, error) {
+	rows, err := q.queries.GetRefreshTokensByUser(ctx, userID)
+	return decodeRows(rows, err, newRefreshToken)
+}
+
+func (q *sqliteQueries) GetUsernameByRefreshToken(ctx context.Context, token string) (uuid.UUID, error) {
+	return q.queries.GetUsernameByRefreshToken(ctx, token)
+}
+
+func (q *sqliteQueries) RevokeClientRefreshToken(ctx context.Context, arg database.RevokeClientRefreshTokenParams) error {
+	return q.queries.RevokeClientRefreshToken(ctx, sqlitedb.RevokeClientRefreshTokenParams(arg))
+}
+
+func (q *sqliteQueries) RevokeRefreshToken(ctx context.Context, token string) error {
+	return q.queries.RevokeRefreshToken(ctx, token)
+}
+
+func (q *sqliteQueries) RotateRefreshToken(ctx context.Context, token string) (int64, error) {
+	return q.queries.RotateRefreshToken(ctx, token)
+}
+
+func (q *sqliteQueries) CreateSubscriptionEvent(ctx context.Context, arg database.CreateSubscriptionEventParams) error {
+	return q.queries.CreateSubscriptionEvent(ctx, sqlitedb.CreateSubscriptionEventParams{
+		UserID:    arg.UserID,
+		Event:     arg.Event,
+		Status:    arg.Status,
+		PeriodEnd: arg.PeriodEnd,
+	})
+}
+
+// ExpireLapsedSubscriptions records each expiry and takes Chirpy Red away in
+// the transaction that expires the subscriptions.
+func (q *sqliteQueries) ExpireLapsedSubscriptions(ctx context.Context) (int64, error) {
+	var expired []sqlitedb.ExpireLapsedSubscriptionsRow
+	err := q.inTx(ctx, func(qtx *sqlitedb.Queries) error {
+		var err error
+		expired, err = qtx.ExpireLapsedSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+		for _, sub := range expired {
+			err = qtx.CreateSubscriptionEvent(ctx, sqlitedb.CreateSubscriptionEventParams{
+				UserID:    sub.UserID,
+				Event:     "subscription.expired",
+				Status:    "expired",
+				PeriodEnd: sub.CurrentPeriodEnd,
+			})
+			if err != nil {
+				return err
+			}
+			err = qtx.SetChirpyRed(ctx, sqlitedb.SetChirpyRedParams{IsChirpyRed: false, ID: sub.UserID})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(expired)), nil
+}
+
+func (q *sqliteQueries) GetSubscriptionByUser(ctx context.Context, userID uuid.UUID) (database.Subscription, error) {
+	row, err := q.queries.GetSubscriptionByUser(ctx, userID)
+	return database.Subscription(row), err
+}
+
+func (q *sqliteQueries) GetSubscriptionEventsByUser(ctx context.Context, userID uuid.UUID) ([]database.SubscriptionEvent, error) {
+	rows, err := q.queries.GetSubscriptionEventsByUser(ctx, userID)
+	return convertRows(rows, func(row sqlitedb.SubscriptionEvent) database.SubscriptionEvent {
+		return database.SubscriptionEvent(row)
+	}), err
+}
+
+func (q *sqliteQueries) UpsertSubscription(ctx context.Context, arg database.UpsertSubscriptionParams) (database.Subscription, error) {
+	row, err := q.queries.UpsertSubscription(ctx, sqlitedb.UpsertSubscriptionParams{
+		UserID:           arg.UserID,
+		Status:           arg.Status,
+		CurrentPeriodEnd: arg.CurrentPeriodEnd,
+	})
+	return database.Subscription(row), err
+}
+
+func (q *sqliteQueries) CancelUserDeletion(ctx context.Context, id uuid.UUID) (int64, error) {
+	return q.queries.CancelUserDeletion(ctx, id)
+}
+
+func (q *sqliteQueries) CountMentionableUsers(ctx context.Context, arg database.CountMentionableUsersParams) (int64, error) {
+	return q.queries.CountMentionableUsers(ctx, sqlitedb.CountMentionableUsersParams{
+		UserID: arg.UserID,
+		Ids:    arg.Ids,
+	})
+}
+
+func (q *sqliteQueries) CreateUser(ctx context.Context, arg database.CreateUserParams) (database.User, error) {
+	row, err := q.queries.CreateUser(ctx, sqlitedb.CreateUserParams(arg))
+	return database.User(row), uniqueViolation(err)
+}
+
+func (q *sqliteQueries) DeleteUsers(ctx context.Context) (sql.Result, error) {
+	return q.queries.DeleteUsers(ctx)
+}
+
+func (q *sqliteQueries) DeleteUsersPastGracePeriod(ctx context.Context) (int64, error) {
+	return q.queries.DeleteUsersPastGracePeriod(ctx)
+}
+
+func (q *sqliteQueries) GetUserByEmail(ctx context.Context, email string) (database.User, error) {
+	row, err := q.queries.GetUserByEmail(ctx, email)
+	return database.User(row), err
+}
+
+func (q *sqliteQueries) GetUserByID(ctx context.Context, id uuid.UUID) (database.User, error) {
+	row, err := q.queries.GetUserByID(ctx, id)
+	return database.User(row), err
+}
+
+func (q *sqliteQueries) ScheduleUserDeletion(ctx context.Context, arg database.ScheduleUserDeletionParams) (database.User, error) {
+	row, err := q.queries.ScheduleUserDeletion(ctx, sqlitedb.ScheduleUserDeletionParams{
+		DeletionScheduledAt: arg.DeletionScheduledAt,
+		ID:                  arg.ID,
+	})
+	return database.User(row), err
+}
+
+func (q *sqliteQueries) SetChirpyRed(ctx context.Context, arg database.SetChirpyRedParams) error {
+	return q.queries.SetChirpyRed(ctx, sqlitedb.SetChirpyRedParams{
+		IsChirpyRed: arg.IsChirpyRed,
+		ID:          arg.ID,
+	})
+}
+
+func (q *sqliteQueries) SetDMPolicy(ctx context.Context, arg database.SetDMPolicyParams) error {
+	return q.queries.SetDMPolicy(ctx, sqlitedb.SetDMPolicyParams{
+		DmPolicy: arg.DmPolicy,
+		ID:       arg.ID,
+	})
+}
+
+func (q *sqliteQueries) SetProtected(ctx context.Context, arg database.SetProtectedParams) error {
+	return q.queries.SetProtected(ctx, sqlitedb.SetProtectedParams{
+		Protected: arg.Protected,
+		ID:        arg.ID,
+	})
+}
+
+func (q *sqliteQueries) UpdateUsernamePassword(ctx context.Context, arg database.UpdateUsernamePasswordParams) (database.UpdateUsernamePasswordRow, error) {
+	row, err := q.queries.UpdateUsernamePassword(ctx, sqlitedb.UpdateUsernamePasswordParams{
+		Email:          arg.Email,
+		HashedPassword: arg.HashedPassword,
+		ID:             arg.ID,
+	})
+	return database.UpdateUsernamePasswordRow(row), uniqueViolation(err)
+}
+
+func (q *sqliteQueries) UpgradeToChirpyRed(ctx context.Context, id uuid.UUID) error {
+	return q.queries.UpgradeToChirpyRed(ctx, id)
+}
+
+func (q *sqliteQueries) CreateWebhookDelivery(ctx context.Context, arg database.CreateWebhookDeliveryParams) (database.WebhookDelivery, error) {
+	row, err := q.queries.CreateWebhookDelivery(ctx, sqlitedb.CreateWebhookDeliveryParams(arg))
+	return database.WebhookDelivery(row), err
+}
+
+func (q *sqliteQueries) GetWebhookDelivery(ctx context.Context, id uuid.UUID) (database.WebhookDelivery, error) {
+	row, err := q.queries.GetWebhookDelivery(ctx, id)
+	return database.WebhookDelivery(row), err
+}
+
+func (q *sqliteQueries) IncrementWebhookReplayCount(ctx context.Context, id uuid.UUID) error {
+	return q.queries.IncrementWebhookReplayCount(ctx, id)
+}
+
+func (q *sqliteQueries) ListWebhookDeliveries(ctx context.Context, arg database.ListWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	rows, err := q.queries.ListWebhookDeliveries(ctx, sqlitedb.ListWebhookDeliveriesParams{
+		Status: arg.Status,
+		Before: arg.Before,
+		Limit:  int64(arg.Limit),
+	})
+	return convertRows(rows, func(row sqlitedb.WebhookDelivery) database.WebhookDelivery { return database.WebhookDelivery(row) }), err
+}
+
+func (q *sqliteQueries) UpdateWebhookDeliveryOutcome(ctx context.Context, arg database.UpdateWebhookDeliveryOutcomeParams) (database.WebhookDelivery, error) {
+	row, err := q.queries.UpdateWebhookDeliveryOutcome(ctx, sqlitedb.UpdateWebhookDeliveryOutcomeParams{
+		Status:  arg.Status,
+		EventID: arg.EventID,
+		Event:   arg.Event,
+		Error:   arg.Error,
+		ID:      arg.ID,
+	})
+	return database.WebhookDelivery(row), err
+}
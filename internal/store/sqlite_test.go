@@ -0,0 +1,120 @@
+package store
+
+import (
+	"context"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestSQLiteReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "chirpy.db")
+
+	s, err := OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("Error opening SQLite database: %v\n", err)
+	}
+	user := newTestUser(t, s, "a@example.com")
+	s.Close()
+
+	// Migrations that have already run are skipped.
+	s, err = OpenSQLite(ctx, path)
+	if err != nil {
+		t.Fatalf("Error reopening SQLite database: %v\n", err)
+	}
+	defer s.Close()
+	got, err := s.GetUserByID(ctx, user.ID)
+	if err != nil || got.Email != user.Email {
+		t.Fatalf("Expected user %s to survive reopening, got %+v (%v)\n", user.ID, got, err)
+	}
+}
+
+func TestSQLiteChirpsOrdered(t *testing.T) {
+	ctx := context.Background()
+	s, err := OpenSQLite(ctx, filepath.Join(t.TempDir(), "chirpy.db"))
+	if err != nil {
+		t.Fatalf("Error opening SQLite database: %v\n", err)
+	}
+	defer s.Close()
+	author := newTestUser(t, s, "author@example.com")
+
+	// Chirps created within the same millisecond keep the order they were
+	// created in.
+	var ids []uuid.UUID
+	for i := 0; i < 20; i++ {
+		c, err := s.CreateChirp(ctx, database.CreateChirpParams{Body: "Hi", UserID: author.ID, Status: "published", Visibility: "public"})
+		if err != nil {
+			t.Fatalf("Error creating chirp: %v\n", err)
+		}
+		if len(c.Mentions) != 0 {
+			t.Fatalf("Expected no mentions, got %v\n", c.Mentions)
+		}
+		ids = append(ids, c.ID)
+	}
+
+	chirps, err := s.GetChirpsByAuthorId(ctx, database.GetChirpsByAuthorIdParams{UserID: author.ID})
+	if err != nil || len(chirps) != len(ids) {
+		t.Fatalf("Expected %d chirps, got %d (%v)\n", len(ids), len(chirps), err)
+	}
+	for i, c := range chirps {
+		if c.ID != ids[i] {
+			t.Fatalf("Chirps out of order at %d\n", i)
+		}
+	}
+
+	visible, err := s.GetVisibleChirpsByIds(ctx, database.GetVisibleChirpsByIdsParams{Ids: ids[:3]})
+	if err != nil || len(visible) != 3 {
+		t.Fatalf("Expected 3 chirps by id, got %d (%v)\n", len(visible), err)
+	}
+}
+
+func TestSQLiteChirpEvents(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s, err := OpenMemory(ctx)
+	if err != nil {
+		t.Fatalf("Error opening in-memory database: %v\n", err)
+	}
+	defer s.Close()
+	author := newTestUser(t, s, "author@example.com")
+
+	notified, err := s.Listen(ctx, "chirp_events")
+	if err != nil {
+		t.Fatalf("Error listening: %v\n", err)
+	}
+	_, err = s.CreateChirp(ctx, database.CreateChirpParams{
+		Body:       "Hello #Go and #go, #chirpy_2",
+		UserID:     author.ID,
+		Status:     "published",
+		Visibility: "public",
+	})
+	if err != nil {
+		t.Fatalf("Error creating chirp: %v\n", err)
+	}
+
+	select {
+	case channel := <-notified:
+		if channel != "chirp_events" {
+			t.Fatalf("Expected chirp_events to be notified, got %q\n", channel)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected a notification\n")
+	}
+
+	events, err := s.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{Limit: 10})
+	if err != nil || len(events) != 1 {
+		t.Fatalf("Expected 1 chirp event, got %d (%v)\n", len(events), err)
+	}
+	if tags := events[0].Tags; !slices.Equal(tags, []string{"chirpy_2", "go"}) {
+		t.Fatalf("Expected tags [chirpy_2 go], got %v\n", tags)
+	}
+
+	cancel()
+	for range notified {
+	}
+}
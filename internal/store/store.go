@@ -0,0 +1,40 @@
+// Package store defines the storage the API needs. Postgres implements it
+// with the sqlc-generated database.Queries, and SQLite implements it on a
+// SQLite database file, or in memory for tests.
+//
+// Methods mirror the generated queries, so implementations return
+// sql.ErrNoRows when a single row isn't found.
+package store
+
+import (
+	"context"
+	"errors"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+)
+
+// ErrDuplicateEmail is returned when creating a user, or changing their
+// email, would give two users the same email.
+var ErrDuplicateEmail = errors.New("A user with that email already exists")
+
+// Repository is the storage the API runs on.
+type Repository interface {
+	database.Querier
+	// Begin starts a transaction. Queries on the repository itself may
+	// wait for it to finish, so only the transaction is used until then.
+	Begin(ctx context.Context) (Tx, error)
+	// Listen sends the name of a channel each time it is notified, until
+	// ctx is done. The chirp_events and notifications channels are
+	// notified when rows are inserted into those tables. An empty name
+	// means notifications may have been missed.
+	Listen(ctx context.Context, channels ...string) (<-chan string, error)
+	Close() error
+}
+
+// Tx is a transaction. Rollback does nothing once it has been committed, so
+// it can be deferred.
+type Tx interface {
+	database.Querier
+	Commit() error
+	Rollback() error
+}
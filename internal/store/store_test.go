@@ -0,0 +1,267 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/google/uuid"
+)
+
+// forEachRepository runs test against an empty repository of each kind.
+// Postgres is tested as in the API tests: if TEST_DB_URL is set, which CI
+// requires, on a migrated schema whose users are deleted first.
+func forEachRepository(t *testing.T, test func(t *testing.T, r Repository)) {
+	t.Run("memory", func(t *testing.T) {
+		s, err := OpenMemory(context.Background())
+		if err != nil {
+			t.Fatalf("Error opening in-memory database: %v\n", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(t, s)
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		s, err := OpenSQLite(context.Background(), filepath.Join(t.TempDir(), "chirpy.db"))
+		if err != nil {
+			t.Fatalf("Error opening SQLite database: %v\n", err)
+		}
+		t.Cleanup(func() { s.Close() })
+		test(t, s)
+	})
+	t.Run("postgres", func(t *testing.T) {
+		dbURL := os.Getenv("TEST_DB_URL")
+		if dbURL == "" && os.Getenv("CI") != "" {
+			t.Fatal("TEST_DB_URL must be set in CI")
+		}
+		if dbURL == "" {
+			t.Skip("TEST_DB_URL is not set")
+		}
+		p, err := OpenPostgres(dbURL)
+		if err == nil {
+			_, err = p.DeleteUsers(context.Background())
+		}
+		if err != nil {
+			t.Fatalf("Error opening Postgres database: %v\n", err)
+		}
+		t.Cleanup(func() { p.Close() })
+		test(t, p)
+	})
+}
+
+func newTestUser(t *testing.T, m Repository, email string) database.User {
+	t.Helper()
+	user, err := m.CreateUser(context.Background(), database.CreateUserParams{Email: email, HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("Error creating user: %v\n", err)
+	}
+	return user
+}
+
+func TestUsers(t *testing.T) {
+	forEachRepository(t, testUsers)
+}
+
+func testUsers(t *testing.T, m Repository) {
+	ctx := context.Background()
+	user := newTestUser(t, m, "a@example.com")
+
+	_, err := m.CreateUser(ctx, database.CreateUserParams{Email: "a@example.com"})
+	if !errors.Is(err, ErrDuplicateEmail) {
+		t.Fatalf("Expected ErrDuplicateEmail, got %v\n", err)
+	}
+
+	got, err := m.GetUserByEmail(ctx, "a@example.com")
+	if err != nil || got.ID != user.ID {
+		t.Fatalf("Expected user %s, got %+v (%v)\n", user.ID, got, err)
+	}
+
+	_, err = m.GetUserByID(ctx, uuid.New())
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected sql.ErrNoRows, got %v\n", err)
+	}
+
+	updated, err := m.UpdateUsernamePassword(ctx, database.UpdateUsernamePasswordParams{
+		ID:             user.ID,
+		Email:          "b@example.com",
+		HashedPassword: "new hash",
+	})
+	if err != nil || updated.Email != "b@example.com" {
+		t.Fatalf("Expected updated email, got %+v (%v)\n", updated, err)
+	}
+	if _, err := m.GetUserByEmail(ctx, "a@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Old email should be gone, got %v\n", err)
+	}
+
+	res, err := m.DeleteUsers(ctx)
+	if err != nil {
+		t.Fatalf("Error deleting users: %v\n", err)
+	}
+	if n, _ := res.RowsAffected(); n != 1 {
+		t.Fatalf("Expected 1 user deleted, got %d\n", n)
+	}
+}
+
+func TestRefreshTokens(t *testing.T) {
+	forEachRepository(t, testRefreshTokens)
+}
+
+func testRefreshTokens(t *testing.T, m Repository) {
+	ctx := context.Background()
+	user := newTestUser(t, m, "a@example.com")
+
+	_, err := m.CreateRefreshToken(ctx, database.CreateRefreshTokenParams{
+		Token:     "token",
+		UserID:    user.ID,
+		ExpiresAt: time.Now().Add(time.Hour),
+	})
+	if err != nil {
+		t.Fatalf("Error creating refresh token: %v\n", err)
+	}
+
+	err = m.RevokeRefreshToken(ctx, "token")
+	if err != nil {
+		t.Fatalf("Error revoking refresh token: %v\n", err)
+	}
+	token, err := m.GetRefreshTokenById(ctx, "token")
+	if err != nil || !token.RevokedAt.Valid {
+		t.Fatalf("Expected a revoked token, got %+v (%v)\n", token, err)
+	}
+}
+
+func TestChirpVisibility(t *testing.T) {
+	forEachRepository(t, testChirpVisibility)
+}
+
+func testChirpVisibility(t *testing.T, m Repository) {
+	ctx := context.Background()
+	author := newTestUser(t, m, "author@example.com")
+	mentioned := newTestUser(t, m, "mentioned@example.com")
+	other := newTestUser(t, m, "other@example.com")
+
+	create := func(status, visibility string) database.Chirp {
+		c, err := m.CreateChirp(ctx, database.CreateChirpParams{
+			Body:       "Hello",
+			UserID:     author.ID,
+			Status:     status,
+			Visibility: visibility,
+			Mentions:   []uuid.UUID{mentioned.ID},
+		})
+		if err != nil {
+			t.Fatalf("Error creating chirp: %v\n", err)
+		}
+		return c
+	}
+	public := create("published", "public")
+	private := create("published", "mentioned")
+	draft := create("draft", "public")
+
+	viewer := func(u database.User) uuid.NullUUID { return uuid.NullUUID{UUID: u.ID, Valid: true} }
+	cases := []struct {
+		name   string
+		chirp  database.Chirp
+		viewer uuid.NullUUID
+		want   bool
+	}{
+		{"public to anonymous", public, uuid.NullUUID{}, true},
+		{"mentioned to anonymous", private, uuid.NullUUID{}, false},
+		{"mentioned to other user", private, viewer(other), false},
+		{"mentioned to mentioned user", private, viewer(mentioned), true},
+		{"mentioned to author", private, viewer(author), true},
+		{"draft to author", draft, viewer(author), false},
+	}
+	for _, c := range cases {
+		_, err := m.GetVisibleChirpById(ctx, database.GetVisibleChirpByIdParams{ID: c.chirp.ID, ViewerID: c.viewer})
+		if got := err == nil; got != c.want {
+			t.Errorf("%s: expected visible=%v, got %v (%v)\n", c.name, c.want, got, err)
+		}
+	}
+
+	chirps, _ := m.GetChirps(ctx, viewer(other))
+	if len(chirps) != 1 || chirps[0].ID != public.ID {
+		t.Fatalf("Expected only the public chirp, got %+v\n", chirps)
+	}
+
+	// Drafts don't count toward the rate limit.
+	n, _ := m.CountChirpsByAuthorSince(ctx, database.CountChirpsByAuthorSinceParams{
+		UserID:    author.ID,
+		CreatedAt: time.Now().Add(-time.Minute),
+	})
+	if n != 2 {
+		t.Fatalf("Expected 2 chirps, got %d\n", n)
+	}
+}
+
+func TestConcurrentUse(t *testing.T) {
+	forEachRepository(t, testConcurrentUse)
+}
+
+func testConcurrentUse(t *testing.T, m Repository) {
+	ctx := context.Background()
+	author := newTestUser(t, m, "author@example.com")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := m.CreateChirp(ctx, database.CreateChirpParams{Body: "Hi", UserID: author.ID, Status: "published", Visibility: "public"})
+			if err == nil {
+				m.GetChirps(ctx, uuid.NullUUID{})
+				m.DeleteChirpById(ctx, c.ID)
+			}
+		}()
+	}
+	wg.Wait()
+
+	chirps, _ := m.GetChirps(ctx, uuid.NullUUID{})
+	if len(chirps) != 0 {
+		t.Fatalf("Expected every chirp deleted, got %d\n", len(chirps))
+	}
+}
+
+func TestTransactions(t *testing.T) {
+	forEachRepository(t, testTransactions)
+}
+
+func testTransactions(t *testing.T, m Repository) {
+	ctx := context.Background()
+
+	tx, err := m.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Error beginning transaction: %v\n", err)
+	}
+	_, err = tx.CreateUser(ctx, database.CreateUserParams{Email: "rolled-back@example.com", HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("Error creating user: %v\n", err)
+	}
+	err = tx.Rollback()
+	if err != nil {
+		t.Fatalf("Error rolling back: %v\n", err)
+	}
+	if _, err := m.GetUserByEmail(ctx, "rolled-back@example.com"); !errors.Is(err, sql.ErrNoRows) {
+		t.Fatalf("Expected the user to be rolled back, got %v\n", err)
+	}
+
+	tx, err = m.Begin(ctx)
+	if err != nil {
+		t.Fatalf("Error beginning transaction: %v\n", err)
+	}
+	defer tx.Rollback()
+	user, err := tx.CreateUser(ctx, database.CreateUserParams{Email: "committed@example.com", HashedPassword: "hash"})
+	if err != nil {
+		t.Fatalf("Error creating user: %v\n", err)
+	}
+	err = tx.Commit()
+	if err != nil {
+		t.Fatalf("Error committing: %v\n", err)
+	}
+	if got, err := m.GetUserByID(ctx, user.ID); err != nil || got.Email != user.Email {
+		t.Fatalf("Expected the user to be committed, got %+v (%v)\n", got, err)
+	}
+}
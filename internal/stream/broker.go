@@ -0,0 +1,112 @@
+// Package stream fans events out to live subscribers, such as Server-Sent
+// Events and WebSocket connections.
+//
+// Publishing never blocks. A subscriber that falls more than its buffer
+// behind is dropped and its channel closed; clients are expected to
+// reconnect and resume from the last event they saw.
+package stream
+
+import (
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+type Event struct {
+	// IDs increase, but events that commit out of order may be published
+	// out of order.
+	ID   int64
+	Type string
+	Data []byte
+	// The user who caused the event, such as a chirp's author.
+	AuthorID uuid.UUID
+	// For notifications, the user being notified.
+	UserID uuid.UUID
+	// For chirps, the thread they belong to and their hashtags.
+	ThreadID uuid.UUID
+	Tags     []string
+	// For chirps, who they are shown to, and the users they mention, who
+	// can always see them.
+	Visibility string
+	Mentions   []uuid.UUID
+}
+
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[*Subscription]struct{}
+	closed bool
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: map[*Subscription]struct{}{}}
+}
+
+type Subscription struct {
+	broker *Broker
+	events chan Event
+}
+
+// Subscribe returns a subscription that buffers up to buffer events.
+func (b *Broker) Subscribe(buffer int) *Subscription {
+	sub := &Subscription{broker: b, events: make(chan Event, buffer)}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.events)
+		return sub
+	}
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Events is closed when the subscription is closed, dropped for falling
+// behind or the broker shuts down.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.remove(s)
+}
+
+// remove must be called with b.mu held.
+func (b *Broker) remove(s *Subscription) {
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.events)
+	}
+}
+
+// Publish sends e to every subscriber, dropping any whose buffer is full.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for sub := range b.subs {
+		select {
+		case sub.events <- e:
+		default:
+			b.remove(sub)
+		}
+	}
+}
+
+// Subscribers returns the number of live subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Close ends every subscription and refuses new ones.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.remove(sub)
+	}
+}
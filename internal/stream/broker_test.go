@@ -0,0 +1,64 @@
+package stream
+
+import (
+	"testing"
+)
+
+func TestPublishFansOut(t *testing.T) {
+	b := NewBroker()
+	a := b.Subscribe(1)
+	c := b.Subscribe(1)
+	defer a.Close()
+	defer c.Close()
+
+	b.Publish(Event{ID: 1, Type: "chirp.created"})
+
+	for _, sub := range []*Subscription{a, c} {
+		e, ok := <-sub.Events()
+		if !ok || e.ID != 1 {
+			t.Fatalf("Expected event 1, got %+v (open=%v)", e, ok)
+		}
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := NewBroker()
+	slow := b.Subscribe(1)
+	fast := b.Subscribe(2)
+	defer fast.Close()
+
+	b.Publish(Event{ID: 1})
+	b.Publish(Event{ID: 2})
+
+	if b.Subscribers() != 1 {
+		t.Fatalf("Expected the slow subscriber to be dropped, have %d subscribers", b.Subscribers())
+	}
+
+	<-slow.Events()
+	if _, ok := <-slow.Events(); ok {
+		t.Fatal("Expected the slow subscriber's channel to be closed")
+	}
+	slow.Close()
+
+	for want := int64(1); want <= 2; want++ {
+		if e := <-fast.Events(); e.ID != want {
+			t.Fatalf("Expected event %d, got %d", want, e.ID)
+		}
+	}
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(1)
+	b.Close()
+
+	if _, ok := <-sub.Events(); ok {
+		t.Fatal("Expected subscription to be closed with the broker")
+	}
+	sub.Close()
+
+	late := b.Subscribe(1)
+	if _, ok := <-late.Events(); ok {
+		t.Fatal("Expected subscriptions after Close to be closed immediately")
+	}
+}
@@ -0,0 +1,76 @@
+package unfurl
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	metaPattern  = regexp.MustCompile(`(?is)<meta\s[^>]*>`)
+	attrPattern  = regexp.MustCompile(`(?s)([a-zA-Z_:-]+)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s"'>]+))`)
+	titlePattern = regexp.MustCompile(`(?is)<title[^>]*>(.*?)</title>`)
+	spacePattern = regexp.MustCompile(`\s+`)
+)
+
+// parseMetadata reads OpenGraph tags from page, falling back to the page's
+// title and description.
+func parseMetadata(page string, pageURL *url.URL) Preview {
+	meta := map[string]string{}
+	for _, tag := range metaPattern.FindAllString(page, -1) {
+		attrs := map[string]string{}
+		for _, m := range attrPattern.FindAllStringSubmatch(tag, -1) {
+			attrs[strings.ToLower(m[1])] = m[2] + m[3] + m[4]
+		}
+
+		key := attrs["property"]
+		if key == "" {
+			key = attrs["name"]
+		}
+		key = strings.ToLower(key)
+		// The first tag wins, as it does for most unfurlers.
+		if _, ok := meta[key]; key != "" && !ok {
+			meta[key] = attrs["content"]
+		}
+	}
+
+	p := Preview{
+		Title:       meta["og:title"],
+		Description: meta["og:description"],
+		SiteName:    clean(meta["og:site_name"], MAX_TITLE_LENGTH),
+	}
+	if p.Title == "" {
+		if m := titlePattern.FindStringSubmatch(page); m != nil {
+			p.Title = m[1]
+		}
+	}
+	if p.Description == "" {
+		p.Description = meta["description"]
+	}
+	p.Title = clean(p.Title, MAX_TITLE_LENGTH)
+	p.Description = clean(p.Description, MAX_DESCRIPTION_LENGTH)
+
+	if image := strings.TrimSpace(html.UnescapeString(meta["og:image"])); image != "" {
+		u, err := pageURL.Parse(image)
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") && len(u.String()) <= MAX_URL_LENGTH {
+			p.ImageURL = u.String()
+		}
+	}
+	return p
+}
+
+// clean unescapes s, collapses its whitespace and cuts it to at most max
+// bytes without splitting a character.
+func clean(s string, max int) string {
+	s = strings.TrimSpace(spacePattern.ReplaceAllString(html.UnescapeString(s), " "))
+	if len(s) <= max {
+		return s
+	}
+	s = s[:max]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
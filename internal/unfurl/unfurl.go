@@ -0,0 +1,173 @@
+// Package unfurl fetches OpenGraph metadata for links in chirps, so they can
+// be shown as preview cards.
+//
+// Pages are fetched on behalf of users, so requests are only made to public
+// addresses: the address is checked after DNS resolution, for every
+// connection including redirects.
+package unfurl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/netguard"
+)
+
+const (
+	DEFAULT_TIMEOUT   = 5 * time.Second
+	DEFAULT_MAX_BYTES = 512 << 10
+	MAX_REDIRECTS     = 3
+	MAX_URL_LENGTH    = 2048
+	// Longer fields are cut short.
+	MAX_TITLE_LENGTH       = 300
+	MAX_DESCRIPTION_LENGTH = 1000
+)
+
+var ErrNotHTML = errors.New("Page is not HTML")
+
+// StatusError is returned by Fetch when the page responds with a non-2xx
+// status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("Page responded with status %d", e.StatusCode)
+}
+
+type Preview struct {
+	URL         string
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Fetcher fetches pages and reads their metadata.
+type Fetcher struct {
+	// Client defaults to a new NewClient(DEFAULT_TIMEOUT) for every fetch,
+	// so set it to reuse connections. Clients that don't come from
+	// NewClient aren't protected against private addresses.
+	Client *http.Client
+	// MaxBytes defaults to DEFAULT_MAX_BYTES. Only this much of a page is
+	// read.
+	MaxBytes int64
+}
+
+// NewClient returns a client that only connects to public addresses over
+// HTTP and HTTPS, ignores proxy settings and follows up to MAX_REDIRECTS
+// redirects.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: netguard.DialControl,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > MAX_REDIRECTS {
+				return errors.New("Too many redirects")
+			}
+			return checkURL(req.URL)
+		},
+	}
+}
+
+func checkURL(u *url.URL) error {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("Unsupported scheme %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return errors.New("URL has no host")
+	}
+	if u.User != nil {
+		return errors.New("URL has credentials")
+	}
+	return nil
+}
+
+// Fetch reads the metadata of the page at rawURL.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	client := f.Client
+	if client == nil {
+		client = NewClient(DEFAULT_TIMEOUT)
+	}
+	maxBytes := f.MaxBytes
+	if maxBytes == 0 {
+		maxBytes = DEFAULT_MAX_BYTES
+	}
+
+	if len(rawURL) > MAX_URL_LENGTH {
+		return Preview{}, errors.New("URL is too long")
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return Preview{}, err
+	}
+	if err := checkURL(u); err != nil {
+		return Preview{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", "Chirpy-Unfurl/1.0")
+	req.Header.Set("Accept", "text/html")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return Preview{}, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return Preview{}, StatusError{StatusCode: res.StatusCode}
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, ErrNotHTML
+	}
+
+	page, err := io.ReadAll(io.LimitReader(res.Body, maxBytes))
+	if err != nil {
+		return Preview{}, err
+	}
+
+	// Relative image URLs are resolved against the page, after redirects.
+	p := parseMetadata(string(page), res.Request.URL)
+	p.URL = rawURL
+	return p, nil
+}
+
+var urlPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// ExtractURLs returns the http and https URLs in text, in order, without
+// trailing punctuation.
+func ExtractURLs(text string) []string {
+	var urls []string
+	for _, u := range urlPattern.FindAllString(text, -1) {
+		u = strings.TrimRight(u, ".,;:!?)]}'")
+		if len(u) > MAX_URL_LENGTH {
+			continue
+		}
+		urls = append(urls, u)
+	}
+	return urls
+}
@@ -0,0 +1,149 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/netguard"
+)
+
+const (
+	TIMESTAMP_HEADER = "X-Chirpy-Timestamp"
+	SIGNATURE_HEADER = "X-Chirpy-Signature"
+	EVENT_HEADER     = "X-Chirpy-Event"
+	DELIVERY_HEADER  = "X-Chirpy-Delivery"
+)
+
+const DEFAULT_SEND_TIMEOUT = 10 * time.Second
+
+// Receivers' responses are discarded, but are read up to this size so the
+// connection can be reused.
+const maxResponseBytes = 64 << 10
+
+// StatusError is returned by Send when the receiver responds with a non-2xx
+// status.
+type StatusError struct {
+	StatusCode int
+}
+
+func (e StatusError) Error() string {
+	return fmt.Sprintf("Receiver responded with status %d", e.StatusCode)
+}
+
+// Message is a single signed delivery to a receiver.
+type Message struct {
+	URL    string
+	Secret string
+	// ID identifies the delivery, so receivers can discard duplicates.
+	ID    string
+	Event string
+	Body  []byte
+}
+
+// NewClient returns a client for delivering webhooks. Receivers are chosen
+// by users, so it only connects to public addresses, checked after DNS
+// resolution, unless allowLoopback is set for local development. Redirects
+// aren't followed; they're reported as a StatusError.
+func NewClient(timeout time.Duration, allowLoopback bool) *http.Client {
+	control := netguard.DialControl
+	if allowLoopback {
+		control = func(network, address string, c syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err == nil && addrPort.Addr().Unmap().IsLoopback() {
+				return nil
+			}
+			return netguard.DialControl(network, address, c)
+		}
+	}
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: control,
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// Sender posts signed messages to receivers.
+type Sender struct {
+	// Client defaults to NewClient(DEFAULT_SEND_TIMEOUT, false). Clients
+	// that don't come from NewClient aren't protected against private
+	// addresses.
+	Client *http.Client
+	// Now defaults to time.Now.
+	Now func() time.Time
+}
+
+// Send posts msg to its URL, signed with its secret, and returns the status
+// the receiver responded with. A non-2xx status is reported as a
+// StatusError.
+func (s *Sender) Send(ctx context.Context, msg Message) (int, error) {
+	client := s.Client
+	if client == nil {
+		client = NewClient(DEFAULT_SEND_TIMEOUT, false)
+	}
+	now := time.Now
+	if s.Now != nil {
+		now = s.Now
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, msg.URL, bytes.NewReader(msg.Body))
+	if err != nil {
+		return 0, err
+	}
+
+	sent := now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1.0")
+	req.Header.Set(TIMESTAMP_HEADER, strconv.FormatInt(sent.Unix(), 10))
+	req.Header.Set(SIGNATURE_HEADER, Sign(msg.Secret, sent, msg.Body))
+	req.Header.Set(EVENT_HEADER, msg.Event)
+	req.Header.Set(DELIVERY_HEADER, msg.ID)
+
+	res, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(res.Body, maxResponseBytes))
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, StatusError{StatusCode: res.StatusCode}
+	}
+	return res.StatusCode, nil
+}
+
+// Backoff returns how long to wait before retrying after the given number of
+// failed attempts: base doubled for each attempt after the first, capped at
+// max, plus up to 10% jitter so that retries to one receiver spread out.
+func Backoff(attempts int, base, max time.Duration) time.Duration {
+	delay := max
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts <= 32 {
+		if d := base << (attempts - 1); d > 0 && d < max {
+			delay = d
+		}
+	}
+	return delay + rand.N(delay/10+1)
+}
@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/netguard"
+)
+
+func TestSend(t *testing.T) {
+	const secret = "whsec_test"
+	body := []byte(`{"type":"chirp.created"}`)
+
+	var got *http.Request
+	var gotBody []byte
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	s := &Sender{Client: receiver.Client()}
+	status, err := s.Send(context.Background(), Message{
+		URL:    receiver.URL,
+		Secret: secret,
+		ID:     "delivery-1",
+		Event:  "chirp.created",
+		Body:   body,
+	})
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("Expected successful delivery, got status %d: %v", status, err)
+	}
+
+	if got.Header.Get(EVENT_HEADER) != "chirp.created" || got.Header.Get(DELIVERY_HEADER) != "delivery-1" {
+		t.Fatalf("Missing event headers: %v", got.Header)
+	}
+	if string(gotBody) != string(body) {
+		t.Fatalf("Expected body %s, got %s", body, gotBody)
+	}
+
+	err = Verify([]string{secret}, got.Header.Get(TIMESTAMP_HEADER), got.Header.Get(SIGNATURE_HEADER), gotBody, time.Minute, time.Now())
+	if err != nil {
+		t.Fatalf("Receiver could not verify signature: %v", err)
+	}
+}
+
+func TestSendReportsFailureStatus(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer receiver.Close()
+
+	s := &Sender{Client: receiver.Client()}
+	status, err := s.Send(context.Background(), Message{URL: receiver.URL, Secret: "s", Body: []byte("{}")})
+
+	var statusErr StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected StatusError 503, got %v", err)
+	}
+	if status != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503, got %d", status)
+	}
+}
+
+func TestSendReportsUnreachableReceiver(t *testing.T) {
+	receiver := httptest.NewServer(http.NotFoundHandler())
+	url := receiver.URL
+	receiver.Close()
+
+	s := &Sender{Client: &http.Client{Timeout: time.Second}}
+	status, err := s.Send(context.Background(), Message{URL: url, Secret: "s", Body: []byte("{}")})
+	if err == nil || status != 0 {
+		t.Fatalf("Expected connection error, got status %d: %v", status, err)
+	}
+}
+
+func TestSendRefusesPrivateAddresses(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Request reached a loopback receiver")
+	}))
+	defer receiver.Close()
+
+	s := &Sender{}
+	for _, u := range []string{receiver.URL, "http://10.0.0.1/", "http://169.254.169.254/latest/meta-data/"} {
+		status, err := s.Send(context.Background(), Message{URL: u, Secret: "s", Body: []byte("{}")})
+		if !errors.Is(err, netguard.ErrForbiddenAddress) || status != 0 {
+			t.Errorf("%s: Expected ErrForbiddenAddress, got status %d: %v", u, status, err)
+		}
+	}
+}
+
+func TestSendDoesNotFollowRedirects(t *testing.T) {
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/hook" {
+			t.Errorf("Redirect to %s was followed", r.URL.Path)
+		}
+		http.Redirect(w, r, "/elsewhere", http.StatusTemporaryRedirect)
+	}))
+	defer receiver.Close()
+
+	s := &Sender{Client: NewClient(time.Second, true)}
+	status, err := s.Send(context.Background(), Message{URL: receiver.URL + "/hook", Secret: "s", Body: []byte("{}")})
+	var statusErr StatusError
+	if !errors.As(err, &statusErr) || status != http.StatusTemporaryRedirect {
+		t.Fatalf("Expected StatusError 307, got status %d: %v", status, err)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	base := time.Second
+	max := time.Minute
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{4, 8 * time.Second},
+		{7, time.Minute},
+		{100, time.Minute},
+	}
+
+	for _, tc := range tests {
+		got := Backoff(tc.attempts, base, max)
+		if got < tc.want || got > tc.want+tc.want/10 {
+			t.Errorf("Backoff(%d) = %v, want between %v and %v", tc.attempts, got, tc.want, tc.want+tc.want/10)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package webhook signs, sends and verifies webhook payloads.
+//
+// A payload is signed by computing HMAC-SHA256 over "<timestamp>.<body>",
+// where timestamp is the Unix time the payload was sent. The timestamp and
+// signature travel in separate headers; the signature header holds one or
+// more comma separated "sha256=<hex digest>" values so that senders can sign
+// with several secrets while rotating them.
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const SIGNATURE_PREFIX = "sha256="
+
+var (
+	ErrMissingSignature = errors.New("Missing webhook signature")
+	ErrInvalidTimestamp = errors.New("Invalid webhook timestamp")
+	ErrStaleTimestamp   = errors.New("Webhook timestamp is outside the tolerance window")
+	ErrNoMatch          = errors.New("Webhook signature does not match")
+)
+
+// Sign returns the signature header value for body sent at timestamp.
+func Sign(secret string, timestamp time.Time, body []byte) string {
+	return SIGNATURE_PREFIX + hex.EncodeToString(mac(secret, timestamp.Unix(), body))
+}
+
+func mac(secret string, timestamp int64, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(h, "%d.", timestamp)
+	h.Write(body)
+	return h.Sum(nil)
+}
+
+// Verify checks that signatureHeader holds a valid signature of body under
+// any of secrets, and that timestampHeader is within tolerance of now.
+func Verify(secrets []string, timestampHeader, signatureHeader string, body []byte, tolerance time.Duration, now time.Time) error {
+	if timestampHeader == "" || signatureHeader == "" {
+		return ErrMissingSignature
+	}
+
+	ts, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return ErrInvalidTimestamp
+	}
+
+	sent := time.Unix(ts, 0)
+	if now.Sub(sent) > tolerance || sent.Sub(now) > tolerance {
+		return ErrStaleTimestamp
+	}
+
+	for _, sig := range strings.Split(signatureHeader, ",") {
+		digest, ok := strings.CutPrefix(strings.TrimSpace(sig), SIGNATURE_PREFIX)
+		if !ok {
+			continue
+		}
+		got, err := hex.DecodeString(digest)
+		if err != nil {
+			continue
+		}
+		for _, secret := range secrets {
+			if hmac.Equal(got, mac(secret, ts, body)) {
+				return nil
+			}
+		}
+	}
+	return ErrNoMatch
+}
@@ -0,0 +1,54 @@
+package webhook
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var body = []byte(`{"event": "user.upgraded", "data": {"user_id": "3311741c-680c-4546-99f3-fc9efac2036c"}}`)
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign("new-secret", now, body)
+
+	err := Verify([]string{"old-secret", "new-secret"}, ts, sig, body, 5*time.Minute, now)
+	if err != nil {
+		t.Fatalf("Valid signature rejected: %v\n", err)
+	}
+
+	err = Verify([]string{"old-secret", "new-secret"}, ts, "sha256=00,"+sig, body, 5*time.Minute, now)
+	if err != nil {
+		t.Fatalf("Valid signature among several rejected: %v\n", err)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	now := time.Now()
+	ts := strconv.FormatInt(now.Unix(), 10)
+	sig := Sign("secret", now, body)
+
+	cases := []struct {
+		name      string
+		secrets   []string
+		timestamp string
+		signature string
+		body      []byte
+		want      error
+	}{
+		{"missing signature", []string{"secret"}, ts, "", body, ErrMissingSignature},
+		{"bad timestamp", []string{"secret"}, "yesterday", sig, body, ErrInvalidTimestamp},
+		{"wrong secret", []string{"other"}, ts, sig, body, ErrNoMatch},
+		{"tampered body", []string{"secret"}, ts, sig, []byte(`{}`), ErrNoMatch},
+		{"replayed timestamp", []string{"secret"}, strconv.FormatInt(now.Add(-time.Hour).Unix(), 10), sig, body, ErrStaleTimestamp},
+	}
+
+	for _, c := range cases {
+		err := Verify(c.secrets, c.timestamp, c.signature, c.body, 5*time.Minute, now)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%s: expected %v got %v\n", c.name, c.want, err)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/unfurl"
+)
+
+const (
+	LINK_PREVIEW_POLL = 10 * time.Second
+	// A worker has this long to fetch a page before another may try.
+	LINK_PREVIEW_LEASE = time.Minute
+	// Previews are fetched again when a chirp links to them after this long.
+	LINK_PREVIEW_TTL = 7 * 24 * time.Hour
+)
+
+type linkPreviewResponse struct {
+	URL         string `json:"url"`
+	Title       string `json:"title,omitempty"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// chirpLink returns the URL a chirp's preview card is for: the first one in
+// its body.
+func chirpLink(body string) (string, bool) {
+	urls := unfurl.ExtractURLs(body)
+	if len(urls) == 0 {
+		return "", false
+	}
+	return urls[0], true
+}
+
+// queueLinkPreview asks the preview worker to fetch the page a chirp links
+// to, unless a fresh preview is already cached.
+func queueLinkPreview(ctx context.Context, q database.Querier, body string) error {
+	url, ok := chirpLink(body)
+	if !ok {
+		return nil
+	}
+	err := q.QueueLinkPreview(ctx, database.QueueLinkPreviewParams{
+		Url:         url,
+		StaleBefore: time.Now().UTC().Add(-LINK_PREVIEW_TTL),
+	})
+	if err != nil {
+		return fmt.Errorf("Error queueing link preview: %v", err)
+	}
+	return nil
+}
+
+// fetchLinkPreviews fetches pending previews until there are none left.
+func (cfg *apiConfig) fetchLinkPreviews(ctx context.Context) error {
+	for {
+		url, err := cfg.store.ClaimLinkPreview(ctx, time.Now().UTC().Add(LINK_PREVIEW_LEASE))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error claiming link preview: %v", err)
+		}
+
+		preview, fetchErr := cfg.unfurler.Fetch(ctx, url)
+		if fetchErr != nil {
+			log.Printf("Error fetching link preview for %s: %v\n", url, fetchErr)
+			err = cfg.store.FailLinkPreview(ctx, url)
+		} else {
+			err = cfg.store.SaveLinkPreview(ctx, database.SaveLinkPreviewParams{
+				Url:         url,
+				Title:       preview.Title,
+				Description: preview.Description,
+				ImageUrl:    preview.ImageURL,
+				SiteName:    preview.SiteName,
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("Error saving link preview for %s: %v", url, err)
+		}
+	}
+}
+
+// linkPreviews returns the cached preview cards for chirps, by URL. Chirps
+// whose previews haven't been fetched, or couldn't be, have none.
+func (cfg *apiConfig) linkPreviews(ctx context.Context, chirps []database.Chirp) (map[string]linkPreviewResponse, error) {
+	urls := []string{}
+	for _, c := range chirps {
+		if url, ok := chirpLink(c.Body); ok {
+			urls = append(urls, url)
+		}
+	}
+	previews := map[string]linkPreviewResponse{}
+	if len(urls) == 0 {
+		return previews, nil
+	}
+
+	rows, err := cfg.store.GetLinkPreviews(ctx, urls)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range rows {
+		previews[p.Url] = linkPreviewResponse{
+			URL:         p.Url,
+			Title:       p.Title,
+			Description: p.Description,
+			ImageURL:    p.ImageUrl,
+			SiteName:    p.SiteName,
+		}
+	}
+	return previews, nil
+}
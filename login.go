@@ -1,13 +1,19 @@
 package main
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/caleb-fringer/chirpy/internal/apierror"
 	"github.com/caleb-fringer/chirpy/internal/auth"
 	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
 	"github.com/google/uuid"
 )
 
@@ -28,27 +34,26 @@ type loginRequestParams struct {
 
 func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 	reqParams := &loginRequestParams{}
-	reqDecoder := json.NewDecoder(r.Body)
-	err := reqDecoder.Decode(reqParams)
+	err := request.DecodeJSON(w, r, reqParams)
 	if err != nil {
-		log.Printf("POST /api/login: Error decoding request: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Server error decoding request."`))
+		apierror.Write(w, err)
 		return
 	}
 
-	user, err := cfg.queries.GetUserByEmail(r.Context(), reqParams.Email)
+	user, err := cfg.store.GetUserByEmail(r.Context(), reqParams.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.Unauthorized("Incorrect email or password"))
+		return
+	}
 	if err != nil {
 		log.Printf("POST /api/login: Error retrieving user from database: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Database error"`))
+		apierror.Write(w, apierror.Internal("Database error"))
 		return
 	}
 
 	err = auth.CheckPasswordHash(reqParams.Password, user.HashedPassword)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`"error": "Incorrect email or password"`))
+		apierror.Write(w, apierror.Unauthorized("Incorrect email or password"))
 		return
 	}
 
@@ -57,37 +62,53 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 		tokenDuration = time.Hour
 	}
 
-	token, err := auth.MakeJWT(user.ID, cfg.secretKey)
+	response, err := cfg.newSession(r.Context(), user, tokenDuration)
 	if err != nil {
-		log.Printf("POST /api/login: Error making JWT: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error creating JWT"`))
+		log.Printf("POST /api/login: Error creating session: %v\n", err)
+		apierror.Write(w, apierror.Internal("Error creating session"))
 		return
 	}
 
-	refreshTokenStr, err := auth.MakeRefreshToken()
+	resJson, err := json.Marshal(response)
 	if err != nil {
-		log.Printf("POST /api/login: Error making refresh token: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error creating refresh token"`))
+		log.Printf("POST /api/login: Error encoding response: %v", err)
+		apierror.Write(w, apierror.Internal("Server error encoding response."))
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(resJson)
+	return
+}
+
+// newSession issues a new access token and refresh token for user.
+func (cfg *apiConfig) newSession(ctx context.Context, user database.User, tokenDuration time.Duration) (*loginResponse, error) {
+	token, err := auth.MakeJWT(user.ID, cfg.secretKey,
+		auth.WithTTL(tokenDuration),
+		auth.WithChirpyRed(user.IsChirpyRed),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Error making JWT: %v", err)
+	}
+
+	refreshTokenStr, err := auth.MakeRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
 	refreshTokenParams := database.CreateRefreshTokenParams{
 		Token:     refreshTokenStr,
 		UserID:    user.ID,
 		ExpiresAt: time.Now().UTC().Add(time.Hour),
 	}
 
-	refreshToken, err := cfg.queries.CreateRefreshToken(r.Context(), refreshTokenParams)
+	refreshToken, err := cfg.store.CreateRefreshToken(ctx, refreshTokenParams)
 	if err != nil {
-		log.Printf("POST /api/login: Error storing refresh token in database: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error creating refresh token"`))
-		return
+		return nil, fmt.Errorf("Error storing refresh token in database: %v", err)
 	}
 
-	response := &loginResponse{
+	return &loginResponse{
 		ID:           user.ID,
 		CreatedAt:    user.CreatedAt,
 		UpdatedAt:    user.UpdatedAt,
@@ -95,17 +116,5 @@ func (cfg *apiConfig) login(w http.ResponseWriter, r *http.Request) {
 		IsChirpyRed:  user.IsChirpyRed,
 		Token:        token,
 		RefreshToken: refreshToken.Token,
-	}
-
-	resJson, err := json.Marshal(response)
-	if err != nil {
-		log.Printf("POST /api/login: Error encoding response: %v", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Server error encoding response."`))
-		return
-	}
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(resJson)
-	return
+	}, nil
 }
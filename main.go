@@ -1,27 +1,60 @@
 package main
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"strings"
 	"sync/atomic"
-
-	"github.com/caleb-fringer/chirpy/internal/database"
+	"syscall"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/impressions"
+	"github.com/caleb-fringer/chirpy/internal/oidc"
+	"github.com/caleb-fringer/chirpy/internal/store"
+	"github.com/caleb-fringer/chirpy/internal/stream"
+	"github.com/caleb-fringer/chirpy/internal/unfurl"
+	"github.com/caleb-fringer/chirpy/internal/webhook"
 	"github.com/joho/godotenv"
-	_ "github.com/lib/pq"
 )
 
 const PORT = 8080
 const ROOT_PATH = "."
 
+// How long requests and WebSocket connections get to finish on shutdown.
+const SHUTDOWN_TIMEOUT = 15 * time.Second
+
+// DB_URLs starting with SQLITE_SCHEME name a SQLite database file, such as
+// sqlite://chirpy.db or sqlite:///var/lib/chirpy/chirpy.db. Anything else is
+// a Postgres connection string.
+const SQLITE_SCHEME = "sqlite://"
+
 type apiConfig struct {
-	platform  string
-	fsHits    atomic.Int32
-	queries   *database.Queries
+	platform string
+	fsHits   atomic.Int32
+	// Postgres or SQLite, depending on DB_URL.
+	store     store.Repository
 	secretKey string
 	polkaKey  string
+	adminKey  string
+	// Secrets Polka signs webhooks with. More than one may be active while
+	// rotating.
+	polkaSecrets []string
+	oidc         *oidc.Provider
+	// Sends outbound webhooks to integrators.
+	webhookSender *webhook.Sender
+	// Fetches link previews for chirps.
+	unfurler *unfurl.Fetcher
+	// Buffers chirp impressions until they are flushed.
+	impressions *impressions.Counter
+	// Fans chirp events and notifications out to live streams.
+	chirpStream        *stream.Broker
+	notificationStream *stream.Broker
+	sockets            *socketHub
 }
 
 func main() {
@@ -30,53 +63,114 @@ func main() {
 	platform := os.Getenv("PLATFORM")
 	secretKey := os.Getenv("SECRET_KEY")
 	polkaKey := os.Getenv("POLKA_KEY")
-	db, err := sql.Open("postgres", dbURL)
+	adminKey := os.Getenv("ADMIN_KEY")
+	polkaSecrets := strings.FieldsFunc(os.Getenv("POLKA_WEBHOOK_SECRETS"), func(r rune) bool { return r == ',' })
+
+	apiCfg := &apiConfig{
+		platform:      platform,
+		secretKey:     secretKey,
+		polkaKey:      polkaKey,
+		adminKey:      adminKey,
+		webhookSender: &webhook.Sender{Client: webhook.NewClient(webhook.DEFAULT_SEND_TIMEOUT, platform == "dev")},
+		unfurler:      &unfurl.Fetcher{Client: unfurl.NewClient(unfurl.DEFAULT_TIMEOUT)},
+		impressions:   &impressions.Counter{},
+		chirpStream:   stream.NewBroker(),
+		polkaSecrets:  polkaSecrets,
+
+		notificationStream: stream.NewBroker(),
+		sockets:            newSocketHub(),
+	}
 
+	err := apiCfg.openDatabase(context.Background(), dbURL)
 	if err != nil {
 		log.Fatalf("Error connecting to the database: %v\n", err)
 	}
 
-	dbQueries := database.New(db)
-	apiCfg := &apiConfig{
-		platform:  platform,
-		queries:   dbQueries,
-		secretKey: secretKey,
-		polkaKey:  polkaKey,
+	if len(polkaSecrets) == 0 {
+		log.Println("Warning: POLKA_WEBHOOK_SECRETS is not set; Polka webhooks are only authenticated by API key")
+	}
+
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		provider, err := oidc.NewProvider(context.Background(), oidc.Config{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_REDIRECT_URL"),
+		})
+		if err != nil {
+			log.Fatalf("Error configuring OpenID Connect provider %s: %v\n", issuer, err)
+		}
+		apiCfg.oidc = provider
 	}
 
-	mux := http.NewServeMux()
 	server := http.Server{
 		Addr:    fmt.Sprintf(":%d", PORT),
-		Handler: mux,
+		Handler: apiCfg.routes(),
 	}
 
-	mux.Handle("/app/", apiCfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(ROOT_PATH)))))
-
-	mux.HandleFunc("GET /api/healthz", healthz)
-
-	mux.HandleFunc("POST /api/users", apiCfg.createUser)
-
-	mux.HandleFunc("POST /admin/reset", apiCfg.reset)
-
-	mux.Handle("GET /admin/metrics", apiCfg)
-
-	mux.HandleFunc("POST /api/chirps", apiCfg.createChirp)
-
-	mux.HandleFunc("GET /api/chirps", apiCfg.getChirps)
-
-	mux.HandleFunc("GET /api/chirps/{id}", apiCfg.getChirp)
-
-	mux.HandleFunc("POST /api/login", apiCfg.login)
-
-	mux.HandleFunc("POST /api/refresh", apiCfg.refresh)
-
-	mux.HandleFunc("POST /api/revoke", apiCfg.handlerRevoke)
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go apiCfg.listenEventLogs(ctx)
+	go runPeriodically(ctx, "Data exports", DATA_EXPORT_POLL, apiCfg.processDataExports)
+	go runPeriodically(ctx, "Account purge", ACCOUNT_PURGE_INTERVAL, apiCfg.purgeDeletedUsers)
+	go runPeriodically(ctx, "Subscription expiry", SUBSCRIPTION_EXPIRY_INTERVAL, apiCfg.expireSubscriptions)
+	go runPeriodically(ctx, "Outbound webhooks", OUTBOUND_WEBHOOK_POLL, apiCfg.deliverOutboundWebhooks)
+	go runPeriodically(ctx, "Scheduled chirps", CHIRP_PUBLISH_POLL, apiCfg.publishScheduledChirps)
+	go runPeriodically(ctx, "Chirp event cleanup", CHIRP_EVENT_CLEANUP_INTERVAL, apiCfg.deleteOldChirpEvents)
+	go runPeriodically(ctx, "Notification cleanup", NOTIFICATION_CLEANUP_INTERVAL, apiCfg.deleteOldNotifications)
+	go runPeriodically(ctx, "Link previews", LINK_PREVIEW_POLL, apiCfg.fetchLinkPreviews)
+	go runPeriodically(ctx, "Impressions", IMPRESSION_FLUSH_INTERVAL, apiCfg.flushImpressions)
+
+	go func() {
+		fmt.Printf("Starting server on port %d...\n", PORT)
+		err := server.ListenAndServe()
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), SHUTDOWN_TIMEOUT)
+	defer cancel()
+
+	// WebSockets send what they have buffered and close. Closing the
+	// brokers ends event streams, which Shutdown would otherwise wait on.
+	apiCfg.sockets.close()
+	apiCfg.chirpStream.Close()
+	apiCfg.notificationStream.Close()
+
+	err = server.Shutdown(shutdownCtx)
+	if err != nil {
+		log.Printf("Error shutting down server: %v\n", err)
+	}
+	err = apiCfg.sockets.wait(shutdownCtx)
+	if err != nil {
+		log.Printf("Error draining WebSocket connections: %v\n", err)
+	}
 
-	mux.HandleFunc("PUT /api/users", apiCfg.updateUser)
+	// Requests have finished, so nothing more will be buffered.
+	err = apiCfg.flushImpressions(shutdownCtx)
+	if err != nil {
+		log.Printf("Error flushing impressions: %v\n", err)
+	}
+}
 
-	mux.HandleFunc("DELETE /api/chirps/{chirpID}", apiCfg.deleteChirp)
+// openDatabase connects to the database dbURL names.
+func (cfg *apiConfig) openDatabase(ctx context.Context, dbURL string) error {
+	if path, ok := strings.CutPrefix(dbURL, SQLITE_SCHEME); ok {
+		s, err := store.OpenSQLite(ctx, path)
+		if err != nil {
+			return err
+		}
+		cfg.store = s
+		return nil
+	}
 
-	mux.HandleFunc("POST /api/polka/webhooks", apiCfg.subscribe)
-	fmt.Printf("Starting server on port %d...\n", PORT)
-	log.Fatal(server.ListenAndServe())
+	p, err := store.OpenPostgres(dbURL)
+	if err != nil {
+		return err
+	}
+	cfg.store = p
+	return nil
 }
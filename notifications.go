@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/caleb-fringer/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+// Live clients are sent notifications as events of this type.
+const NOTIFICATION_EVENT = "notification"
+
+const (
+	NOTIFICATION_RETENTION         = 90 * 24 * time.Hour
+	NOTIFICATION_CLEANUP_INTERVAL  = time.Hour
+	DEFAULT_NOTIFICATION_PAGE_SIZE = 50
+	MAX_NOTIFICATION_PAGE_SIZE     = 200
+)
+
+type notificationResponse struct {
+	ID        int64      `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   uuid.UUID  `json:"actor_id"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+}
+
+func newNotificationResponse(n database.Notification) notificationResponse {
+	res := notificationResponse{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      n.Type,
+		ActorID:   n.ActorID,
+	}
+	if n.ChirpID.Valid {
+		res.ChirpID = &n.ChirpID.UUID
+	}
+	return res
+}
+
+func notificationStreamEvent(n database.Notification) (stream.Event, bool, error) {
+	data, err := json.Marshal(newNotificationResponse(n))
+	if err != nil {
+		return stream.Event{}, false, err
+	}
+	return stream.Event{
+		ID:       n.ID,
+		Type:     NOTIFICATION_EVENT,
+		Data:     data,
+		AuthorID: n.ActorID,
+		UserID:   n.UserID,
+	}, true, nil
+}
+
+// deleteOldNotifications trims the notification log.
+func (cfg *apiConfig) deleteOldNotifications(ctx context.Context) error {
+	_, err := cfg.store.DeleteNotificationsBefore(ctx, time.Now().UTC().Add(-NOTIFICATION_RETENTION))
+	if err != nil {
+		return fmt.Errorf("Error deleting old notifications: %v", err)
+	}
+	return nil
+}
+
+// getNotifications returns the user's notifications, newest first. Pages are
+// fetched by passing the last id seen as before.
+func (cfg *apiConfig) getNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	query := r.URL.Query()
+	params := database.GetNotificationsByUserParams{
+		UserID: userID,
+		ID:     math.MaxInt64,
+		Limit:  DEFAULT_NOTIFICATION_PAGE_SIZE,
+	}
+
+	if before := query.Get("before"); before != "" {
+		id, err := strconv.ParseInt(before, 10, 64)
+		if err != nil {
+			apierror.Write(w, request.FieldErrors{"before": "must be a notification id"}.Err())
+			return
+		}
+		params.ID = id
+	}
+
+	limit, err := request.QueryInt(r, "limit", DEFAULT_NOTIFICATION_PAGE_SIZE, 1, MAX_NOTIFICATION_PAGE_SIZE)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+	params.Limit = int32(limit)
+
+	notifications, err := cfg.store.GetNotificationsByUser(r.Context(), params)
+	if err != nil {
+		log.Printf("GET /api/notifications: Error retrieving notifications: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	hidden, err := cfg.hiddenAuthors(r.Context(), uuid.NullUUID{UUID: userID, Valid: true})
+	if err != nil {
+		log.Printf("GET /api/notifications: Error retrieving hidden users: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	// Notifications from blocked and muted users are kept, so they come back
+	// if the user is unblocked or unmuted.
+	res := make([]notificationResponse, 0, len(notifications))
+	for _, n := range notifications {
+		if _, ok := hidden[n.ActorID]; ok {
+			continue
+		}
+		res = append(res, newNotificationResponse(n))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/notifications: Error writing response: %v\n", err)
+	}
+}
@@ -0,0 +1,600 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+const (
+	AUTHORIZATION_CODE_TTL  = 10 * time.Minute
+	OAUTH_ACCESS_TOKEN_TTL  = time.Hour
+	OAUTH_REFRESH_TOKEN_TTL = 60 * 24 * time.Hour
+)
+
+type createOAuthClientReqParams struct {
+	Name         string   `json:"name"`
+	RedirectURIs []string `json:"redirect_uris"`
+	Scopes       []string `json:"scopes"`
+	// Public clients, such as mobile and single page apps, can't keep a
+	// secret and rely on PKCE alone.
+	Public bool `json:"public"`
+}
+
+type oauthClientResponse struct {
+	ClientID     uuid.UUID `json:"client_id"`
+	CreatedAt    time.Time `json:"created_at"`
+	Name         string    `json:"name"`
+	RedirectURIs []string  `json:"redirect_uris"`
+	Scopes       []string  `json:"scopes"`
+	ClientSecret string    `json:"client_secret,omitempty"`
+}
+
+type oauthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	Scope        string `json:"scope"`
+}
+
+// oauthError is an error response defined by RFC 6749.
+type oauthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e oauthError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+func writeOAuthError(w http.ResponseWriter, status int, code, description string) {
+	rawRes, err := json.Marshal(oauthError{Code: code, Description: description})
+	if err != nil {
+		log.Printf("Error marshalling OAuth error: %v\n", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(rawRes)
+}
+
+func validRedirectURI(rawURI string) bool {
+	uri, err := url.Parse(rawURI)
+	if err != nil || uri.Fragment != "" || uri.Host == "" {
+		return false
+	}
+	return uri.Scheme == "https" || (uri.Scheme == "http" && uri.Hostname() == "localhost")
+}
+
+func (cfg *apiConfig) createOAuthClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	reqBody := &createOAuthClientReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	if reqBody.Name == "" {
+		apierror.Write(w, apierror.BadRequest("Please provide a name for the client."))
+		return
+	}
+
+	if len(reqBody.RedirectURIs) == 0 {
+		apierror.Write(w, apierror.BadRequest("Please provide at least one redirect URI."))
+		return
+	}
+	for _, uri := range reqBody.RedirectURIs {
+		if !validRedirectURI(uri) {
+			apierror.Write(w, apierror.BadRequest(fmt.Sprintf("Invalid redirect URI %q. Redirect URIs must use https.", uri)))
+			return
+		}
+	}
+
+	if len(reqBody.Scopes) == 0 {
+		apierror.Write(w, apierror.BadRequest("Please request at least one scope."))
+		return
+	}
+	for _, scope := range reqBody.Scopes {
+		if _, ok := auth.Scopes[scope]; !ok {
+			apierror.Write(w, apierror.BadRequest(fmt.Sprintf("Unknown scope %q", scope)))
+			return
+		}
+	}
+
+	params := database.CreateOAuthClientParams{
+		UserID:       userID,
+		Name:         reqBody.Name,
+		RedirectUris: reqBody.RedirectURIs,
+		Scopes:       reqBody.Scopes,
+	}
+
+	var secret string
+	if !reqBody.Public {
+		secret, err = auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("POST /api/oauth/clients: Error creating client secret: %v\n", err)
+			apierror.Write(w, apierror.Internal("Server error"))
+			return
+		}
+		params.SecretHash = sql.NullString{String: auth.HashToken(secret), Valid: true}
+	}
+
+	client, err := cfg.store.CreateOAuthClient(r.Context(), params)
+	if err != nil {
+		log.Printf("POST /api/oauth/clients: Error storing client: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	// The plaintext secret is only ever returned here.
+	res := oauthClientResponse{
+		ClientID:     client.ID,
+		CreatedAt:    client.CreatedAt,
+		Name:         client.Name,
+		RedirectURIs: client.RedirectUris,
+		Scopes:       client.Scopes,
+		ClientSecret: secret,
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("POST /api/oauth/clients: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) deleteOAuthClient(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	clientID, err := request.PathUUID(r, "clientID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	// Authorization codes and refresh tokens issued to the client are
+	// deleted along with it.
+	rows, err := cfg.store.DeleteOAuthClient(r.Context(), database.DeleteOAuthClientParams{
+		ID:     clientID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/oauth/clients/%s: Error deleting client: %v\n", clientID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("Client not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type authorizeRequest struct {
+	Client        database.OauthClient
+	RedirectURI   string
+	State         string
+	Scopes        []string
+	CodeChallenge string
+}
+
+// parseAuthorizeRequest validates the parameters of an authorization
+// request. Errors about the client or redirect URI are plain errors and must
+// be shown to the user; an oauthError is returned for everything else, and
+// should be reported to the client through its redirect URI.
+func (cfg *apiConfig) parseAuthorizeRequest(ctx context.Context, values url.Values) (*authorizeRequest, error) {
+	clientID, err := uuid.Parse(values.Get("client_id"))
+	if err != nil {
+		return nil, errors.New("Invalid client_id")
+	}
+
+	client, err := cfg.store.GetOAuthClientByID(ctx, clientID)
+	if err != nil {
+		return nil, errors.New("Unknown client")
+	}
+
+	req := &authorizeRequest{
+		Client:      client,
+		RedirectURI: values.Get("redirect_uri"),
+		State:       values.Get("state"),
+	}
+	if !slices.Contains(client.RedirectUris, req.RedirectURI) {
+		return nil, errors.New("redirect_uri is not registered for this client")
+	}
+
+	if values.Get("response_type") != "code" {
+		return req, oauthError{"unsupported_response_type", "Only the code response type is supported"}
+	}
+
+	req.CodeChallenge = values.Get("code_challenge")
+	if req.CodeChallenge == "" || values.Get("code_challenge_method") != auth.PKCE_METHOD_S256 {
+		return req, oauthError{"invalid_request", "PKCE with the S256 method is required"}
+	}
+
+	req.Scopes = auth.ParseScope(values.Get("scope"))
+	if len(req.Scopes) == 0 {
+		req.Scopes = client.Scopes
+	}
+	for _, scope := range req.Scopes {
+		if !slices.Contains(client.Scopes, scope) {
+			return req, oauthError{"invalid_scope", fmt.Sprintf("Scope %q is not allowed for this client", scope)}
+		}
+	}
+
+	return req, nil
+}
+
+// redirectToClient sends the user agent back to the client with params added
+// to the redirect URI's query.
+func redirectToClient(w http.ResponseWriter, r *http.Request, req *authorizeRequest, params url.Values) {
+	uri, err := url.Parse(req.RedirectURI)
+	if err != nil {
+		http.Error(w, "Invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+
+	query := uri.Query()
+	for k, v := range params {
+		query[k] = v
+	}
+	if req.State != "" {
+		query.Set("state", req.State)
+	}
+	uri.RawQuery = query.Encode()
+
+	http.Redirect(w, r, uri.String(), http.StatusFound)
+}
+
+var consentTemplate = template.Must(template.New("consent").Parse(`
+    <html>
+        <body>
+            <h1>Authorize {{.Client.Name}}</h1>
+            <p>{{.Client.Name}} would like to:</p>
+            <ul>
+                {{range .Scopes}}<li>{{.}}</li>{{end}}
+            </ul>
+            {{if .Error}}<p><strong>{{.Error}}</strong></p>{{end}}
+            <form method="POST" action="/oauth/authorize">
+                <input type="hidden" name="response_type" value="code">
+                <input type="hidden" name="client_id" value="{{.Client.ID}}">
+                <input type="hidden" name="redirect_uri" value="{{.RedirectURI}}">
+                <input type="hidden" name="state" value="{{.State}}">
+                <input type="hidden" name="scope" value="{{.Scope}}">
+                <input type="hidden" name="code_challenge" value="{{.CodeChallenge}}">
+                <input type="hidden" name="code_challenge_method" value="S256">
+                <label>Email <input type="email" name="email"></label>
+                <label>Password <input type="password" name="password"></label>
+                <button type="submit" name="decision" value="approve">Allow</button>
+                <button type="submit" name="decision" value="deny">Deny</button>
+            </form>
+        </body>
+    </html>`))
+
+func renderConsentPage(w http.ResponseWriter, status int, req *authorizeRequest, errMsg string) {
+	descriptions := make([]string, 0, len(req.Scopes))
+	for _, scope := range req.Scopes {
+		descriptions = append(descriptions, auth.Scopes[scope])
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	err := consentTemplate.Execute(w, map[string]any{
+		"Client":        req.Client,
+		"RedirectURI":   req.RedirectURI,
+		"State":         req.State,
+		"Scope":         strings.Join(req.Scopes, " "),
+		"Scopes":        descriptions,
+		"CodeChallenge": req.CodeChallenge,
+		"Error":         errMsg,
+	})
+	if err != nil {
+		log.Printf("Error rendering consent page: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) authorize(w http.ResponseWriter, r *http.Request) {
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.URL.Query())
+	if err != nil {
+		var oauthErr oauthError
+		if req != nil && errors.As(err, &oauthErr) {
+			redirectToClient(w, r, req, url.Values{
+				"error":             {oauthErr.Code},
+				"error_description": {oauthErr.Description},
+			})
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	renderConsentPage(w, http.StatusOK, req, "")
+}
+
+func (cfg *apiConfig) approveAuthorization(w http.ResponseWriter, r *http.Request) {
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, "Bad request body", http.StatusBadRequest)
+		return
+	}
+
+	req, err := cfg.parseAuthorizeRequest(r.Context(), r.PostForm)
+	if err != nil {
+		var oauthErr oauthError
+		if req != nil && errors.As(err, &oauthErr) {
+			redirectToClient(w, r, req, url.Values{
+				"error":             {oauthErr.Code},
+				"error_description": {oauthErr.Description},
+			})
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if r.PostForm.Get("decision") != "approve" {
+		redirectToClient(w, r, req, url.Values{"error": {"access_denied"}})
+		return
+	}
+
+	user, err := cfg.store.GetUserByEmail(r.Context(), r.PostForm.Get("email"))
+	if err == nil {
+		err = auth.CheckPasswordHash(r.PostForm.Get("password"), user.HashedPassword)
+	}
+	if err != nil {
+		renderConsentPage(w, http.StatusUnauthorized, req, "Incorrect email or password")
+		return
+	}
+
+	code, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("POST /oauth/authorize: Error creating authorization code: %v\n", err)
+		redirectToClient(w, r, req, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	err = cfg.store.CreateAuthorizationCode(r.Context(), database.CreateAuthorizationCodeParams{
+		CodeHash:      auth.HashToken(code),
+		ClientID:      req.Client.ID,
+		UserID:        user.ID,
+		RedirectUri:   req.RedirectURI,
+		Scopes:        req.Scopes,
+		CodeChallenge: req.CodeChallenge,
+		ExpiresAt:     time.Now().UTC().Add(AUTHORIZATION_CODE_TTL),
+	})
+	if err != nil {
+		log.Printf("POST /oauth/authorize: Error storing authorization code: %v\n", err)
+		redirectToClient(w, r, req, url.Values{"error": {"server_error"}})
+		return
+	}
+
+	redirectToClient(w, r, req, url.Values{"code": {code}})
+}
+
+// authenticateOAuthClient identifies the client calling the token or
+// revocation endpoint, using HTTP Basic credentials or the client_id and
+// client_secret form parameters. Public clients have no secret.
+func (cfg *apiConfig) authenticateOAuthClient(r *http.Request) (database.OauthClient, error) {
+	rawID, secret, ok := r.BasicAuth()
+	if !ok {
+		rawID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	clientID, err := uuid.Parse(rawID)
+	if err != nil {
+		return database.OauthClient{}, errors.New("Invalid client_id")
+	}
+
+	client, err := cfg.store.GetOAuthClientByID(r.Context(), clientID)
+	if err != nil {
+		return database.OauthClient{}, errors.New("Unknown client")
+	}
+
+	if client.SecretHash.Valid && !auth.CheckTokenHash(secret, client.SecretHash.String) {
+		return database.OauthClient{}, errors.New("Invalid client credentials")
+	}
+	return client, nil
+}
+
+func (cfg *apiConfig) oauthToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Bad request body")
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	// The grant is redeemed in the same transaction that issues the new
+	// tokens.
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("POST /oauth/token: Error starting transaction: %v\n", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+	defer tx.Rollback()
+
+	var userID uuid.UUID
+	var scopes []string
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		code, err := tx.ConsumeAuthorizationCode(r.Context(), auth.HashToken(r.PostForm.Get("code")))
+		if err != nil {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid or expired authorization code")
+			return
+		}
+		if code.ClientID != client.ID || code.RedirectUri != r.PostForm.Get("redirect_uri") {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Authorization code was issued to another client or redirect URI")
+			return
+		}
+		if !auth.VerifyPKCE(r.PostForm.Get("code_verifier"), code.CodeChallenge) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid code_verifier")
+			return
+		}
+		userID, scopes = code.UserID, code.Scopes
+
+	case "refresh_token":
+		tokenStr := r.PostForm.Get("refresh_token")
+		token, err := tx.GetRefreshTokenById(r.Context(), tokenStr)
+		if err != nil || !token.ClientID.Valid || token.ClientID.UUID != client.ID {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Invalid refresh token")
+			return
+		}
+		if token.RevokedAt.Valid || time.Now().UTC().After(token.ExpiresAt) {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Expired or revoked refresh token")
+			return
+		}
+
+		// Clients may ask for a subset of the originally granted scopes.
+		scopes = token.Scopes
+		if requested := auth.ParseScope(r.PostForm.Get("scope")); len(requested) > 0 {
+			for _, scope := range requested {
+				if !slices.Contains(token.Scopes, scope) {
+					writeOAuthError(w, http.StatusBadRequest, "invalid_scope", fmt.Sprintf("Scope %q was not granted", scope))
+					return
+				}
+			}
+			scopes = requested
+		}
+
+		// Refresh tokens are rotated on every use. If another request
+		// redeemed this one first, nothing is rotated and this one fails.
+		rows, err := tx.RotateRefreshToken(r.Context(), tokenStr)
+		if err != nil {
+			log.Printf("POST /oauth/token: Error revoking refresh token: %v\n", err)
+			writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+			return
+		}
+		if rows == 0 {
+			writeOAuthError(w, http.StatusBadRequest, "invalid_grant", "Expired or revoked refresh token")
+			return
+		}
+		userID = token.UserID
+
+	default:
+		writeOAuthError(w, http.StatusBadRequest, "unsupported_grant_type", "")
+		return
+	}
+
+	res, err := cfg.issueOAuthTokens(r.Context(), tx, client, userID, scopes)
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("POST /oauth/token: Error issuing tokens: %v\n", err)
+		writeOAuthError(w, http.StatusInternalServerError, "server_error", "")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("POST /oauth/token: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) issueOAuthTokens(ctx context.Context, q database.Querier, client database.OauthClient, userID uuid.UUID, scopes []string) (*oauthTokenResponse, error) {
+	// A token without scopes would be treated as a first-party session.
+	if len(scopes) == 0 {
+		return nil, errors.New("Refusing to issue an OAuth token without scopes")
+	}
+
+	accessToken, err := auth.MakeJWT(userID, cfg.secretKey,
+		auth.WithTTL(OAUTH_ACCESS_TOKEN_TTL),
+		auth.WithScopes(scopes...),
+		auth.WithClientID(client.ID.String()),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokenStr, err := auth.MakeRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = q.CreateOAuthRefreshToken(ctx, database.CreateOAuthRefreshTokenParams{
+		Token:     refreshTokenStr,
+		UserID:    userID,
+		ExpiresAt: time.Now().UTC().Add(OAUTH_REFRESH_TOKEN_TTL),
+		ClientID:  uuid.NullUUID{UUID: client.ID, Valid: true},
+		Scopes:    scopes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &oauthTokenResponse{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(OAUTH_ACCESS_TOKEN_TTL.Seconds()),
+		RefreshToken: refreshTokenStr,
+		Scope:        strings.Join(scopes, " "),
+	}, nil
+}
+
+// oauthRevoke implements RFC 7009 token revocation for refresh tokens.
+// Access tokens are short-lived JWTs and can't be revoked.
+func (cfg *apiConfig) oauthRevoke(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	err := r.ParseForm()
+	if err != nil {
+		writeOAuthError(w, http.StatusBadRequest, "invalid_request", "Bad request body")
+		return
+	}
+
+	client, err := cfg.authenticateOAuthClient(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Basic realm="chirpy"`)
+		writeOAuthError(w, http.StatusUnauthorized, "invalid_client", err.Error())
+		return
+	}
+
+	err = cfg.store.RevokeClientRefreshToken(r.Context(), database.RevokeClientRefreshTokenParams{
+		Token:    r.PostForm.Get("token"),
+		ClientID: uuid.NullUUID{UUID: client.ID, Valid: true},
+	})
+	if err != nil {
+		log.Printf("POST /oauth/revoke: Error revoking refresh token: %v\n", err)
+		writeOAuthError(w, http.StatusServiceUnavailable, "server_error", "")
+		return
+	}
+
+	// Unknown tokens are not an error, so clients can't probe for them.
+	w.WriteHeader(http.StatusOK)
+}
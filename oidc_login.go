@@ -0,0 +1,192 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/oidc"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const OIDC_STATE_COOKIE = "chirpy_oidc"
+const OIDC_STATE_TTL = 10 * time.Minute
+
+// Users created from an external identity have no password. "unset" is the
+// column default and is never a valid bcrypt hash.
+const UNUSABLE_PASSWORD_HASH = "unset"
+
+// oidcStateClaims are kept in a signed cookie for the duration of a sign in,
+// so the callback can check state and nonce without server-side storage.
+type oidcStateClaims struct {
+	jwt.RegisteredClaims
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+}
+
+func (cfg *apiConfig) oidcLogin(w http.ResponseWriter, r *http.Request) {
+	if cfg.oidc == nil {
+		apierror.Write(w, apierror.NotFound("External sign in is not configured"))
+		return
+	}
+
+	state := &oidcStateClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    auth.TOKEN_ISSUER,
+			ExpiresAt: jwt.NewNumericDate(time.Now().UTC().Add(OIDC_STATE_TTL)),
+		},
+	}
+	for _, v := range []*string{&state.State, &state.Nonce, &state.Verifier} {
+		var err error
+		*v, err = auth.MakeRefreshToken()
+		if err != nil {
+			log.Printf("GET /api/oidc/login: Error creating state: %v\n", err)
+			apierror.Write(w, apierror.Internal("Server error"))
+			return
+		}
+	}
+
+	cookie, err := jwt.NewWithClaims(jwt.SigningMethodHS256, state).SignedString([]byte(cfg.secretKey))
+	if err != nil {
+		log.Printf("GET /api/oidc/login: Error signing state: %v\n", err)
+		apierror.Write(w, apierror.Internal("Server error"))
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     OIDC_STATE_COOKIE,
+		Value:    cookie,
+		Path:     "/api/oidc",
+		MaxAge:   int(OIDC_STATE_TTL.Seconds()),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+
+	authURL := cfg.oidc.AuthCodeURL(state.State, state.Nonce, auth.PKCEChallenge(state.Verifier))
+	http.Redirect(w, r, authURL, http.StatusFound)
+}
+
+func (cfg *apiConfig) oidcCallback(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if cfg.oidc == nil {
+		apierror.Write(w, apierror.NotFound("External sign in is not configured"))
+		return
+	}
+
+	// The state cookie is single use.
+	http.SetCookie(w, &http.Cookie{Name: OIDC_STATE_COOKIE, Path: "/api/oidc", MaxAge: -1})
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		apierror.Write(w, apierror.Unauthorized("Sign in was cancelled or failed at your identity provider."))
+		return
+	}
+
+	cookie, err := r.Cookie(OIDC_STATE_COOKIE)
+	if err != nil {
+		apierror.Write(w, apierror.BadRequest("Sign in session expired. Please try again."))
+		return
+	}
+
+	state := &oidcStateClaims{}
+	_, err = jwt.ParseWithClaims(cookie.Value, state, func(t *jwt.Token) (interface{}, error) {
+		return []byte(cfg.secretKey), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(auth.TOKEN_ISSUER),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || subtle.ConstantTimeCompare([]byte(state.State), []byte(query.Get("state"))) != 1 {
+		apierror.Write(w, apierror.BadRequest("Invalid sign in state. Please try again."))
+		return
+	}
+
+	idToken, err := cfg.oidc.Exchange(r.Context(), query.Get("code"), state.Verifier, state.Nonce)
+	if err != nil {
+		log.Printf("GET /api/oidc/callback: Error exchanging code: %v\n", err)
+		apierror.Write(w, apierror.Unauthorized("Could not verify your identity with the provider."))
+		return
+	}
+
+	user, err := cfg.userForExternalIdentity(r.Context(), idToken)
+	if errors.Is(err, errUnverifiedEmail) {
+		apierror.Write(w, apierror.Forbidden("Your identity provider has not verified your email address."))
+		return
+	}
+	if err != nil {
+		log.Printf("GET /api/oidc/callback: Error resolving user: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	response, err := cfg.newSession(r.Context(), user, auth.DEFAULT_TOKEN_TTL)
+	if err != nil {
+		log.Printf("GET /api/oidc/callback: Error creating session: %v\n", err)
+		apierror.Write(w, apierror.Internal("Error creating session"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(response)
+	if err != nil {
+		log.Printf("GET /api/oidc/callback: Error writing response: %v\n", err)
+	}
+}
+
+var errUnverifiedEmail = errors.New("External identity has no verified email")
+
+// userForExternalIdentity returns the user linked to idToken. The first time
+// an identity is seen it is linked to the user with the same email, or a new
+// user is created, but only if the provider has verified the email.
+func (cfg *apiConfig) userForExternalIdentity(ctx context.Context, idToken *oidc.IDToken) (database.User, error) {
+	user, err := cfg.store.GetUserByExternalIdentity(ctx, database.GetUserByExternalIdentityParams{
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		return user, err
+	}
+
+	if idToken.Email == "" || !idToken.EmailVerified {
+		return database.User{}, errUnverifiedEmail
+	}
+
+	tx, err := cfg.store.Begin(ctx)
+	if err != nil {
+		return database.User{}, err
+	}
+	defer tx.Rollback()
+
+	user, err = tx.GetUserByEmail(ctx, idToken.Email)
+	if errors.Is(err, sql.ErrNoRows) {
+		user, err = tx.CreateUser(ctx, database.CreateUserParams{
+			Email:          idToken.Email,
+			HashedPassword: UNUSABLE_PASSWORD_HASH,
+		})
+	}
+	if err != nil {
+		return database.User{}, err
+	}
+
+	_, err = tx.CreateExternalIdentity(ctx, database.CreateExternalIdentityParams{
+		UserID:  user.ID,
+		Issuer:  idToken.Issuer,
+		Subject: idToken.Subject,
+		Email:   idToken.Email,
+	})
+	if err != nil {
+		return database.User{}, err
+	}
+
+	return user, tx.Commit()
+}
@@ -0,0 +1,510 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/netguard"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/caleb-fringer/chirpy/internal/webhook"
+	"github.com/google/uuid"
+)
+
+// Events integrators can subscribe to.
+const (
+	CHIRP_CREATED_EVENT = "chirp.created"
+	CHIRP_DELETED_EVENT = "chirp.deleted"
+	USER_UPGRADED_EVENT = "user.upgraded"
+)
+
+var outboundWebhookEvents = map[string]string{
+	CHIRP_CREATED_EVENT: "A chirp was posted",
+	CHIRP_DELETED_EVENT: "A chirp was deleted",
+	USER_UPGRADED_EVENT: "The account upgraded to Chirpy Red",
+}
+
+// Outbound webhook statuses.
+const (
+	OUTBOUND_WEBHOOK_PENDING   = "pending"
+	OUTBOUND_WEBHOOK_DELIVERED = "delivered"
+	OUTBOUND_WEBHOOK_DEAD      = "dead"
+)
+
+const WEBHOOK_SECRET_PREFIX = "whsec_"
+
+const (
+	OUTBOUND_WEBHOOK_POLL = 5 * time.Second
+	// A claimed delivery is retried after this long if the worker sending it
+	// dies before recording the outcome.
+	OUTBOUND_WEBHOOK_LEASE = time.Minute
+	// Retries back off exponentially from the base delay up to the max delay.
+	// After the last attempt the delivery is dead-lettered.
+	OUTBOUND_WEBHOOK_RETRY_BASE   = 30 * time.Second
+	OUTBOUND_WEBHOOK_RETRY_MAX    = 6 * time.Hour
+	OUTBOUND_WEBHOOK_MAX_ATTEMPTS = 10
+)
+
+const (
+	DEFAULT_OUTBOUND_WEBHOOK_PAGE_SIZE = 50
+	MAX_OUTBOUND_WEBHOOK_PAGE_SIZE     = 200
+)
+
+type createWebhookEndpointReqParams struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+}
+
+type webhookEndpointResponse struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+}
+
+func newWebhookEndpointResponse(e database.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:        e.ID,
+		CreatedAt: e.CreatedAt,
+		URL:       e.Url,
+		Events:    e.Events,
+	}
+}
+
+type outboundWebhookResponse struct {
+	ID            uuid.UUID       `json:"id"`
+	CreatedAt     time.Time       `json:"created_at"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at"`
+	LastError     string          `json:"last_error,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at"`
+}
+
+func newOutboundWebhookResponse(o database.OutboundWebhook) outboundWebhookResponse {
+	res := outboundWebhookResponse{
+		ID:          o.ID,
+		CreatedAt:   o.CreatedAt,
+		Event:       o.Event,
+		Payload:     o.Payload,
+		Status:      o.Status,
+		Attempts:    o.Attempts,
+		LastError:   o.LastError.String,
+		DeliveredAt: nullTimePtr(o.DeliveredAt),
+	}
+	if o.Status == OUTBOUND_WEBHOOK_PENDING {
+		res.NextAttemptAt = &o.NextAttemptAt
+	}
+	return res
+}
+
+type outboundWebhookAttemptResponse struct {
+	Attempt    int32     `json:"attempt"`
+	CreatedAt  time.Time `json:"created_at"`
+	StatusCode *int32    `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	DurationMs int32     `json:"duration_ms"`
+}
+
+// outboundWebhookPayload is the body of every outbound webhook.
+type outboundWebhookPayload struct {
+	ID        uuid.UUID `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+// enqueueWebhookEvent queues event for delivery to every endpoint userID has
+// subscribed to it. Pass a transaction's queries to queue the event
+// atomically with the change that caused it.
+func enqueueWebhookEvent(ctx context.Context, q database.Querier, userID uuid.UUID, event string, data any) error {
+	payload, err := json.Marshal(outboundWebhookPayload{
+		ID:        uuid.New(),
+		Type:      event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = q.EnqueueOutboundWebhooks(ctx, database.EnqueueOutboundWebhooksParams{
+		UserID:  userID,
+		Event:   event,
+		Payload: payload,
+	})
+	if err != nil {
+		return fmt.Errorf("Error queueing %s webhooks: %v", event, err)
+	}
+	return nil
+}
+
+// deliverOutboundWebhooks sends every webhook that is due. Deliveries are
+// claimed with SKIP LOCKED, so any number of replicas can run this
+// concurrently.
+func (cfg *apiConfig) deliverOutboundWebhooks(ctx context.Context) error {
+	for {
+		job, err := cfg.store.ClaimOutboundWebhook(ctx, time.Now().UTC().Add(OUTBOUND_WEBHOOK_LEASE))
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("Error claiming outbound webhook: %v", err)
+		}
+
+		start := time.Now()
+		statusCode, sendErr := cfg.webhookSender.Send(ctx, webhook.Message{
+			URL:    job.Url,
+			Secret: job.Secret,
+			ID:     job.ID.String(),
+			Event:  job.Event,
+			Body:   job.Payload,
+		})
+
+		attempt := database.CreateOutboundWebhookAttemptParams{
+			OutboundWebhookID: job.ID,
+			Attempt:           job.Attempts,
+			StatusCode:        sql.NullInt32{Int32: int32(statusCode), Valid: statusCode != 0},
+			DurationMs:        int32(time.Since(start).Milliseconds()),
+		}
+		if sendErr != nil {
+			attempt.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+		}
+		err = cfg.store.CreateOutboundWebhookAttempt(ctx, attempt)
+		if err != nil {
+			log.Printf("Error recording attempt %d of outbound webhook %s: %v\n", job.Attempts, job.ID, err)
+		}
+
+		switch {
+		case sendErr == nil:
+			err = cfg.store.MarkOutboundWebhookDelivered(ctx, job.ID)
+		case job.Attempts >= OUTBOUND_WEBHOOK_MAX_ATTEMPTS:
+			log.Printf("Dead-lettering outbound webhook %s after %d attempts: %v\n", job.ID, job.Attempts, sendErr)
+			err = cfg.store.DeadLetterOutboundWebhook(ctx, database.DeadLetterOutboundWebhookParams{
+				ID:        job.ID,
+				LastError: attempt.Error,
+			})
+		default:
+			delay := webhook.Backoff(int(job.Attempts), OUTBOUND_WEBHOOK_RETRY_BASE, OUTBOUND_WEBHOOK_RETRY_MAX)
+			err = cfg.store.RetryOutboundWebhook(ctx, database.RetryOutboundWebhookParams{
+				ID:            job.ID,
+				NextAttemptAt: time.Now().UTC().Add(delay),
+				LastError:     attempt.Error,
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("Error saving outcome of outbound webhook %s: %v", job.ID, err)
+		}
+	}
+}
+
+// validWebhookURL requires https to a host that isn't a private address.
+// Receivers on localhost are allowed over http in development. Names are
+// checked again when the sender connects, after DNS resolution.
+func (cfg *apiConfig) validWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Hostname() == "" || u.User != nil {
+		return false
+	}
+	host := u.Hostname()
+	if cfg.platform == "dev" && (host == "localhost" || isLoopback(host)) {
+		return u.Scheme == "https" || u.Scheme == "http"
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if addr, err := netip.ParseAddr(host); err == nil && !netguard.IsPublic(addr) {
+		return false
+	}
+	return u.Scheme == "https"
+}
+
+func isLoopback(host string) bool {
+	addr, err := netip.ParseAddr(host)
+	return err == nil && addr.Unmap().IsLoopback()
+}
+
+func (cfg *apiConfig) createWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	reqBody := &createWebhookEndpointReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	if !cfg.validWebhookURL(reqBody.URL) {
+		apierror.Write(w, apierror.BadRequest("Invalid webhook URL. Webhook URLs must use https and a public host."))
+		return
+	}
+
+	if len(reqBody.Events) == 0 {
+		apierror.Write(w, apierror.BadRequest("Please subscribe to at least one event."))
+		return
+	}
+	for _, event := range reqBody.Events {
+		if _, ok := outboundWebhookEvents[event]; !ok {
+			apierror.Write(w, apierror.BadRequest(fmt.Sprintf("Unknown event %q", event)))
+			return
+		}
+	}
+
+	secret, err := auth.MakeRefreshToken()
+	if err != nil {
+		log.Printf("POST /api/webhooks: Error creating secret: %v\n", err)
+		apierror.Write(w, apierror.Internal("Server error"))
+		return
+	}
+	secret = WEBHOOK_SECRET_PREFIX + secret
+
+	// Payloads are signed with the secret, so unlike our other secrets it is
+	// stored as is.
+	endpoint, err := cfg.store.CreateWebhookEndpoint(r.Context(), database.CreateWebhookEndpointParams{
+		UserID: userID,
+		Url:    reqBody.URL,
+		Secret: secret,
+		Events: reqBody.Events,
+	})
+	if err != nil {
+		log.Printf("POST /api/webhooks: Error storing endpoint: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := newWebhookEndpointResponse(endpoint)
+	res.Secret = endpoint.Secret
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("POST /api/webhooks: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) getWebhookEndpoints(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	endpoints, err := cfg.store.GetWebhookEndpointsByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/webhooks: Error retrieving endpoints: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := make([]webhookEndpointResponse, 0, len(endpoints))
+	for _, e := range endpoints {
+		res = append(res, newWebhookEndpointResponse(e))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/webhooks: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) deleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	endpointID, err := request.PathUUID(r, "endpointID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	// Queued deliveries are deleted along with the endpoint.
+	rows, err := cfg.store.DeleteWebhookEndpoint(r.Context(), database.DeleteWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/webhooks/%s: Error deleting endpoint: %v\n", endpointID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("Endpoint not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// userWebhookEndpoint returns the endpoint named in the request path if it
+// belongs to the session user. Otherwise it writes an error response and
+// returns false.
+func (cfg *apiConfig) userWebhookEndpoint(w http.ResponseWriter, r *http.Request) (database.WebhookEndpoint, bool) {
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	endpointID, err := request.PathUUID(r, "endpointID")
+	if err != nil {
+		apierror.Write(w, err)
+		return database.WebhookEndpoint{}, false
+	}
+
+	endpoint, err := cfg.store.GetWebhookEndpoint(r.Context(), database.GetWebhookEndpointParams{
+		ID:     endpointID,
+		UserID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.NotFound("Endpoint not found"))
+		return database.WebhookEndpoint{}, false
+	}
+	if err != nil {
+		log.Printf("%s %s: Error retrieving endpoint: %v\n", r.Method, r.URL.Path, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return database.WebhookEndpoint{}, false
+	}
+
+	return endpoint, true
+}
+
+func (cfg *apiConfig) getOutboundWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	endpoint, ok := cfg.userWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	limit, err := request.QueryInt(r, "limit", DEFAULT_OUTBOUND_WEBHOOK_PAGE_SIZE, 1, MAX_OUTBOUND_WEBHOOK_PAGE_SIZE)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+	params := database.GetOutboundWebhooksByEndpointParams{
+		EndpointID: endpoint.ID,
+		Limit:      int32(limit),
+	}
+
+	deliveries, err := cfg.store.GetOutboundWebhooksByEndpoint(r.Context(), params)
+	if err != nil {
+		log.Printf("GET /api/webhooks/%s/deliveries: Error retrieving deliveries: %v\n", endpoint.ID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := make([]outboundWebhookResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		res = append(res, newOutboundWebhookResponse(d))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/webhooks/%s/deliveries: Error writing response: %v\n", endpoint.ID, err)
+	}
+}
+
+func (cfg *apiConfig) getOutboundWebhookAttempts(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	endpoint, ok := cfg.userWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := request.PathUUID(r, "deliveryID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	_, err = cfg.store.GetOutboundWebhook(r.Context(), database.GetOutboundWebhookParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if err != nil {
+		apierror.Write(w, apierror.NotFound("Delivery not found"))
+		return
+	}
+
+	attempts, err := cfg.store.GetOutboundWebhookAttempts(r.Context(), deliveryID)
+	if err != nil {
+		log.Printf("GET /api/webhooks/%s/deliveries/%s/attempts: Error retrieving attempts: %v\n", endpoint.ID, deliveryID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := make([]outboundWebhookAttemptResponse, 0, len(attempts))
+	for _, a := range attempts {
+		attempt := outboundWebhookAttemptResponse{
+			Attempt:    a.Attempt,
+			CreatedAt:  a.CreatedAt,
+			Error:      a.Error.String,
+			DurationMs: a.DurationMs,
+		}
+		if a.StatusCode.Valid {
+			attempt.StatusCode = &a.StatusCode.Int32
+		}
+		res = append(res, attempt)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/webhooks/%s/deliveries/%s/attempts: Error writing response: %v\n", endpoint.ID, deliveryID, err)
+	}
+}
+
+// retryOutboundWebhook puts a dead-lettered delivery back on the queue with a
+// fresh set of attempts.
+func (cfg *apiConfig) retryOutboundWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	endpoint, ok := cfg.userWebhookEndpoint(w, r)
+	if !ok {
+		return
+	}
+
+	deliveryID, err := request.PathUUID(r, "deliveryID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	delivery, err := cfg.store.GetOutboundWebhook(r.Context(), database.GetOutboundWebhookParams{
+		ID:         deliveryID,
+		EndpointID: endpoint.ID,
+	})
+	if err != nil {
+		apierror.Write(w, apierror.NotFound("Delivery not found"))
+		return
+	}
+
+	rows := int64(0)
+	if delivery.Status == OUTBOUND_WEBHOOK_DEAD {
+		rows, err = cfg.store.RequeueOutboundWebhook(r.Context(), database.RequeueOutboundWebhookParams{
+			ID:         deliveryID,
+			EndpointID: endpoint.ID,
+		})
+		if err != nil {
+			log.Printf("POST /api/webhooks/%s/deliveries/%s/retry: Error requeueing delivery: %v\n", endpoint.ID, deliveryID, err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+	}
+	if rows == 0 {
+		apierror.Write(w, apierror.Conflict("Only dead-lettered deliveries can be retried"))
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(nil)
+}
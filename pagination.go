@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/request"
+)
+
+const (
+	DEFAULT_PAGE_SIZE = 50
+	MAX_PAGE_SIZE     = 200
+)
+
+// timePage parses the before and limit query parameters of lists paged by
+// timestamp. It writes an error response and returns false if they are
+// invalid.
+func timePage(w http.ResponseWriter, r *http.Request) (time.Time, int32, bool) {
+	before, err := request.QueryTime(r, "before", time.Now().UTC().Add(time.Second))
+	if err != nil {
+		apierror.Write(w, err)
+		return time.Time{}, 0, false
+	}
+	limit, err := request.QueryInt(r, "limit", DEFAULT_PAGE_SIZE, 1, MAX_PAGE_SIZE)
+	if err != nil {
+		apierror.Write(w, err)
+		return time.Time{}, 0, false
+	}
+	return before, int32(limit), true
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+// userPinTarget returns the chirp named in the request path and the token's
+// user, who must have written it. Otherwise it writes an error response and
+// returns false.
+func (cfg *apiConfig) userPinTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	chirpID, err := request.PathUUID(r, "chirpID")
+	if err != nil {
+		apierror.Write(w, err)
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+
+	chirp, err := cfg.store.GetChirpById(r.Context(), chirpID)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && chirp.UserID != principal.UserID) {
+		apierror.Write(w, apierror.NotFound("Chirp not found"))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+	if err != nil {
+		log.Printf("%s %s: Error retrieving chirp: %v\n", r.Method, r.URL.Path, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	if r.Method == http.MethodPost && chirp.Status != CHIRP_PUBLISHED {
+		apierror.Write(w, apierror.Conflict("Only published chirps can be pinned."))
+		return uuid.UUID{}, uuid.UUID{}, false
+	}
+
+	return chirpID, principal.UserID, true
+}
+
+// pinChirp pins one of the user's chirps to their profile. Users can pin up
+// to three, which the schema enforces. Pinning a chirp that is already pinned
+// does nothing.
+func (cfg *apiConfig) pinChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirpID, userID, ok := cfg.userPinTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.store.PinChirp(r.Context(), database.PinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	var pinned []uuid.UUID
+	if err == nil && rows == 0 {
+		pinned, err = cfg.store.GetPinnedChirpIDs(r.Context(), userID)
+	}
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/pin: Error pinning chirp: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 && !slices.Contains(pinned, chirpID) {
+		apierror.Write(w, apierror.Conflict("You can pin at most 3 chirps."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (cfg *apiConfig) unpinChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirpID, userID, ok := cfg.userPinTarget(w, r)
+	if !ok {
+		return
+	}
+
+	rows, err := cfg.store.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/chirps/%s/pin: Error unpinning chirp: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("This chirp isn't pinned."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// pinnedFirst moves the chirps in pinned to the front of chirps, in the
+// order they appear in pinned, keeping the rest in order.
+func pinnedFirst(chirps []database.Chirp, pinned []uuid.UUID) []database.Chirp {
+	byID := map[uuid.UUID]database.Chirp{}
+	for _, c := range chirps {
+		byID[c.ID] = c
+	}
+
+	ordered := make([]database.Chirp, 0, len(chirps))
+	for _, id := range pinned {
+		if c, ok := byID[id]; ok {
+			ordered = append(ordered, c)
+		}
+	}
+	for _, c := range chirps {
+		if !slices.Contains(pinned, c.ID) {
+			ordered = append(ordered, c)
+		}
+	}
+	return ordered
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+const (
+	MIN_POLL_OPTIONS       = 2
+	MAX_POLL_OPTIONS       = 4
+	MAX_POLL_OPTION_LENGTH = 100
+	// How long polls stay open, counted from when their chirp is published.
+	MIN_POLL_DURATION = 5 * time.Minute
+	MAX_POLL_DURATION = 7 * 24 * time.Hour
+)
+
+type createPollReqParams struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+type pollOptionResponse struct {
+	Text string `json:"text"`
+	// Only set once the viewer has voted or the poll has closed.
+	Votes *int64 `json:"votes,omitempty"`
+}
+
+type pollResponse struct {
+	ClosesAt time.Time            `json:"closes_at"`
+	Closed   bool                 `json:"closed"`
+	Options  []pollOptionResponse `json:"options"`
+	// The index of the option the viewer voted for.
+	Voted      *int   `json:"voted,omitempty"`
+	TotalVotes *int64 `json:"total_votes,omitempty"`
+}
+
+type votePollReqParams struct {
+	Option *int `json:"option"`
+}
+
+func (p *votePollReqParams) Validate() request.FieldErrors {
+	errs := request.FieldErrors{}
+	if p.Option == nil {
+		errs.Add("option", "is required")
+	}
+	return errs
+}
+
+// checkPoll returns a message for the client if p isn't a valid poll for a
+// chirp published at publishAt.
+func checkPoll(p createPollReqParams, publishAt time.Time) string {
+	if len(p.Options) < MIN_POLL_OPTIONS || len(p.Options) > MAX_POLL_OPTIONS {
+		return fmt.Sprintf("A poll must have between %d and %d options.", MIN_POLL_OPTIONS, MAX_POLL_OPTIONS)
+	}
+	seen := map[string]struct{}{}
+	for _, option := range p.Options {
+		option = strings.TrimSpace(option)
+		if option == "" || len(option) > MAX_POLL_OPTION_LENGTH {
+			return fmt.Sprintf("Poll options must be between 1 and %d characters.", MAX_POLL_OPTION_LENGTH)
+		}
+		if _, ok := seen[strings.ToLower(option)]; ok {
+			return "Poll options must be different."
+		}
+		seen[strings.ToLower(option)] = struct{}{}
+	}
+
+	return checkPollDuration(p.ClosesAt, publishAt)
+}
+
+// checkPollDuration returns a message for the client if a poll closing at
+// closesAt can't go out on a chirp published at publishAt.
+func checkPollDuration(closesAt, publishAt time.Time) string {
+	duration := closesAt.Sub(publishAt)
+	if duration < MIN_POLL_DURATION || duration > MAX_POLL_DURATION {
+		return "closes_at must be between 5 minutes and 7 days after the chirp is published."
+	}
+	return ""
+}
+
+// chirpPollError returns a 400 *apierror.Error if the poll on chirpID, if it
+// has one, can't go out on the chirp published at publishAt.
+func chirpPollError(ctx context.Context, q database.Querier, chirpID uuid.UUID, publishAt time.Time) error {
+	poll, err := q.GetPoll(ctx, chirpID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("Error retrieving poll: %v", err)
+	}
+	if msg := checkPollDuration(poll.ClosesAt, publishAt); msg != "" {
+		return apierror.ValidationFailed(msg)
+	}
+	return nil
+}
+
+func createPoll(ctx context.Context, q database.Querier, chirpID uuid.UUID, p createPollReqParams) error {
+	err := q.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: p.ClosesAt.UTC(),
+	})
+	for i, option := range p.Options {
+		if err != nil {
+			break
+		}
+		err = q.CreatePollOption(ctx, database.CreatePollOptionParams{
+			ChirpID:  chirpID,
+			Position: int16(i),
+			Text:     censorProfanity(strings.TrimSpace(option)),
+		})
+	}
+	return err
+}
+
+// polls returns the polls on chirps as viewer sees them, by chirp id.
+func (cfg *apiConfig) polls(ctx context.Context, viewer uuid.NullUUID, chirps []database.Chirp) (map[uuid.UUID]pollResponse, error) {
+	ids := make([]uuid.UUID, 0, len(chirps))
+	for _, c := range chirps {
+		ids = append(ids, c.ID)
+	}
+	polls := map[uuid.UUID]pollResponse{}
+	if len(ids) == 0 {
+		return polls, nil
+	}
+
+	options, err := cfg.store.GetPollOptions(ctx, ids)
+	if err != nil || len(options) == 0 {
+		return polls, err
+	}
+
+	voted := map[uuid.UUID]int{}
+	if viewer.Valid {
+		votes, err := cfg.store.GetPollVotesByUser(ctx, database.GetPollVotesByUserParams{
+			UserID:   viewer.UUID,
+			ChirpIds: ids,
+		})
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range votes {
+			voted[v.ChirpID] = int(v.Position)
+		}
+	}
+
+	now := time.Now().UTC()
+	totals := map[uuid.UUID]int64{}
+	for _, o := range options {
+		totals[o.ChirpID] += o.Votes
+	}
+	for _, o := range options {
+		p, ok := polls[o.ChirpID]
+		if !ok {
+			p = pollResponse{ClosesAt: o.ClosesAt, Closed: !now.Before(o.ClosesAt)}
+			if position, ok := voted[o.ChirpID]; ok {
+				p.Voted = &position
+			}
+			if p.Closed || p.Voted != nil {
+				total := totals[o.ChirpID]
+				p.TotalVotes = &total
+			}
+		}
+
+		option := pollOptionResponse{Text: o.Text}
+		if p.TotalVotes != nil {
+			option.Votes = &o.Votes
+		}
+		p.Options = append(p.Options, option)
+		polls[o.ChirpID] = p
+	}
+	return polls, nil
+}
+
+// votePoll records the user's vote on a chirp's poll and returns the poll
+// with its results.
+func (cfg *apiConfig) votePoll(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirpID, err := request.PathUUID(r, "chirpID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+	viewer := uuid.NullUUID{UUID: principal.UserID, Valid: true}
+
+	reqBody := &votePollReqParams{}
+	err = request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	// Chirps the user can't see, or by users on either side of a block,
+	// look as if they don't exist.
+	chirp, err := cfg.store.GetVisibleChirpById(r.Context(), database.GetVisibleChirpByIdParams{
+		ID:       chirpID,
+		ViewerID: viewer,
+	})
+	blocked := false
+	if err == nil {
+		blocked, err = cfg.store.IsBlockedEither(r.Context(), database.IsBlockedEitherParams{
+			BlockerID: chirp.UserID,
+			BlockedID: principal.UserID,
+		})
+	}
+	var poll database.Poll
+	if err == nil && !blocked {
+		poll, err = cfg.store.GetPoll(r.Context(), chirpID)
+	}
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && blocked) {
+		apierror.Write(w, apierror.NotFound("Poll not found"))
+		return
+	}
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/poll/votes: Error retrieving poll: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if !time.Now().UTC().Before(poll.ClosesAt) {
+		apierror.Write(w, apierror.Conflict("This poll is closed."))
+		return
+	}
+
+	polls, err := cfg.polls(r.Context(), viewer, []database.Chirp{chirp})
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/poll/votes: Error retrieving poll: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if option := *reqBody.Option; option < 0 || option >= len(polls[chirpID].Options) {
+		apierror.Write(w, apierror.BadRequest("That option isn't in this poll."))
+		return
+	}
+
+	rows, err := cfg.store.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+		ChirpID:  chirpID,
+		UserID:   principal.UserID,
+		Position: int16(*reqBody.Option),
+	})
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/poll/votes: Error recording vote: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if rows == 0 {
+		apierror.Write(w, apierror.Conflict("You have already voted in this poll."))
+		return
+	}
+
+	polls, err = cfg.polls(r.Context(), viewer, []database.Chirp{chirp})
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/poll/votes: Error retrieving results: %v\n", chirpID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(polls[chirpID])
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/poll/votes: Error writing response: %v\n", chirpID, err)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/caleb-fringer/chirpy/internal/apierror"
 	"github.com/caleb-fringer/chirpy/internal/auth"
 	"github.com/caleb-fringer/chirpy/internal/database"
 	"github.com/google/uuid"
@@ -24,36 +25,37 @@ func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
 	tokenStr, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("POST /api/refresh: Error extracting refresh token from request headers: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(json.RawMessage(`"error": "Please provide a refresh token in the authorization header."`))
+		apierror.Write(w, apierror.Unauthorized("Please provide a refresh token in the authorization header."))
 		return
 	}
 
-	token, err := cfg.queries.GetRefreshTokenById(r.Context(), tokenStr)
+	token, err := cfg.store.GetRefreshTokenById(r.Context(), tokenStr)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`"error": "Invalid token."`))
+		apierror.Write(w, apierror.Unauthorized("Invalid token."))
 		return
 	}
 
 	if time.Now().UTC().After(token.ExpiresAt) {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`"error": "Expired token."`))
+		apierror.Write(w, apierror.Unauthorized("Expired token."))
+		return
+	}
+
+	// Tokens issued to OAuth clients must be refreshed through /oauth/token.
+	if token.ClientID.Valid {
+		apierror.Write(w, apierror.Unauthorized("Invalid token."))
 		return
 	}
 
 	if token.RevokedAt.Valid {
 		log.Printf("Warning: Someone attempted to use a revoked refresh token: token %s\n", tokenStr)
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`"error": "Revoked token."`))
+		apierror.Write(w, apierror.Unauthorized("Revoked token."))
 		return
 	}
 
 	refreshTokenStr, err := auth.MakeRefreshToken()
 	if err != nil {
 		log.Printf("POST /api/refresh: Error creating new refresh token: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error creating new refresh token"`))
+		apierror.Write(w, apierror.Internal("Error creating new refresh token"))
 		return
 	}
 
@@ -63,28 +65,25 @@ func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
 		ExpiresAt: time.Now().UTC().Add(60 * 24 * time.Hour),
 	}
 
-	refreshToken, err := cfg.queries.CreateRefreshToken(r.Context(), refreshTokenParams)
+	refreshToken, err := cfg.store.CreateRefreshToken(r.Context(), refreshTokenParams)
 
 	if err != nil {
 		log.Printf("POST /api/refresh: Error saving new refresh token in database: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error creating new refresh token"`))
+		apierror.Write(w, apierror.Internal("Error creating new refresh token"))
 		return
 	}
 
-	newAuthToken, err := auth.MakeJWT(token.UserID, cfg.secretKey)
+	user, err := cfg.store.GetUserByID(r.Context(), refreshToken.UserID)
 	if err != nil {
-		log.Printf("POST /api/refresh: Error creating new JWT: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error creating new authorization token"`))
+		log.Printf("POST /api/refresh: Error getting user email from UUID: %v\n", err)
+		apierror.Write(w, apierror.Internal("Error creating new authorization token"))
 		return
 	}
 
-	user, err := cfg.queries.GetUserByID(r.Context(), refreshToken.UserID)
+	newAuthToken, err := auth.MakeJWT(user.ID, cfg.secretKey, auth.WithChirpyRed(user.IsChirpyRed))
 	if err != nil {
-		log.Printf("POST /api/refresh: Error getting user email from UUID: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error creating new authorization token"`))
+		log.Printf("POST /api/refresh: Error creating new JWT: %v\n", err)
+		apierror.Write(w, apierror.Internal("Error creating new authorization token"))
 		return
 	}
 
@@ -100,11 +99,11 @@ func (cfg *apiConfig) refresh(w http.ResponseWriter, r *http.Request) {
 	rawRes, err := json.Marshal(res)
 	if err != nil {
 		log.Printf("POST /api/refresh: Error marshalling json response: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Error marshalling json response"`))
+		apierror.Write(w, apierror.Internal("Error marshalling json response"))
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(rawRes)
 	return
@@ -114,16 +113,14 @@ func (cfg *apiConfig) handlerRevoke(w http.ResponseWriter, r *http.Request) {
 	tokenStr, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		log.Printf("POST /api/revoke: Error extracting authorization token: %v\n", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(json.RawMessage(`"error": "Please provide an authorization token in the authorization header."`))
+		apierror.Write(w, apierror.Unauthorized("Please provide a refresh token in the authorization header."))
 		return
 	}
 
-	err = cfg.queries.RevokeRefreshToken(r.Context(), tokenStr)
+	err = cfg.store.RevokeRefreshToken(r.Context(), tokenStr)
 	if err != nil {
 		log.Printf("POST /api/revoke: Error revoking refresh token in DB: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`"error": "Database error"`))
+		apierror.Write(w, apierror.Internal("Database error"))
 		return
 	}
 
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/caleb-fringer/chirpy/internal/auth"
+)
+
+// routes registers the API's handlers.
+func (cfg *apiConfig) routes() *http.ServeMux {
+	mux := http.NewServeMux()
+
+	mux.Handle("/app/", cfg.middlewareMetricsInc(http.StripPrefix("/app", http.FileServer(http.Dir(ROOT_PATH)))))
+
+	mux.HandleFunc("GET /api/healthz", healthz)
+
+	mux.HandleFunc("POST /api/users", cfg.createUser)
+
+	mux.HandleFunc("POST /admin/reset", cfg.reset)
+
+	mux.Handle("GET /admin/metrics", cfg)
+
+	mux.Handle("POST /api/chirps", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.createChirp))
+
+	mux.Handle("GET /api/chirps", cfg.optionalAuth(auth.SCOPE_CHIRPS_READ, cfg.getChirps))
+
+	mux.Handle("GET /api/chirps/{id}", cfg.optionalAuth(auth.SCOPE_CHIRPS_READ, cfg.getChirp))
+
+	mux.Handle("GET /api/chirps/{chirpID}/thread", cfg.optionalAuth(auth.SCOPE_CHIRPS_READ, cfg.getChirpThread))
+
+	mux.Handle("POST /api/chirps/{chirpID}/poll/votes", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.votePoll))
+
+	mux.Handle("POST /api/chirps/{chirpID}/bookmark", cfg.requireSession(cfg.bookmarkChirp))
+
+	mux.Handle("DELETE /api/chirps/{chirpID}/bookmark", cfg.requireSession(cfg.unbookmarkChirp))
+
+	mux.Handle("GET /api/bookmarks", cfg.requireSession(cfg.getBookmarks))
+
+	mux.Handle("POST /api/chirps/{chirpID}/pin", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.pinChirp))
+
+	mux.Handle("DELETE /api/chirps/{chirpID}/pin", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.unpinChirp))
+
+	mux.Handle("GET /api/analytics/chirps", cfg.requireAuth(auth.SCOPE_CHIRPS_READ, cfg.getChirpAnalytics))
+
+	mux.HandleFunc("POST /api/login", cfg.login)
+
+	mux.HandleFunc("POST /api/refresh", cfg.refresh)
+
+	mux.HandleFunc("POST /api/revoke", cfg.handlerRevoke)
+
+	mux.Handle("PUT /api/users", cfg.requireSession(cfg.updateUser))
+
+	mux.Handle("GET /api/chirps/drafts", cfg.requireAuth(auth.SCOPE_CHIRPS_READ, cfg.getUnpublishedChirps))
+
+	mux.Handle("PUT /api/chirps/{chirpID}", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.updateChirp))
+
+	mux.Handle("POST /api/chirps/{chirpID}/publish", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.publishChirp))
+
+	mux.Handle("PUT /api/chirps/{chirpID}/schedule", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.scheduleChirp))
+
+	mux.Handle("DELETE /api/chirps/{chirpID}/schedule", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.unscheduleChirp))
+
+	mux.Handle("DELETE /api/chirps/{chirpID}", cfg.requireAuth(auth.SCOPE_CHIRPS_WRITE, cfg.deleteChirp))
+
+	mux.HandleFunc("POST /api/polka/webhooks", cfg.subscribe)
+
+	mux.Handle("POST /api/tokens", cfg.requireSession(cfg.createPersonalAccessToken))
+
+	mux.Handle("GET /api/tokens", cfg.requireSession(cfg.getPersonalAccessTokens))
+
+	mux.Handle("DELETE /api/tokens/{tokenID}", cfg.requireSession(cfg.revokePersonalAccessToken))
+
+	mux.Handle("POST /api/oauth/clients", cfg.requireSession(cfg.createOAuthClient))
+
+	mux.Handle("DELETE /api/oauth/clients/{clientID}", cfg.requireSession(cfg.deleteOAuthClient))
+
+	mux.HandleFunc("GET /oauth/authorize", cfg.authorize)
+
+	mux.HandleFunc("POST /oauth/authorize", cfg.approveAuthorization)
+
+	mux.HandleFunc("POST /oauth/token", cfg.oauthToken)
+
+	mux.HandleFunc("POST /oauth/revoke", cfg.oauthRevoke)
+
+	mux.HandleFunc("GET /api/oidc/login", cfg.oidcLogin)
+
+	mux.HandleFunc("GET /api/oidc/callback", cfg.oidcCallback)
+
+	mux.Handle("DELETE /api/users", cfg.requireSession(cfg.deleteUser))
+
+	mux.Handle("DELETE /api/users/deletion", cfg.requireSession(cfg.cancelUserDeletion))
+
+	mux.Handle("POST /api/users/export", cfg.requireSession(cfg.requestDataExport))
+
+	mux.Handle("GET /api/users/export", cfg.requireSession(cfg.getDataExport))
+
+	mux.Handle("GET /api/users/export/{exportID}", cfg.requireSession(cfg.downloadDataExport))
+
+	mux.Handle("GET /api/users/subscription", cfg.requireSession(cfg.getSubscription))
+
+	mux.HandleFunc("GET /admin/webhooks", cfg.listWebhookDeliveries)
+
+	mux.HandleFunc("GET /admin/webhooks/{deliveryID}", cfg.getWebhookDelivery)
+
+	mux.HandleFunc("POST /admin/webhooks/{deliveryID}/replay", cfg.replayWebhookDelivery)
+
+	mux.Handle("POST /api/webhooks", cfg.requireSession(cfg.createWebhookEndpoint))
+
+	mux.Handle("GET /api/webhooks", cfg.requireSession(cfg.getWebhookEndpoints))
+
+	mux.Handle("DELETE /api/webhooks/{endpointID}", cfg.requireSession(cfg.deleteWebhookEndpoint))
+
+	mux.Handle("GET /api/webhooks/{endpointID}/deliveries", cfg.requireSession(cfg.getOutboundWebhooks))
+
+	mux.Handle("GET /api/webhooks/{endpointID}/deliveries/{deliveryID}/attempts", cfg.requireSession(cfg.getOutboundWebhookAttempts))
+
+	mux.Handle("POST /api/webhooks/{endpointID}/deliveries/{deliveryID}/retry", cfg.requireSession(cfg.retryOutboundWebhook))
+
+	mux.Handle("POST /api/users/{userID}/follow", cfg.requireSession(cfg.followUser))
+
+	mux.Handle("DELETE /api/users/{userID}/follow", cfg.requireSession(cfg.unfollowUser))
+
+	mux.Handle("GET /api/follow-requests", cfg.requireSession(cfg.getFollowRequests))
+
+	mux.Handle("POST /api/follow-requests/{userID}", cfg.requireSession(cfg.approveFollowRequest))
+
+	mux.Handle("DELETE /api/follow-requests/{userID}", cfg.requireSession(cfg.rejectFollowRequest))
+
+	mux.Handle("GET /api/stream", cfg.optionalAuth(auth.SCOPE_CHIRPS_READ, cfg.streamChirps))
+
+	mux.Handle("GET /api/notifications", cfg.requireSession(cfg.getNotifications))
+
+	mux.Handle("GET /api/ws", withQueryToken(cfg.requireSession(cfg.serveSocket)))
+
+	mux.Handle("GET /api/users/settings", cfg.requireSession(cfg.getSettings))
+
+	mux.Handle("PUT /api/users/settings", cfg.requireSession(cfg.updateSettings))
+
+	mux.Handle("POST /api/users/{userID}/block", cfg.requireSession(cfg.blockUser))
+
+	mux.Handle("DELETE /api/users/{userID}/block", cfg.requireSession(cfg.unblockUser))
+
+	mux.Handle("GET /api/users/blocks", cfg.requireSession(cfg.getBlocks))
+
+	mux.Handle("POST /api/users/{userID}/mute", cfg.requireSession(cfg.muteUser))
+
+	mux.Handle("DELETE /api/users/{userID}/mute", cfg.requireSession(cfg.unmuteUser))
+
+	mux.Handle("GET /api/users/mutes", cfg.requireSession(cfg.getMutes))
+
+	mux.Handle("POST /api/conversations", cfg.requireSession(cfg.createConversation))
+
+	mux.Handle("GET /api/conversations", cfg.requireSession(cfg.getConversations))
+
+	mux.Handle("GET /api/conversations/{conversationID}/messages", cfg.requireSession(cfg.getDirectMessages))
+
+	mux.Handle("POST /api/conversations/{conversationID}/messages", cfg.requireSession(cfg.sendDirectMessage))
+
+	mux.Handle("POST /api/conversations/{conversationID}/read", cfg.requireSession(cfg.markConversationRead))
+
+	return mux
+}
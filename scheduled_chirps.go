@@ -0,0 +1,320 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/entitlements"
+	"github.com/caleb-fringer/chirpy/internal/request"
+)
+
+const (
+	CHIRP_PUBLISH_POLL       = 15 * time.Second
+	CHIRP_PUBLISH_BATCH_SIZE = 100
+	// How far ahead chirps can be scheduled.
+	MAX_SCHEDULE_AHEAD = 365 * 24 * time.Hour
+)
+
+type scheduleChirpReqParams struct {
+	PublishAt *time.Time `json:"publish_at"`
+}
+
+// checkPublishAt returns the problem with publishAt as a field error, or ""
+// if a chirp can be scheduled for then.
+func checkPublishAt(publishAt *time.Time) string {
+	now := time.Now()
+	switch {
+	case publishAt == nil:
+		return "is required for scheduled chirps"
+	case !publishAt.After(now):
+		return "must be in the future"
+	case publishAt.After(now.Add(MAX_SCHEDULE_AHEAD)):
+		return "must be at most a year ahead"
+	}
+	return ""
+}
+
+// publishScheduledChirps publishes every scheduled chirp that is due. Chirps
+// are claimed with SKIP LOCKED, so any number of replicas can run this
+// concurrently without publishing a chirp twice.
+func (cfg *apiConfig) publishScheduledChirps(ctx context.Context) error {
+	for {
+		n, err := cfg.publishDueChirps(ctx)
+		if err != nil {
+			return err
+		}
+		if n < CHIRP_PUBLISH_BATCH_SIZE {
+			return nil
+		}
+	}
+}
+
+// checkPublishable returns an *apierror.Error if chirp can't be published at
+// publishAt. Drafts and scheduled chirps are checked again when they go out,
+// since the author may have lost Chirpy Red and a poll's closing time only
+// makes sense relative to when its chirp is published.
+func checkPublishable(ctx context.Context, q database.Querier, chirp database.Chirp, publishAt time.Time) error {
+	user, err := q.GetUserByID(ctx, chirp.UserID)
+	if err != nil {
+		return fmt.Errorf("Error retrieving plan: %v", err)
+	}
+	plan := entitlements.ForUser(user.IsChirpyRed)
+
+	var missing entitlements.MissingError
+	if errors.As(plan.CheckChirpLength(len(chirp.Body)), &missing) {
+		return entitlementError(missing)
+	}
+	err = chirpRateLimitError(ctx, q, chirp.UserID, plan)
+	if err != nil {
+		return err
+	}
+	return chirpPollError(ctx, q, chirp.ID, publishAt)
+}
+
+// publishDueChirps handles one batch of due chirps and returns how many it
+// handled. Chirps that can no longer be published are returned to their
+// author's drafts; the rest are published, with their webhooks queued in the
+// same transaction.
+func (cfg *apiConfig) publishDueChirps(ctx context.Context) (int, error) {
+	tx, err := cfg.store.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	chirps, err := tx.LockDueChirps(ctx, CHIRP_PUBLISH_BATCH_SIZE)
+	if err != nil {
+		return 0, fmt.Errorf("Error retrieving scheduled chirps: %v", err)
+	}
+
+	published := 0
+	for _, due := range chirps {
+		err = checkPublishable(ctx, tx, due, time.Now().UTC())
+		var apiErr *apierror.Error
+		if errors.As(err, &apiErr) {
+			log.Printf("Returning scheduled chirp %s to drafts: %v\n", due.ID, apiErr)
+			_, err = tx.UnscheduleChirp(ctx, due.ID)
+			if err != nil {
+				return 0, fmt.Errorf("Error unscheduling chirp %s: %v", due.ID, err)
+			}
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		chirp, err := tx.PublishChirp(ctx, due.ID)
+		if err != nil {
+			return 0, fmt.Errorf("Error publishing chirp %s: %v", due.ID, err)
+		}
+		err = enqueueWebhookEvent(ctx, tx, chirp.UserID, CHIRP_CREATED_EVENT, newChirpResponse(chirp))
+		if err != nil {
+			return 0, err
+		}
+		published++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, err
+	}
+	if published > 0 {
+		log.Printf("Published %d scheduled chirps\n", published)
+	}
+	return len(chirps), nil
+}
+
+func (cfg *apiConfig) getUnpublishedChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	principal := auth.PrincipalFrom(r.Context())
+
+	chirps, err := cfg.store.GetUnpublishedChirpsByAuthor(r.Context(), principal.UserID)
+	if err != nil {
+		log.Printf("GET /api/chirps/drafts: Error retrieving drafts: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newChirpsResponse(chirps))
+	if err != nil {
+		log.Printf("GET /api/chirps/drafts: Error writing response: %v\n", err)
+	}
+}
+
+// userUnpublishedChirp returns the draft or scheduled chirp named in the
+// request path if it belongs to the user making the request. Otherwise it writes an
+// error response and returns false.
+func (cfg *apiConfig) userUnpublishedChirp(w http.ResponseWriter, r *http.Request) (database.Chirp, bool) {
+	chirpID, err := request.PathUUID(r, "chirpID")
+	if err != nil {
+		apierror.Write(w, err)
+		return database.Chirp{}, false
+	}
+
+	principal := auth.PrincipalFrom(r.Context())
+
+	chirp, err := cfg.store.GetChirpById(r.Context(), chirpID)
+	if err != nil || chirp.UserID != principal.UserID {
+		apierror.Write(w, apierror.NotFound("Chirp not found"))
+		return database.Chirp{}, false
+	}
+
+	if chirp.Status == CHIRP_PUBLISHED {
+		apierror.Write(w, apierror.Conflict("Chirp has already been published."))
+		return database.Chirp{}, false
+	}
+
+	return chirp, true
+}
+
+// publishChirp publishes a draft or scheduled chirp immediately.
+func (cfg *apiConfig) publishChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirp, ok := cfg.userUnpublishedChirp(w, r)
+	if !ok {
+		return
+	}
+	id := chirp.ID
+
+	tx, err := cfg.store.Begin(r.Context())
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/publish: Error starting transaction: %v\n", id, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	defer tx.Rollback()
+
+	err = checkPublishable(r.Context(), tx, chirp, time.Now().UTC())
+	if err != nil {
+		writeChirpCheckError(w, r, err)
+		return
+	}
+
+	chirp, err = tx.PublishChirp(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The publisher got to it first.
+		apierror.Write(w, apierror.Conflict("Chirp has already been published."))
+		return
+	}
+	if err == nil {
+		err = enqueueWebhookEvent(r.Context(), tx, chirp.UserID, CHIRP_CREATED_EVENT, newChirpResponse(chirp))
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/publish: Error publishing chirp: %v\n", id, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newChirpResponse(chirp))
+	if err != nil {
+		log.Printf("POST /api/chirps/%s/publish: Error writing response: %v\n", id, err)
+	}
+}
+
+// scheduleChirp schedules a draft, or reschedules a scheduled chirp.
+// Scheduling is a Chirpy Red feature.
+func (cfg *apiConfig) scheduleChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirp, ok := cfg.userUnpublishedChirp(w, r)
+	if !ok {
+		return
+	}
+	id := chirp.ID
+
+	plan, err := cfg.userPlan(r.Context(), chirp.UserID)
+	if err != nil {
+		log.Printf("PUT /api/chirps/%s/schedule: Error retrieving plan: %v\n", id, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	var missing entitlements.MissingError
+	if errors.As(plan.Require(entitlements.SCHEDULE_CHIRPS), &missing) {
+		writeEntitlementError(w, missing)
+		return
+	}
+
+	reqBody := &scheduleChirpReqParams{}
+	err = request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	if problem := checkPublishAt(reqBody.PublishAt); problem != "" {
+		apierror.Write(w, request.FieldErrors{"publish_at": problem}.Err())
+		return
+	}
+
+	err = chirpPollError(r.Context(), cfg.store, id, *reqBody.PublishAt)
+	if err != nil {
+		writeChirpCheckError(w, r, err)
+		return
+	}
+
+	chirp, err = cfg.store.ScheduleChirp(r.Context(), database.ScheduleChirpParams{
+		ID:        id,
+		PublishAt: sql.NullTime{Time: reqBody.PublishAt.UTC(), Valid: true},
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.Conflict("Chirp has already been published."))
+		return
+	}
+	if err != nil {
+		log.Printf("PUT /api/chirps/%s/schedule: Error scheduling chirp: %v\n", id, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newChirpResponse(chirp))
+	if err != nil {
+		log.Printf("PUT /api/chirps/%s/schedule: Error writing response: %v\n", id, err)
+	}
+}
+
+// unscheduleChirp turns a scheduled chirp back into a draft.
+func (cfg *apiConfig) unscheduleChirp(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirp, ok := cfg.userUnpublishedChirp(w, r)
+	if !ok {
+		return
+	}
+	id := chirp.ID
+
+	if chirp.Status != CHIRP_SCHEDULED {
+		apierror.Write(w, apierror.Conflict("Chirp is not scheduled."))
+		return
+	}
+
+	chirp, err := cfg.store.UnscheduleChirp(r.Context(), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, apierror.Conflict("Chirp has already been published."))
+		return
+	}
+	if err != nil {
+		log.Printf("DELETE /api/chirps/%s/schedule: Error unscheduling chirp: %v\n", id, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newChirpResponse(chirp))
+	if err != nil {
+		log.Printf("DELETE /api/chirps/%s/schedule: Error writing response: %v\n", id, err)
+	}
+}
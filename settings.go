@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+)
+
+type userSettings struct {
+	DMPolicy  string `json:"dm_policy"`
+	Protected bool   `json:"protected"`
+}
+
+type updateSettingsReqParams struct {
+	// Unset fields are left as they are.
+	DMPolicy  *string `json:"dm_policy,omitempty"`
+	Protected *bool   `json:"protected,omitempty"`
+}
+
+func newUserSettings(user database.User) userSettings {
+	return userSettings{DMPolicy: user.DmPolicy, Protected: user.Protected}
+}
+
+func (cfg *apiConfig) getSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	user, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/users/settings: Error retrieving user %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newUserSettings(user))
+	if err != nil {
+		log.Printf("GET /api/users/settings: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) updateSettings(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	reqBody := &updateSettingsReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	if reqBody.DMPolicy != nil {
+		switch *reqBody.DMPolicy {
+		case DM_EVERYONE, DM_FOLLOWING, DM_NOBODY:
+		default:
+			apierror.Write(w, apierror.BadRequest("dm_policy must be everyone, following or nobody."))
+			return
+		}
+
+		err = cfg.store.SetDMPolicy(r.Context(), database.SetDMPolicyParams{
+			ID:       userID,
+			DmPolicy: *reqBody.DMPolicy,
+		})
+		if err != nil {
+			log.Printf("PUT /api/users/settings: Error updating DM policy for %s: %v\n", userID, err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+	}
+
+	if reqBody.Protected != nil {
+		tx, err := cfg.store.Begin(r.Context())
+		if err != nil {
+			log.Printf("PUT /api/users/settings: Error starting transaction: %v\n", err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+		defer tx.Rollback()
+
+		err = tx.SetProtected(r.Context(), database.SetProtectedParams{
+			ID:        userID,
+			Protected: *reqBody.Protected,
+		})
+		// Nobody is left waiting once approval is no longer needed.
+		if err == nil && !*reqBody.Protected {
+			err = tx.AcceptAllFollowRequests(r.Context(), userID)
+		}
+		if err == nil {
+			err = tx.Commit()
+		}
+		if err != nil {
+			log.Printf("PUT /api/users/settings: Error updating protected for %s: %v\n", userID, err)
+			apierror.Write(w, apierror.Internal("Database error"))
+			return
+		}
+	}
+
+	user, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("PUT /api/users/settings: Error retrieving user %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newUserSettings(user))
+	if err != nil {
+		log.Printf("PUT /api/users/settings: Error writing response: %v\n", err)
+	}
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/stream"
+	"github.com/coder/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	// Each connection buffers this many events per stream. A client that
+	// falls further behind is disconnected and should reconnect.
+	SOCKET_BUFFER            = 256
+	SOCKET_REQUEST_BUFFER    = 16
+	SOCKET_WRITE_TIMEOUT     = 10 * time.Second
+	SOCKET_PING_INTERVAL     = 30 * time.Second
+	SOCKET_PONG_TIMEOUT      = 10 * time.Second
+	SOCKET_TIMELINE_REFRESH  = time.Minute
+	MAX_SOCKET_MESSAGE_BYTES = 4096
+	MAX_SOCKET_TOPICS        = 100
+	MAX_HASHTAG_LENGTH       = 100
+)
+
+// Topic kinds clients can subscribe to, as "<kind>:<value>".
+const (
+	HASHTAG_TOPIC = "hashtag"
+	AUTHOR_TOPIC  = "author"
+	THREAD_TOPIC  = "thread"
+)
+
+// Messages sent by clients.
+const (
+	SOCKET_SUBSCRIBE   = "subscribe"
+	SOCKET_UNSUBSCRIBE = "unsubscribe"
+)
+
+// Replies sent to clients. Events are sent with their own type, such as
+// chirp.created or notification.
+const (
+	SOCKET_SUBSCRIBED   = "subscribed"
+	SOCKET_UNSUBSCRIBED = "unsubscribed"
+	SOCKET_ERROR        = "error"
+)
+
+// socketHub tracks open WebSocket connections so they can be drained when
+// the server shuts down. Hijacked connections aren't waited for by
+// http.Server.Shutdown.
+type socketHub struct {
+	mu      sync.Mutex
+	wg      sync.WaitGroup
+	closed  bool
+	closing chan struct{}
+}
+
+func newSocketHub() *socketHub {
+	return &socketHub{closing: make(chan struct{})}
+}
+
+// join registers a connection. It returns false once the hub is closed.
+func (h *socketHub) join() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return false
+	}
+	h.wg.Add(1)
+	return true
+}
+
+func (h *socketHub) leave() {
+	h.wg.Done()
+}
+
+// close tells every connection to drain and disconnect.
+func (h *socketHub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if !h.closed {
+		h.closed = true
+		close(h.closing)
+	}
+}
+
+// wait blocks until every connection has left, or ctx is done.
+func (h *socketHub) wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type socketRequest struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+	// Set by the server when the message couldn't be read.
+	err string
+}
+
+type socketReply struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type socketEvent struct {
+	ID   int64           `json:"id"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+	// Whether a chirp belongs on the user's timeline, and which of their
+	// topics it matched.
+	Timeline bool     `json:"timeline,omitempty"`
+	Topics   []string `json:"topics,omitempty"`
+}
+
+// parseTopic validates a topic and returns it in canonical form.
+func parseTopic(topic string) (string, bool) {
+	kind, value, ok := strings.Cut(topic, ":")
+	if !ok {
+		return "", false
+	}
+
+	switch kind {
+	case HASHTAG_TOPIC:
+		value = strings.ToLower(strings.TrimPrefix(value, "#"))
+		if !validHashtag(value) {
+			return "", false
+		}
+	case AUTHOR_TOPIC, THREAD_TOPIC:
+		id, err := uuid.Parse(value)
+		if err != nil {
+			return "", false
+		}
+		value = id.String()
+	default:
+		return "", false
+	}
+	return kind + ":" + value, true
+}
+
+// validHashtag reports whether tag could have been extracted from a chirp.
+// Chirps are tagged with the word characters following a '#'.
+func validHashtag(tag string) bool {
+	if tag == "" || utf8.RuneCountInString(tag) > MAX_HASHTAG_LENGTH {
+		return false
+	}
+	for _, r := range tag {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// chirpTopics returns the topics a chirp event is published to.
+func chirpTopics(e stream.Event) []string {
+	topics := make([]string, 0, len(e.Tags)+2)
+	topics = append(topics, AUTHOR_TOPIC+":"+e.AuthorID.String(), THREAD_TOPIC+":"+e.ThreadID.String())
+	for _, tag := range e.Tags {
+		topics = append(topics, HASHTAG_TOPIC+":"+tag)
+	}
+	return topics
+}
+
+// socketSession is the state of one WebSocket connection. It is only used
+// from the connection's main loop.
+type socketSession struct {
+	conn     *websocket.Conn
+	userID   uuid.UUID
+	timeline map[uuid.UUID]struct{}
+	// Users hidden from this one by blocks and mutes.
+	hidden map[uuid.UUID]struct{}
+	topics map[string]struct{}
+}
+
+func (s *socketSession) write(ctx context.Context, msg any) error {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	// A client that can't take a message within the timeout is treated as
+	// gone. Events queue up behind it until it is dropped by the broker.
+	ctx, cancel := context.WithTimeout(ctx, SOCKET_WRITE_TIMEOUT)
+	defer cancel()
+	return s.conn.Write(ctx, websocket.MessageText, data)
+}
+
+func (s *socketSession) handleRequest(ctx context.Context, req socketRequest) error {
+	if req.err != "" {
+		return s.write(ctx, socketReply{Type: SOCKET_ERROR, Error: req.err})
+	}
+	if req.Type != SOCKET_SUBSCRIBE && req.Type != SOCKET_UNSUBSCRIBE {
+		return s.write(ctx, socketReply{Type: SOCKET_ERROR, Error: "type must be subscribe or unsubscribe."})
+	}
+
+	topic, ok := parseTopic(req.Topic)
+	if !ok {
+		return s.write(ctx, socketReply{
+			Type:  SOCKET_ERROR,
+			Topic: req.Topic,
+			Error: "topic must be hashtag:<tag>, author:<user id> or thread:<chirp id>.",
+		})
+	}
+
+	if req.Type == SOCKET_UNSUBSCRIBE {
+		delete(s.topics, topic)
+		return s.write(ctx, socketReply{Type: SOCKET_UNSUBSCRIBED, Topic: topic})
+	}
+
+	if _, ok := s.topics[topic]; !ok && len(s.topics) >= MAX_SOCKET_TOPICS {
+		return s.write(ctx, socketReply{
+			Type:  SOCKET_ERROR,
+			Topic: topic,
+			Error: "Too many topics. Unsubscribe from some first.",
+		})
+	}
+	s.topics[topic] = struct{}{}
+	return s.write(ctx, socketReply{Type: SOCKET_SUBSCRIBED, Topic: topic})
+}
+
+// writeChirpEvent sends e if it belongs on the user's timeline or matches
+// one of their topics.
+func (s *socketSession) writeChirpEvent(ctx context.Context, e stream.Event) error {
+	if _, ok := s.hidden[e.AuthorID]; ok {
+		return nil
+	}
+	// The timeline is everyone the user follows, and themselves.
+	if !chirpEventVisible(e, uuid.NullUUID{UUID: s.userID, Valid: true}, s.timeline) {
+		return nil
+	}
+	msg := socketEvent{ID: e.ID, Type: e.Type, Data: e.Data}
+	_, msg.Timeline = s.timeline[e.AuthorID]
+	for _, topic := range chirpTopics(e) {
+		if _, ok := s.topics[topic]; ok {
+			msg.Topics = append(msg.Topics, topic)
+		}
+	}
+
+	if !msg.Timeline && len(msg.Topics) == 0 {
+		return nil
+	}
+	return s.write(ctx, msg)
+}
+
+func (s *socketSession) writeNotification(ctx context.Context, e stream.Event) error {
+	if e.UserID != s.userID {
+		return nil
+	}
+	if _, ok := s.hidden[e.AuthorID]; ok {
+		return nil
+	}
+	return s.write(ctx, socketEvent{ID: e.ID, Type: e.Type, Data: e.Data})
+}
+
+// drain sends the events that were already buffered when the server began
+// shutting down.
+func (s *socketSession) drain(ctx context.Context, chirps, notifications <-chan stream.Event) error {
+	for {
+		var err error
+		select {
+		case e, ok := <-chirps:
+			if !ok {
+				chirps = nil
+				continue
+			}
+			err = s.writeChirpEvent(ctx, e)
+		case e, ok := <-notifications:
+			if !ok {
+				notifications = nil
+				continue
+			}
+			err = s.writeNotification(ctx, e)
+		default:
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// readSocketRequests reads client messages until the connection fails. The
+// requests channel is bounded, so a client sending faster than they are
+// handled is slowed down by TCP rather than buffered without limit.
+func readSocketRequests(ctx context.Context, conn *websocket.Conn, requests chan<- socketRequest) {
+	defer close(requests)
+	for {
+		typ, data, err := conn.Read(ctx)
+		if err != nil {
+			return
+		}
+
+		req := socketRequest{}
+		if typ != websocket.MessageText {
+			req.err = "Messages must be JSON text."
+		} else if err := json.Unmarshal(data, &req); err != nil {
+			req.err = "Messages must be JSON objects."
+		}
+
+		select {
+		case requests <- req:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// keepSocketAlive pings the client until ctx is done, cancelling the
+// connection if a pong doesn't come back in time. Pongs are read by
+// readSocketRequests.
+func keepSocketAlive(ctx context.Context, conn *websocket.Conn, cancel context.CancelFunc) {
+	ticker := time.NewTicker(SOCKET_PING_INTERVAL)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		pingCtx, pingCancel := context.WithTimeout(ctx, SOCKET_PONG_TIMEOUT)
+		err := conn.Ping(pingCtx)
+		pingCancel()
+		if err != nil {
+			cancel()
+			return
+		}
+	}
+}
+
+// serveSocket upgrades to a WebSocket that carries the user's notifications
+// and new chirps from their timeline, plus chirps matching the topics they
+// subscribe to over the connection.
+func (cfg *apiConfig) serveSocket(w http.ResponseWriter, r *http.Request) {
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	if !cfg.sockets.join() {
+		apierror.Write(w, apierror.Unavailable("Server is shutting down."))
+		return
+	}
+	defer cfg.sockets.leave()
+
+	viewer := uuid.NullUUID{UUID: userID, Valid: true}
+	timeline, err := cfg.timelineAuthors(r.Context(), userID)
+	var hidden map[uuid.UUID]struct{}
+	if err == nil {
+		hidden, err = cfg.hiddenAuthors(r.Context(), viewer)
+	}
+	if err != nil {
+		log.Printf("GET /api/ws: Error retrieving timeline: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	chirps := cfg.chirpStream.Subscribe(SOCKET_BUFFER)
+	defer chirps.Close()
+	notifications := cfg.notificationStream.Subscribe(SOCKET_BUFFER)
+	defer notifications.Close()
+
+	// Accept writes its own error response.
+	conn, err := websocket.Accept(w, r, nil)
+	if err != nil {
+		log.Printf("GET /api/ws: Error accepting connection: %v\n", err)
+		return
+	}
+	defer conn.CloseNow()
+	conn.SetReadLimit(MAX_SOCKET_MESSAGE_BYTES)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	requests := make(chan socketRequest, SOCKET_REQUEST_BUFFER)
+	go readSocketRequests(ctx, conn, requests)
+	go keepSocketAlive(ctx, conn, cancel)
+
+	session := &socketSession{
+		conn:     conn,
+		userID:   userID,
+		timeline: timeline,
+		hidden:   hidden,
+		topics:   map[string]struct{}{},
+	}
+
+	refresh := time.NewTicker(SOCKET_TIMELINE_REFRESH)
+	defer refresh.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// The client went away or stopped answering pings.
+			return
+		case <-cfg.sockets.closing:
+			cfg.closeSocket(ctx, session, chirps, notifications)
+			return
+		case req, ok := <-requests:
+			if !ok {
+				return
+			}
+			err = session.handleRequest(ctx, req)
+		case e, ok := <-chirps.Events():
+			if !ok {
+				cfg.closeSocket(ctx, session, chirps, notifications)
+				return
+			}
+			err = session.writeChirpEvent(ctx, e)
+		case e, ok := <-notifications.Events():
+			if !ok {
+				cfg.closeSocket(ctx, session, chirps, notifications)
+				return
+			}
+			err = session.writeNotification(ctx, e)
+		case <-refresh.C:
+			// Follows, blocks and mutes made while connected are picked
+			// up here.
+			timeline, err := cfg.timelineAuthors(ctx, userID)
+			if err != nil {
+				log.Printf("GET /api/ws: Error refreshing timeline: %v\n", err)
+				continue
+			}
+			hidden, err := cfg.hiddenAuthors(ctx, viewer)
+			if err != nil {
+				log.Printf("GET /api/ws: Error refreshing hidden users: %v\n", err)
+				continue
+			}
+			session.timeline = timeline
+			session.hidden = hidden
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// closeSocket closes a connection that is being shut down, or whose
+// subscription was dropped for falling too far behind. On shutdown, buffered
+// events are sent first.
+func (cfg *apiConfig) closeSocket(ctx context.Context, session *socketSession, chirps, notifications *stream.Subscription) {
+	select {
+	case <-cfg.sockets.closing:
+		err := session.drain(ctx, chirps.Events(), notifications.Events())
+		if err == nil {
+			session.conn.Close(websocket.StatusGoingAway, "Server is shutting down")
+		}
+	default:
+		session.conn.Close(websocket.StatusTryAgainLater, "Connection fell too far behind")
+	}
+}
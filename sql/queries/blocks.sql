@@ -0,0 +1,29 @@
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlocksByUser :many
+SELECT blocker_id, blocked_id, created_at FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: IsBlockedEither :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = $1 AND blocked_id = $2)
+        OR (blocker_id = $2 AND blocked_id = $1)
+);
+
+-- name: GetHiddenUserIDs :many
+-- Users whose content is hidden from a user: everyone they block or mute,
+-- and everyone who blocks them.
+SELECT blocked_id FROM blocks WHERE blocks.blocker_id = $1
+UNION
+SELECT blocker_id FROM blocks WHERE blocks.blocked_id = $1
+UNION
+SELECT muted_id FROM mutes WHERE mutes.muter_id = $1;
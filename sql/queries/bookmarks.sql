@@ -0,0 +1,24 @@
+-- name: BookmarkChirp :exec
+INSERT INTO bookmarks (user_id, chirp_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetBookmarkedChirps :many
+-- A user's bookmarked chirps that they can still see, newest bookmark first.
+SELECT bookmarks.created_at AS bookmarked_at, sqlc.embed(chirps) FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg('user_id')
+    AND bookmarks.created_at < sqlc.arg('before')
+    AND chirps.status = 'published'
+    AND chirp_visible_to(chirps.id, sqlc.arg('user_id'))
+ORDER BY bookmarks.created_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetBookmarksByUser :many
+SELECT user_id, chirp_id, created_at FROM bookmarks
+WHERE user_id = $1
+ORDER BY created_at DESC;
@@ -0,0 +1,12 @@
+-- name: GetChirpEventsAfter :many
+SELECT id, created_at, event, chirp_id, user_id, thread_id, tags, visibility, mentions FROM chirp_events
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: GetLatestChirpEventID :one
+SELECT COALESCE(MAX(id), 0)::BIGINT FROM chirp_events;
+
+-- name: DeleteChirpEventsBefore :execrows
+DELETE FROM chirp_events
+WHERE created_at < $1;
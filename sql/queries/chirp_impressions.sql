@@ -0,0 +1,33 @@
+-- name: AddChirpImpressions :exec
+-- Adds counts[i] impressions to chirp_ids[i] on days[i]. Chirps that have
+-- been deleted since are skipped.
+INSERT INTO chirp_impressions (chirp_id, day, impressions)
+SELECT batch.chirp_id, batch.day::DATE, batch.impressions
+FROM unnest(sqlc.arg('chirp_ids')::UUID[], sqlc.arg('days')::TEXT[], sqlc.arg('counts')::BIGINT[])
+    AS batch(chirp_id, day, impressions)
+JOIN chirps ON chirps.id = batch.chirp_id
+ON CONFLICT (chirp_id, day)
+DO UPDATE SET impressions = chirp_impressions.impressions + EXCLUDED.impressions;
+
+-- name: GetChirpAnalytics :many
+-- Daily impressions and replies for an author's chirps since a day, for
+-- days that had either.
+WITH activity AS (
+    SELECT chirp_impressions.chirp_id, chirp_impressions.day,
+        chirp_impressions.impressions, 0 AS replies
+    FROM chirp_impressions
+    JOIN chirps ON chirps.id = chirp_impressions.chirp_id
+    WHERE chirps.user_id = sqlc.arg('user_id') AND chirp_impressions.day >= sqlc.arg('since')::DATE
+    UNION ALL
+    SELECT replies.reply_to_id, replies.created_at::DATE, 0, 1
+    FROM chirps AS replies
+    JOIN chirps AS parents ON parents.id = replies.reply_to_id
+    WHERE parents.user_id = sqlc.arg('user_id') AND replies.status = 'published'
+        AND replies.created_at::DATE >= sqlc.arg('since')::DATE
+)
+SELECT activity.chirp_id::UUID AS chirp_id, activity.day::DATE AS day,
+    SUM(activity.impressions)::BIGINT AS impressions,
+    SUM(activity.replies)::BIGINT AS replies
+FROM activity
+GROUP BY activity.chirp_id, activity.day
+ORDER BY activity.chirp_id, activity.day;
@@ -1,24 +1,93 @@
 -- name: CreateChirp :one
-INSERT INTO chirps(id, created_at, updated_at, body, user_id)
+INSERT INTO chirps(id, created_at, updated_at, body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
     $1,
-    $2
+    $2,
+    $3,
+    $4,
+    $5,
+    $6,
+    $7,
+    $8,
+    $9
 )
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at ASC;
+-- Only chirps the viewer may see are returned. A NULL viewer is anonymous.
+SELECT * FROM chirps
+WHERE status = 'published' AND chirp_visible_to(id, sqlc.narg('viewer_id')::UUID)
+ORDER BY created_at ASC;
 
 -- name: GetChirpById :one
 SELECT * FROM chirps WHERE id = $1;
 
+-- name: GetVisibleChirpById :one
+SELECT * FROM chirps
+WHERE id = $1 AND status = 'published' AND chirp_visible_to(id, sqlc.narg('viewer_id')::UUID);
+
+-- name: GetVisibleChirpsByIds :many
+SELECT * FROM chirps
+WHERE id = ANY(sqlc.arg('ids')::UUID[]) AND status = 'published'
+    AND chirp_visible_to(id, sqlc.narg('viewer_id')::UUID);
+
 -- name: DeleteChirpById :exec
 DELETE FROM chirps WHERE id = $1;
 
 -- name: GetChirpsByAuthorId :many
 SELECT * FROM chirps 
-WHERE user_id = $1 
+WHERE user_id = $1 AND status = 'published' AND chirp_visible_to(id, sqlc.narg('viewer_id')::UUID)
+ORDER BY created_at ASC;
+
+-- name: GetChirpThread :many
+SELECT * FROM chirps
+WHERE (id = $1 OR thread_id = $1) AND status = 'published'
+    AND chirp_visible_to(id, sqlc.narg('viewer_id')::UUID)
 ORDER BY created_at ASC;
+
+-- name: CountChirpsByAuthorSince :one
+-- Only published chirps count. Publishing a draft or scheduled chirp resets
+-- its created_at, so it counts from when it was published.
+SELECT COUNT(*) FROM chirps
+WHERE user_id = $1 AND status = 'published' AND created_at > $2;
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: GetUnpublishedChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = $1 AND status <> 'published'
+ORDER BY created_at DESC;
+
+-- name: ScheduleChirp :one
+UPDATE chirps
+SET status = 'scheduled', publish_at = $2, updated_at = NOW()
+WHERE id = $1 AND status <> 'published'
+RETURNING *;
+
+-- name: UnscheduleChirp :one
+UPDATE chirps
+SET status = 'draft', publish_at = NULL, updated_at = NOW()
+WHERE id = $1 AND status = 'scheduled'
+RETURNING *;
+
+-- name: PublishChirp :one
+UPDATE chirps
+SET status = 'published', publish_at = NOW(), created_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND status <> 'published'
+RETURNING *;
+
+-- name: LockDueChirps :many
+-- Locks the scheduled chirps that are due, oldest first, until the
+-- transaction ends. Chirps another publisher has locked are skipped.
+SELECT * FROM chirps
+WHERE status = 'scheduled' AND publish_at <= NOW()
+ORDER BY publish_at
+LIMIT $1
+FOR UPDATE SKIP LOCKED;
@@ -0,0 +1,48 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, updated_at, user_id, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    'pending'
+)
+RETURNING id, created_at, updated_at, user_id, status, error, completed_at, expires_at;
+
+-- name: GetLatestDataExport :one
+SELECT id, created_at, updated_at, user_id, status, error, completed_at, expires_at FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetDataExportArchive :one
+SELECT archive FROM data_exports
+WHERE id = $1 AND user_id = $2 AND status = 'complete' AND expires_at > NOW();
+
+-- name: ClaimDataExport :one
+-- An export still running since before stale_before is claimed again, in
+-- case the worker building it died.
+UPDATE data_exports
+SET status = 'running', updated_at = NOW()
+WHERE id = (
+    SELECT id FROM data_exports
+    WHERE status = 'pending' OR (status = 'running' AND updated_at < sqlc.arg('stale_before')::TIMESTAMP)
+    ORDER BY created_at
+    FOR UPDATE SKIP LOCKED
+    LIMIT 1
+)
+RETURNING id, created_at, updated_at, user_id, status, error, completed_at, expires_at;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'complete', archive = $2, completed_at = NOW(), expires_at = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeleteExpiredDataExports :exec
+DELETE FROM data_exports
+WHERE expires_at <= NOW();
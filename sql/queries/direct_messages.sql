@@ -0,0 +1,84 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1)
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW());
+
+-- name: GetConversationMember :one
+SELECT * FROM conversation_members
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetDirectConversation :one
+-- The one-to-one conversation between two users, if they have one.
+SELECT conversations.id, conversations.created_at, conversations.updated_at, conversations.created_by
+FROM conversations
+JOIN conversation_members a ON a.conversation_id = conversations.id AND a.user_id = sqlc.arg('user_id')
+JOIN conversation_members b ON b.conversation_id = conversations.id AND b.user_id = sqlc.arg('other_user_id')
+WHERE (
+    SELECT COUNT(*) FROM conversation_members m
+    WHERE m.conversation_id = conversations.id
+) = 2
+LIMIT 1;
+
+-- name: GetConversationsByUser :many
+SELECT conversations.id, conversations.created_at, conversations.updated_at, conversations.created_by,
+    conversation_members.last_read_at,
+    (
+        SELECT COUNT(*) FROM direct_messages
+        WHERE direct_messages.conversation_id = conversations.id
+            AND direct_messages.sender_id <> sqlc.arg('user_id')
+            AND (conversation_members.last_read_at IS NULL
+                OR direct_messages.created_at > conversation_members.last_read_at)
+    ) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = sqlc.arg('user_id')
+    AND conversations.updated_at < sqlc.arg('before')
+ORDER BY conversations.updated_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetConversationMembers :many
+SELECT conversation_id, user_id FROM conversation_members
+WHERE conversation_id = ANY(sqlc.arg('conversation_ids')::uuid[])
+ORDER BY joined_at;
+
+-- name: IsBlockedInConversation :one
+-- Whether anyone else in the conversation has blocked the user, or been
+-- blocked by them.
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members
+    JOIN blocks ON (blocks.blocker_id = conversation_members.user_id AND blocks.blocked_id = sqlc.arg('user_id'))
+        OR (blocks.blocker_id = sqlc.arg('user_id') AND blocks.blocked_id = conversation_members.user_id)
+    WHERE conversation_members.conversation_id = sqlc.arg('conversation_id')
+        AND conversation_members.user_id <> sqlc.arg('user_id')
+);
+
+-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (id, created_at, conversation_id, sender_id, body)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: GetDirectMessages :many
+SELECT * FROM direct_messages
+WHERE conversation_id = sqlc.arg('conversation_id')
+    AND created_at < sqlc.arg('before')
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetDirectMessagesBySender :many
+SELECT * FROM direct_messages
+WHERE sender_id = $1
+ORDER BY created_at;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
@@ -0,0 +1,28 @@
+-- name: CreateExternalIdentity :one
+INSERT INTO external_identities (
+    id,
+    created_at,
+    updated_at,
+    user_id,
+    issuer,
+    subject,
+    email
+) VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+) RETURNING *;
+
+-- name: GetUserByExternalIdentity :one
+SELECT users.* FROM users
+JOIN external_identities ON external_identities.user_id = users.id
+WHERE external_identities.issuer = $1 AND external_identities.subject = $2;
+
+-- name: GetExternalIdentitiesByUser :many
+SELECT * FROM external_identities
+WHERE user_id = $1
+ORDER BY created_at;
@@ -0,0 +1,29 @@
+-- name: CreateFollowRequest :exec
+INSERT INTO follow_requests (requester_id, target_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollowRequest :execrows
+DELETE FROM follow_requests
+WHERE requester_id = $1 AND target_id = $2;
+
+-- name: DeleteFollowRequestsBetween :exec
+DELETE FROM follow_requests
+WHERE (requester_id = $1 AND target_id = $2)
+    OR (requester_id = $2 AND target_id = $1);
+
+-- name: GetFollowRequestsByTarget :many
+SELECT * FROM follow_requests
+WHERE target_id = $1
+ORDER BY created_at DESC;
+
+-- name: AcceptAllFollowRequests :exec
+-- Turns every pending request to follow target_id into a follow.
+WITH accepted AS (
+    DELETE FROM follow_requests
+    WHERE target_id = $1
+    RETURNING requester_id, target_id
+)
+INSERT INTO follows (follower_id, followee_id, created_at)
+SELECT requester_id, target_id, NOW() FROM accepted
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,34 @@
+-- name: QueueLinkPreview :exec
+-- Previews fetched before stale_before are fetched again.
+INSERT INTO link_previews (url, created_at)
+VALUES ($1, NOW())
+ON CONFLICT (url) DO UPDATE
+SET status = 'pending', lease_until = NULL
+WHERE link_previews.status <> 'pending' AND link_previews.fetched_at < sqlc.arg('stale_before')::TIMESTAMP;
+
+-- name: ClaimLinkPreview :one
+UPDATE link_previews
+SET lease_until = sqlc.arg('lease_until')::TIMESTAMP
+WHERE url = (
+    SELECT url FROM link_previews
+    WHERE status = 'pending' AND (lease_until IS NULL OR lease_until <= NOW())
+    ORDER BY created_at
+    FOR UPDATE SKIP LOCKED
+    LIMIT 1
+)
+RETURNING url;
+
+-- name: SaveLinkPreview :exec
+UPDATE link_previews
+SET status = 'ready', lease_until = NULL, fetched_at = NOW(),
+    title = $2, description = $3, image_url = $4, site_name = $5
+WHERE url = $1;
+
+-- name: FailLinkPreview :exec
+UPDATE link_previews
+SET status = 'failed', lease_until = NULL, fetched_at = NOW()
+WHERE url = $1;
+
+-- name: GetLinkPreviews :many
+SELECT url, created_at, status, lease_until, fetched_at, title, description, image_url, site_name FROM link_previews
+WHERE url = ANY(sqlc.arg('urls')::TEXT[]) AND status = 'ready';
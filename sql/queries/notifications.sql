@@ -0,0 +1,18 @@
+-- name: GetNotificationsAfter :many
+SELECT id, created_at, user_id, type, actor_id, chirp_id FROM notifications
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
+
+-- name: GetLatestNotificationID :one
+SELECT COALESCE(MAX(id), 0)::BIGINT FROM notifications;
+
+-- name: GetNotificationsByUser :many
+SELECT id, created_at, user_id, type, actor_id, chirp_id FROM notifications
+WHERE user_id = $1 AND id < $2
+ORDER BY id DESC
+LIMIT $3;
+
+-- name: DeleteNotificationsBefore :execrows
+DELETE FROM notifications
+WHERE created_at < $1;
@@ -0,0 +1,87 @@
+-- name: CreateWebhookEndpoint :one
+INSERT INTO webhook_endpoints (id, created_at, updated_at, user_id, url, secret, events)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING id, created_at, updated_at, user_id, url, secret, events;
+
+-- name: GetWebhookEndpoint :one
+SELECT id, created_at, updated_at, user_id, url, secret, events FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: GetWebhookEndpointsByUser :many
+SELECT id, created_at, updated_at, user_id, url, secret, events FROM webhook_endpoints
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueOutboundWebhooks :execrows
+INSERT INTO outbound_webhooks (id, created_at, updated_at, endpoint_id, event, payload, status, next_attempt_at)
+SELECT gen_random_uuid(), NOW(), NOW(), id, $2, $3, 'pending', NOW()
+FROM webhook_endpoints
+WHERE user_id = $1 AND $2::text = ANY(events);
+
+-- name: ClaimOutboundWebhook :one
+WITH claimed AS (
+    UPDATE outbound_webhooks
+    SET attempts = attempts + 1, next_attempt_at = $1, updated_at = NOW()
+    WHERE outbound_webhooks.id = (
+        SELECT id FROM outbound_webhooks
+        WHERE status = 'pending' AND next_attempt_at <= NOW()
+        ORDER BY next_attempt_at
+        FOR UPDATE SKIP LOCKED
+        LIMIT 1
+    )
+    RETURNING id, endpoint_id, event, payload, attempts
+)
+SELECT claimed.id, claimed.endpoint_id, claimed.event, claimed.payload, claimed.attempts, webhook_endpoints.url, webhook_endpoints.secret
+FROM claimed
+JOIN webhook_endpoints ON webhook_endpoints.id = claimed.endpoint_id;
+
+-- name: CreateOutboundWebhookAttempt :exec
+INSERT INTO outbound_webhook_attempts (id, created_at, outbound_webhook_id, attempt, status_code, error, duration_ms)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4, $5);
+
+-- name: MarkOutboundWebhookDelivered :exec
+UPDATE outbound_webhooks
+SET status = 'delivered', delivered_at = NOW(), last_error = NULL, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RetryOutboundWebhook :exec
+UPDATE outbound_webhooks
+SET next_attempt_at = $2, last_error = $3, updated_at = NOW()
+WHERE id = $1;
+
+-- name: DeadLetterOutboundWebhook :exec
+UPDATE outbound_webhooks
+SET status = 'dead', last_error = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: RequeueOutboundWebhook :execrows
+UPDATE outbound_webhooks
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), updated_at = NOW()
+WHERE id = $1 AND endpoint_id = $2 AND status = 'dead';
+
+-- name: GetOutboundWebhook :one
+SELECT id, created_at, updated_at, endpoint_id, event, payload, status, attempts, next_attempt_at, last_error, delivered_at FROM outbound_webhooks
+WHERE id = $1 AND endpoint_id = $2;
+
+-- name: GetOutboundWebhooksByEndpoint :many
+SELECT id, created_at, updated_at, endpoint_id, event, payload, status, attempts, next_attempt_at, last_error, delivered_at FROM outbound_webhooks
+WHERE endpoint_id = $1
+ORDER BY created_at DESC
+LIMIT $2;
+
+-- name: GetOutboundWebhookAttempts :many
+SELECT id, created_at, outbound_webhook_id, attempt, status_code, error, duration_ms FROM outbound_webhook_attempts
+WHERE outbound_webhook_id = $1
+ORDER BY attempt;
@@ -0,0 +1,24 @@
+-- name: PinChirp :execrows
+-- Takes the lowest free position. Affects no rows if the chirp is already
+-- pinned or every position is taken.
+INSERT INTO pinned_chirps (user_id, position, chirp_id, created_at)
+SELECT sqlc.arg('user_id')::UUID, free.position, sqlc.arg('chirp_id')::UUID, NOW()
+FROM generate_series(0, 2) AS free(position)
+WHERE NOT EXISTS (
+    SELECT 1 FROM pinned_chirps
+    WHERE pinned_chirps.user_id = sqlc.arg('user_id')::UUID
+        AND pinned_chirps.position = free.position
+)
+ORDER BY free.position
+LIMIT 1
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: GetPinnedChirpIDs :many
+-- Most recently pinned first.
+SELECT chirp_id FROM pinned_chirps
+WHERE user_id = $1
+ORDER BY created_at DESC, position DESC;
@@ -0,0 +1,4 @@
+-- name: RecordPolkaEvent :execrows
+INSERT INTO polka_events (event_id, event, received_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT (event_id) DO NOTHING;
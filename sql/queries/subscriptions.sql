@@ -0,0 +1,36 @@
+-- name: GetSubscriptionByUser :one
+SELECT * FROM subscriptions
+WHERE user_id = $1;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end)
+VALUES ($1, NOW(), NOW(), $2, $3)
+ON CONFLICT (user_id) DO UPDATE
+SET status = EXCLUDED.status,
+    current_period_end = EXCLUDED.current_period_end,
+    updated_at = NOW()
+RETURNING *;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (id, created_at, user_id, event, status, period_end)
+VALUES (gen_random_uuid(), NOW(), $1, $2, $3, $4);
+
+-- name: GetSubscriptionEventsByUser :many
+SELECT * FROM subscription_events
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: ExpireLapsedSubscriptions :execrows
+WITH expired AS (
+    UPDATE subscriptions
+    SET status = 'expired', updated_at = NOW()
+    WHERE status IN ('active', 'past_due', 'canceled') AND current_period_end <= NOW()
+    RETURNING user_id, current_period_end
+), history AS (
+    INSERT INTO subscription_events (id, created_at, user_id, event, status, period_end)
+    SELECT gen_random_uuid(), NOW(), user_id, 'subscription.expired', 'expired', current_period_end
+    FROM expired
+)
+UPDATE users
+SET is_chirpy_red = FALSE, updated_at = NOW()
+WHERE id IN (SELECT user_id FROM expired);
@@ -30,3 +30,44 @@ RETURNING id, created_at, updated_at, email;
 UPDATE users
 SET is_chirpy_red = TRUE, updated_at = NOW()
 WHERE id = $1;
+
+-- name: ScheduleUserDeletion :one
+UPDATE users
+SET deletion_scheduled_at = $2, updated_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: CancelUserDeletion :execrows
+UPDATE users
+SET deletion_scheduled_at = NULL, updated_at = NOW()
+WHERE id = $1 AND deletion_scheduled_at IS NOT NULL;
+
+-- name: DeleteUsersPastGracePeriod :execrows
+DELETE FROM users
+WHERE deletion_scheduled_at <= NOW();
+
+-- name: SetChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetDMPolicy :exec
+UPDATE users
+SET dm_policy = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: SetProtected :exec
+UPDATE users
+SET protected = $2, updated_at = NOW()
+WHERE id = $1;
+
+-- name: CountMentionableUsers :one
+-- Counts the users in ids that exist and aren't on either side of a block
+-- with user_id.
+SELECT COUNT(*) FROM users
+WHERE id = ANY(sqlc.arg('ids')::UUID[])
+    AND NOT EXISTS (
+        SELECT 1 FROM blocks
+        WHERE (blocker_id = users.id AND blocked_id = sqlc.arg('user_id'))
+            OR (blocker_id = sqlc.arg('user_id') AND blocked_id = users.id)
+    );
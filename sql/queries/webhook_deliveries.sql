@@ -0,0 +1,33 @@
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (id, received_at, source, headers, body, status)
+VALUES (
+    gen_random_uuid(),
+    NOW(),
+    $1,
+    $2,
+    $3,
+    'received'
+)
+RETURNING *;
+
+-- name: UpdateWebhookDeliveryOutcome :one
+UPDATE webhook_deliveries
+SET status = $2, event_id = $3, event = $4, error = $5, processed_at = NOW()
+WHERE id = $1
+RETURNING *;
+
+-- name: IncrementWebhookReplayCount :exec
+UPDATE webhook_deliveries
+SET replay_count = replay_count + 1
+WHERE id = $1;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE (sqlc.narg('status')::text IS NULL OR status = sqlc.narg('status'))
+  AND received_at < sqlc.arg('before')
+ORDER BY received_at DESC
+LIMIT sqlc.arg('limit');
@@ -0,0 +1,14 @@
+-- +goose Up
+CREATE TABLE external_identities (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    email TEXT NOT NULL,
+    UNIQUE (issuer, subject)
+);
+
+-- +goose Down
+DROP TABLE external_identities;
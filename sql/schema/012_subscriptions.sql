@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE subscriptions (
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('active', 'past_due', 'canceled', 'expired', 'refunded')),
+    current_period_end TIMESTAMP NOT NULL
+);
+
+CREATE TABLE subscription_events (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    event TEXT NOT NULL,
+    status TEXT NOT NULL,
+    period_end TIMESTAMP NOT NULL
+);
+
+CREATE INDEX subscription_events_user_id_idx ON subscription_events(user_id, created_at);
+
+-- Existing Chirpy Red members get a subscription that lapses unless renewed.
+INSERT INTO subscriptions (user_id, created_at, updated_at, status, current_period_end)
+SELECT id, NOW(), NOW(), 'active', NOW() + INTERVAL '30 days'
+FROM users
+WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscription_events;
+DROP TABLE subscriptions;
@@ -0,0 +1,20 @@
+-- +goose Up
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY,
+    received_at TIMESTAMP NOT NULL,
+    source TEXT NOT NULL,
+    headers JSONB NOT NULL,
+    body BYTEA NOT NULL,
+    status TEXT NOT NULL
+        CHECK (status IN ('received', 'rejected', 'processed', 'duplicate', 'failed')),
+    event_id TEXT,
+    event TEXT,
+    error TEXT,
+    processed_at TIMESTAMP,
+    replay_count INTEGER NOT NULL DEFAULT 0
+);
+
+CREATE INDEX webhook_deliveries_received_at_idx ON webhook_deliveries(received_at);
+
+-- +goose Down
+DROP TABLE webhook_deliveries;
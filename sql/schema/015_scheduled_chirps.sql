@@ -0,0 +1,20 @@
+-- +goose Up
+-- Existing chirps are published. A draft or scheduled chirp's created_at is
+-- reset when it is published, so feeds show it at the time it went out.
+ALTER TABLE chirps
+ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('draft', 'scheduled', 'published')),
+ADD COLUMN publish_at TIMESTAMP,
+ADD CONSTRAINT chirps_scheduled_publish_at_check
+    CHECK (status <> 'scheduled' OR publish_at IS NOT NULL);
+
+CREATE INDEX chirps_due_idx ON chirps(publish_at)
+    WHERE status = 'scheduled';
+
+-- +goose Down
+DROP INDEX chirps_due_idx;
+
+ALTER TABLE chirps
+DROP CONSTRAINT chirps_scheduled_publish_at_check,
+DROP COLUMN publish_at,
+DROP COLUMN status;
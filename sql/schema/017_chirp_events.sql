@@ -0,0 +1,51 @@
+-- +goose Up
+-- A short-lived log of chirps being published and deleted, which live streams
+-- replay from. Chirps and users may be gone by the time an event is read, so
+-- there are no foreign keys.
+CREATE TABLE chirp_events (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    event TEXT NOT NULL,
+    chirp_id UUID NOT NULL,
+    user_id UUID NOT NULL
+);
+
+CREATE INDEX chirp_events_created_at_idx ON chirp_events(created_at);
+
+-- Events are recorded by a trigger so that every way a chirp can be published
+-- or deleted, including cascades, is covered. Listeners are told the new
+-- event's id when the transaction commits.
+-- +goose StatementBegin
+CREATE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status <> 'published' THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id)
+        VALUES (NOW(), 'chirp.deleted', OLD.id, OLD.user_id)
+        RETURNING id INTO event_id;
+    ELSIF NEW.status = 'published' AND (TG_OP = 'INSERT' OR OLD.status <> 'published') THEN
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id)
+        VALUES (NOW(), 'chirp.created', NEW.id, NEW.user_id)
+        RETURNING id INTO event_id;
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_record_event
+AFTER INSERT OR UPDATE OF status OR DELETE ON chirps
+FOR EACH ROW EXECUTE FUNCTION record_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirps_record_event ON chirps;
+DROP FUNCTION record_chirp_event();
+DROP TABLE chirp_events;
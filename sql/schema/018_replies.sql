@@ -0,0 +1,92 @@
+-- +goose Up
+-- Replies point at the chirp they answer and at the chirp that started their
+-- thread. Chirps that start a thread have no thread_id. A reply keeps its
+-- thread when the chirp it answers is deleted.
+ALTER TABLE chirps
+ADD COLUMN reply_to_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+ADD COLUMN thread_id UUID;
+
+CREATE INDEX chirps_thread_id_idx ON chirps(thread_id);
+
+-- Events carry what live streams filter on, since the chirp may be gone by
+-- the time they are read.
+ALTER TABLE chirp_events
+ADD COLUMN thread_id UUID,
+ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}';
+
+UPDATE chirp_events SET thread_id = chirp_id;
+
+ALTER TABLE chirp_events
+ALTER COLUMN thread_id SET NOT NULL;
+
+-- +goose StatementBegin
+CREATE FUNCTION chirp_tags(body TEXT) RETURNS TEXT[] AS $$
+    SELECT COALESCE(array_agg(DISTINCT lower(m[1])), '{}')
+    FROM regexp_matches(body, '#(\w+)', 'g') AS t(m);
+$$ LANGUAGE sql IMMUTABLE;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status <> 'published' THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id, thread_id, tags)
+        VALUES (NOW(), 'chirp.deleted', OLD.id, OLD.user_id, COALESCE(OLD.thread_id, OLD.id), chirp_tags(OLD.body))
+        RETURNING id INTO event_id;
+    ELSIF NEW.status = 'published' AND (TG_OP = 'INSERT' OR OLD.status <> 'published') THEN
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id, thread_id, tags)
+        VALUES (NOW(), 'chirp.created', NEW.id, NEW.user_id, COALESCE(NEW.thread_id, NEW.id), chirp_tags(NEW.body))
+        RETURNING id INTO event_id;
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status <> 'published' THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id)
+        VALUES (NOW(), 'chirp.deleted', OLD.id, OLD.user_id)
+        RETURNING id INTO event_id;
+    ELSIF NEW.status = 'published' AND (TG_OP = 'INSERT' OR OLD.status <> 'published') THEN
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id)
+        VALUES (NOW(), 'chirp.created', NEW.id, NEW.user_id)
+        RETURNING id INTO event_id;
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+DROP FUNCTION chirp_tags(TEXT);
+
+ALTER TABLE chirp_events
+DROP COLUMN tags,
+DROP COLUMN thread_id;
+
+DROP INDEX chirps_thread_id_idx;
+
+ALTER TABLE chirps
+DROP COLUMN thread_id,
+DROP COLUMN reply_to_id;
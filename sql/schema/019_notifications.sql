@@ -0,0 +1,75 @@
+-- +goose Up
+-- Notifications are written by triggers, so they are recorded whichever way
+-- a follow or reply is created. Listeners are told the new notification's id
+-- when the transaction commits.
+CREATE TABLE notifications (
+    id BIGSERIAL PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    type TEXT NOT NULL
+        CHECK (type IN ('follow', 'reply')),
+    actor_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications(user_id, id);
+CREATE INDEX notifications_created_at_idx ON notifications(created_at);
+
+-- +goose StatementBegin
+CREATE FUNCTION publish_notification() RETURNS trigger AS $$
+BEGIN
+    PERFORM pg_notify('notifications', NEW.id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER notifications_publish
+AFTER INSERT ON notifications
+FOR EACH ROW EXECUTE FUNCTION publish_notification();
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_follow() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO notifications (created_at, user_id, type, actor_id)
+    VALUES (NOW(), NEW.followee_id, 'follow', NEW.follower_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follows_notify
+AFTER INSERT ON follows
+FOR EACH ROW EXECUTE FUNCTION notify_follow();
+
+-- Users are notified when a reply to one of their chirps is published, unless
+-- they replied to themselves.
+-- +goose StatementBegin
+CREATE FUNCTION notify_reply() RETURNS trigger AS $$
+BEGIN
+    IF NEW.reply_to_id IS NULL OR NEW.status <> 'published'
+        OR (TG_OP = 'UPDATE' AND OLD.status = 'published') THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO notifications (created_at, user_id, type, actor_id, chirp_id)
+    SELECT NOW(), parent.user_id, 'reply', NEW.user_id, NEW.id
+    FROM chirps parent
+    WHERE parent.id = NEW.reply_to_id AND parent.user_id <> NEW.user_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirps_notify_reply
+AFTER INSERT OR UPDATE OF status ON chirps
+FOR EACH ROW EXECUTE FUNCTION notify_reply();
+
+-- +goose Down
+DROP TRIGGER chirps_notify_reply ON chirps;
+DROP FUNCTION notify_reply();
+DROP TRIGGER follows_notify ON follows;
+DROP FUNCTION notify_follow();
+DROP TRIGGER notifications_publish ON notifications;
+DROP FUNCTION publish_notification();
+DROP TABLE notifications;
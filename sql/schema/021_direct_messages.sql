@@ -0,0 +1,44 @@
+-- +goose Up
+-- Who may start a conversation with a user: anyone, only users they follow,
+-- or no one.
+ALTER TABLE users
+ADD COLUMN dm_policy TEXT NOT NULL DEFAULT 'everyone'
+    CHECK (dm_policy IN ('everyone', 'following', 'nobody'));
+
+-- A conversation's updated_at is bumped by every message, so inboxes can be
+-- sorted by activity.
+CREATE TABLE conversations (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_by UUID REFERENCES users(id) ON DELETE SET NULL
+);
+
+CREATE TABLE conversation_members (
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP NOT NULL,
+    last_read_at TIMESTAMP,
+    PRIMARY KEY (conversation_id, user_id)
+);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members(user_id);
+
+CREATE TABLE direct_messages (
+    id UUID PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    conversation_id UUID NOT NULL REFERENCES conversations(id) ON DELETE CASCADE,
+    sender_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    body TEXT NOT NULL
+);
+
+CREATE INDEX direct_messages_conversation_id_idx ON direct_messages(conversation_id, created_at);
+CREATE INDEX direct_messages_sender_id_idx ON direct_messages(sender_id);
+
+-- +goose Down
+DROP TABLE direct_messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
+
+ALTER TABLE users
+DROP COLUMN dm_policy;
@@ -0,0 +1,198 @@
+-- +goose Up
+-- Chirps by protected users are only shown to their followers, who have to
+-- be approved.
+ALTER TABLE users
+ADD COLUMN protected BOOLEAN NOT NULL DEFAULT false;
+
+-- Chirps are shown to everyone, to the author's followers, or only to the
+-- users they mention. Mentioned users and the author can always see them.
+ALTER TABLE chirps
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+    CHECK (visibility IN ('public', 'followers', 'mentioned')),
+ADD COLUMN mentions UUID[] NOT NULL DEFAULT '{}';
+
+CREATE TABLE follow_requests (
+    requester_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    target_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (requester_id, target_id),
+    CHECK (requester_id <> target_id)
+);
+
+CREATE INDEX follow_requests_target_id_idx ON follow_requests(target_id, created_at);
+
+-- chirp_audience is who a chirp is actually shown to, taking the author
+-- being protected into account.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_audience(visibility TEXT, author_id UUID) RETURNS TEXT AS $$
+    SELECT CASE
+        WHEN visibility = 'public' AND (SELECT protected FROM users WHERE id = author_id)
+            THEN 'followers'
+        ELSE visibility
+    END;
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- chirp_visible_to reports whether viewer_id may see a chirp. A NULL viewer
+-- is anonymous, and only sees chirps shown to everyone.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible_to(chirp_id UUID, viewer_id UUID) RETURNS BOOLEAN AS $$
+    SELECT COALESCE(
+        c.user_id = viewer_id
+        OR viewer_id = ANY(c.mentions)
+        OR CASE chirp_audience(c.visibility, c.user_id)
+            WHEN 'public' THEN true
+            WHEN 'followers' THEN EXISTS (
+                SELECT 1 FROM follows
+                WHERE follower_id = viewer_id AND followee_id = c.user_id
+            )
+            ELSE false
+        END,
+        false
+    )
+    FROM chirps c
+    WHERE c.id = chirp_id;
+$$ LANGUAGE sql STABLE;
+-- +goose StatementEnd
+
+-- Events record who the chirp was shown to, so streams can filter deletions
+-- of chirps that are already gone.
+ALTER TABLE chirp_events
+ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public',
+ADD COLUMN mentions UUID[] NOT NULL DEFAULT '{}';
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status <> 'published' THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id, thread_id, tags, visibility, mentions)
+        VALUES (NOW(), 'chirp.deleted', OLD.id, OLD.user_id, COALESCE(OLD.thread_id, OLD.id), chirp_tags(OLD.body),
+            chirp_audience(OLD.visibility, OLD.user_id), OLD.mentions)
+        RETURNING id INTO event_id;
+    ELSIF NEW.status = 'published' AND (TG_OP = 'INSERT' OR OLD.status <> 'published') THEN
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id, thread_id, tags, visibility, mentions)
+        VALUES (NOW(), 'chirp.created', NEW.id, NEW.user_id, COALESCE(NEW.thread_id, NEW.id), chirp_tags(NEW.body),
+            chirp_audience(NEW.visibility, NEW.user_id), NEW.mentions)
+        RETURNING id INTO event_id;
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- Users aren't notified of replies they can't see.
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_reply() RETURNS trigger AS $$
+BEGIN
+    IF NEW.reply_to_id IS NULL OR NEW.status <> 'published'
+        OR (TG_OP = 'UPDATE' AND OLD.status = 'published') THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO notifications (created_at, user_id, type, actor_id, chirp_id)
+    SELECT NOW(), parent.user_id, 'reply', NEW.user_id, NEW.id
+    FROM chirps parent
+    WHERE parent.id = NEW.reply_to_id AND parent.user_id <> NEW.user_id
+        AND chirp_visible_to(NEW.id, parent.user_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('follow', 'follow_request', 'reply'));
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_follow_request() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO notifications (created_at, user_id, type, actor_id)
+    VALUES (NOW(), NEW.target_id, 'follow_request', NEW.requester_id);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER follow_requests_notify
+AFTER INSERT ON follow_requests
+FOR EACH ROW EXECUTE FUNCTION notify_follow_request();
+
+-- +goose Down
+DROP TRIGGER follow_requests_notify ON follow_requests;
+DROP FUNCTION notify_follow_request();
+
+DELETE FROM notifications WHERE type = 'follow_request';
+
+ALTER TABLE notifications
+DROP CONSTRAINT notifications_type_check,
+ADD CONSTRAINT notifications_type_check
+    CHECK (type IN ('follow', 'reply'));
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION notify_reply() RETURNS trigger AS $$
+BEGIN
+    IF NEW.reply_to_id IS NULL OR NEW.status <> 'published'
+        OR (TG_OP = 'UPDATE' AND OLD.status = 'published') THEN
+        RETURN NULL;
+    END IF;
+
+    INSERT INTO notifications (created_at, user_id, type, actor_id, chirp_id)
+    SELECT NOW(), parent.user_id, 'reply', NEW.user_id, NEW.id
+    FROM chirps parent
+    WHERE parent.id = NEW.reply_to_id AND parent.user_id <> NEW.user_id;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE OR REPLACE FUNCTION record_chirp_event() RETURNS trigger AS $$
+DECLARE
+    event_id BIGINT;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        IF OLD.status <> 'published' THEN
+            RETURN NULL;
+        END IF;
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id, thread_id, tags)
+        VALUES (NOW(), 'chirp.deleted', OLD.id, OLD.user_id, COALESCE(OLD.thread_id, OLD.id), chirp_tags(OLD.body))
+        RETURNING id INTO event_id;
+    ELSIF NEW.status = 'published' AND (TG_OP = 'INSERT' OR OLD.status <> 'published') THEN
+        INSERT INTO chirp_events (created_at, event, chirp_id, user_id, thread_id, tags)
+        VALUES (NOW(), 'chirp.created', NEW.id, NEW.user_id, COALESCE(NEW.thread_id, NEW.id), chirp_tags(NEW.body))
+        RETURNING id INTO event_id;
+    ELSE
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_notify('chirp_events', event_id::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+ALTER TABLE chirp_events
+DROP COLUMN mentions,
+DROP COLUMN visibility;
+
+DROP FUNCTION chirp_visible_to(UUID, UUID);
+DROP FUNCTION chirp_audience(TEXT, UUID);
+
+DROP TABLE follow_requests;
+
+ALTER TABLE chirps
+DROP COLUMN mentions,
+DROP COLUMN visibility;
+
+ALTER TABLE users
+DROP COLUMN protected;
@@ -0,0 +1,30 @@
+-- +goose Up
+-- A quote chirp embeds the chirp it quotes. Quotes stay up when the quoted
+-- chirp is deleted.
+ALTER TABLE chirps
+ADD COLUMN quote_id UUID REFERENCES chirps(id) ON DELETE SET NULL;
+
+-- Preview cards are cached per URL and shared by every chirp linking to it.
+-- Pending previews are fetched by a worker, which leases them so replicas
+-- don't fetch the same page. Failed fetches are kept so they aren't retried
+-- until the preview goes stale.
+CREATE TABLE link_previews (
+    url TEXT PRIMARY KEY,
+    created_at TIMESTAMP NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'ready', 'failed')),
+    lease_until TIMESTAMP,
+    fetched_at TIMESTAMP,
+    title TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    image_url TEXT NOT NULL DEFAULT '',
+    site_name TEXT NOT NULL DEFAULT ''
+);
+
+CREATE INDEX link_previews_pending_idx ON link_previews(created_at) WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE link_previews;
+
+ALTER TABLE chirps
+DROP COLUMN quote_id;
@@ -0,0 +1,25 @@
+-- +goose Up
+-- Bookmarks are private to the user who made them.
+CREATE TABLE bookmarks (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, chirp_id)
+);
+
+CREATE INDEX bookmarks_user_id_created_at_idx ON bookmarks(user_id, created_at);
+
+-- Users pin up to three of their own chirps to their profile. Each pin takes
+-- one of three positions, which caps the count without a race.
+CREATE TABLE pinned_chirps (
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    position SMALLINT NOT NULL CHECK (position BETWEEN 0 AND 2),
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (user_id, position),
+    UNIQUE (user_id, chirp_id)
+);
+
+-- +goose Down
+DROP TABLE pinned_chirps;
+DROP TABLE bookmarks;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- How many times each chirp was shown, per UTC day. Servers buffer counts
+-- and add them in batches.
+CREATE TABLE chirp_impressions (
+    chirp_id UUID NOT NULL REFERENCES chirps(id) ON DELETE CASCADE,
+    day DATE NOT NULL,
+    impressions BIGINT NOT NULL,
+    PRIMARY KEY (chirp_id, day)
+);
+
+-- +goose Down
+DROP TABLE chirp_impressions;
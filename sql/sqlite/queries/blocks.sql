@@ -0,0 +1,29 @@
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :execrows
+DELETE FROM blocks
+WHERE blocker_id = ? AND blocked_id = ?;
+
+-- name: GetBlocksByUser :many
+SELECT blocker_id, blocked_id, created_at FROM blocks
+WHERE blocker_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: IsBlockedEither :one
+SELECT CAST(EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg('blocker_id') AND blocked_id = sqlc.arg('blocked_id'))
+        OR (blocker_id = sqlc.arg('blocked_id') AND blocked_id = sqlc.arg('blocker_id'))
+) AS BOOLEAN);
+
+-- name: GetHiddenUserIDs :many
+-- Users whose content is hidden from a user: everyone they block or mute,
+-- and everyone who blocks them.
+SELECT blocked_id FROM blocks WHERE blocks.blocker_id = sqlc.arg('blocker_id')
+UNION
+SELECT blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg('blocker_id')
+UNION
+SELECT muted_id FROM mutes WHERE mutes.muter_id = sqlc.arg('blocker_id');
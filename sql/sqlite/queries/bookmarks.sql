@@ -0,0 +1,33 @@
+-- name: BookmarkChirp :exec
+INSERT INTO bookmarks (user_id, chirp_id)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteBookmark :execrows
+DELETE FROM bookmarks
+WHERE user_id = ? AND chirp_id = ?;
+
+-- name: GetBookmarkedChirps :many
+-- A user's bookmarked chirps that they can still see, newest bookmark first.
+-- Visibility is checked as in GetChirps.
+SELECT bookmarks.created_at AS bookmarked_at, sqlc.embed(chirps) FROM bookmarks
+JOIN chirps ON chirps.id = bookmarks.chirp_id
+WHERE bookmarks.user_id = sqlc.arg('user_id')
+    AND bookmarks.created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('before'))
+    AND chirps.status = 'published'
+    AND (
+        chirps.user_id = sqlc.arg('user_id')
+        OR EXISTS (SELECT 1 FROM json_each(chirps.mentions) WHERE value = sqlc.arg('user_id'))
+        OR CASE
+            WHEN chirps.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = chirps.user_id) THEN TRUE
+            WHEN chirps.visibility = 'mentioned' THEN FALSE
+            ELSE EXISTS (SELECT 1 FROM follows WHERE follower_id = sqlc.arg('user_id') AND followee_id = chirps.user_id)
+        END
+    )
+ORDER BY bookmarks.created_at DESC, bookmarks.rowid DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetBookmarksByUser :many
+SELECT user_id, chirp_id, created_at FROM bookmarks
+WHERE user_id = ?
+ORDER BY created_at DESC, rowid DESC;
@@ -0,0 +1,12 @@
+-- name: GetChirpEventsAfter :many
+SELECT id, created_at, event, chirp_id, user_id, thread_id, tags, visibility, mentions FROM chirp_events
+WHERE id > ?
+ORDER BY id
+LIMIT ?;
+
+-- name: GetLatestChirpEventID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) FROM chirp_events;
+
+-- name: DeleteChirpEventsBefore :execrows
+DELETE FROM chirp_events
+WHERE created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('created_at'));
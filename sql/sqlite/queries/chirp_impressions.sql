@@ -0,0 +1,31 @@
+-- name: AddChirpImpressions :exec
+-- Adds impressions to chirp_id on day, a YYYY-MM-DD date. Chirps that have
+-- been deleted since are skipped.
+INSERT INTO chirp_impressions (chirp_id, day, impressions)
+SELECT id, date(sqlc.arg('day')), sqlc.arg('impressions') FROM chirps
+WHERE id = sqlc.arg('chirp_id')
+ON CONFLICT (chirp_id, day)
+DO UPDATE SET impressions = chirp_impressions.impressions + excluded.impressions;
+
+-- name: GetChirpAnalytics :many
+-- Daily impressions and replies for an author's chirps since a day, for
+-- days that had either. Days are YYYY-MM-DD dates.
+WITH activity AS (
+    SELECT chirp_impressions.chirp_id, chirp_impressions.day,
+        chirp_impressions.impressions, 0 AS replies
+    FROM chirp_impressions
+    JOIN chirps ON chirps.id = chirp_impressions.chirp_id
+    WHERE chirps.user_id = sqlc.arg('user_id') AND chirp_impressions.day >= date(sqlc.arg('since'))
+    UNION ALL
+    SELECT replies.reply_to_id, date(replies.created_at), 0, 1
+    FROM chirps AS replies
+    JOIN chirps AS parents ON parents.id = replies.reply_to_id
+    WHERE parents.user_id = sqlc.arg('user_id') AND replies.status = 'published'
+        AND date(replies.created_at) >= date(sqlc.arg('since'))
+)
+SELECT CAST(activity.chirp_id AS TEXT) AS chirp_id, CAST(activity.day AS TEXT) AS day,
+    CAST(SUM(activity.impressions) AS INTEGER) AS impressions,
+    CAST(SUM(activity.replies) AS INTEGER) AS replies
+FROM activity
+GROUP BY activity.chirp_id, activity.day
+ORDER BY activity.chirp_id, activity.day;
@@ -0,0 +1,133 @@
+-- name: CreateChirp :one
+-- mentions is a JSON array of user ids.
+INSERT INTO chirps (body, user_id, status, publish_at, reply_to_id, thread_id, visibility, mentions, quote_id)
+VALUES (
+    sqlc.arg('body'),
+    sqlc.arg('user_id'),
+    sqlc.arg('status'),
+    strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('publish_at')),
+    sqlc.arg('reply_to_id'),
+    sqlc.arg('thread_id'),
+    sqlc.arg('visibility'),
+    sqlc.arg('mentions'),
+    sqlc.arg('quote_id')
+)
+RETURNING *;
+
+-- name: GetChirps :many
+-- Only chirps the viewer may see are returned: the author and the users a
+-- chirp mentions see it, everyone sees public chirps by users who aren't
+-- protected, and followers see the rest unless only mentioned users may. A
+-- nil viewer is anonymous. Ties in created_at are broken by insertion order.
+SELECT * FROM chirps
+WHERE status = 'published' AND (
+        chirps.user_id = sqlc.arg('viewer_id')
+        OR EXISTS (SELECT 1 FROM json_each(chirps.mentions) WHERE value = sqlc.arg('viewer_id'))
+        OR CASE
+            WHEN chirps.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = chirps.user_id) THEN TRUE
+            WHEN chirps.visibility = 'mentioned' THEN FALSE
+            ELSE EXISTS (SELECT 1 FROM follows WHERE follower_id = sqlc.arg('viewer_id') AND followee_id = chirps.user_id)
+        END
+    )
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: GetChirpById :one
+SELECT * FROM chirps WHERE id = ?;
+
+-- name: GetVisibleChirpById :one
+SELECT * FROM chirps
+WHERE id = sqlc.arg('id') AND status = 'published' AND (
+        chirps.user_id = sqlc.arg('viewer_id')
+        OR EXISTS (SELECT 1 FROM json_each(chirps.mentions) WHERE value = sqlc.arg('viewer_id'))
+        OR CASE
+            WHEN chirps.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = chirps.user_id) THEN TRUE
+            WHEN chirps.visibility = 'mentioned' THEN FALSE
+            ELSE EXISTS (SELECT 1 FROM follows WHERE follower_id = sqlc.arg('viewer_id') AND followee_id = chirps.user_id)
+        END
+    );
+
+-- name: GetVisibleChirpsByIds :many
+SELECT * FROM chirps
+WHERE status = 'published' AND (
+        chirps.user_id = sqlc.arg('viewer_id')
+        OR EXISTS (SELECT 1 FROM json_each(chirps.mentions) WHERE value = sqlc.arg('viewer_id'))
+        OR CASE
+            WHEN chirps.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = chirps.user_id) THEN TRUE
+            WHEN chirps.visibility = 'mentioned' THEN FALSE
+            ELSE EXISTS (SELECT 1 FROM follows WHERE follower_id = sqlc.arg('viewer_id') AND followee_id = chirps.user_id)
+        END
+    )
+    AND id IN (sqlc.slice('ids'));
+
+-- name: DeleteChirpById :exec
+DELETE FROM chirps WHERE id = ?;
+
+-- name: GetChirpsByAuthorId :many
+SELECT * FROM chirps
+WHERE user_id = sqlc.arg('user_id') AND status = 'published' AND (
+        chirps.user_id = sqlc.arg('viewer_id')
+        OR EXISTS (SELECT 1 FROM json_each(chirps.mentions) WHERE value = sqlc.arg('viewer_id'))
+        OR CASE
+            WHEN chirps.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = chirps.user_id) THEN TRUE
+            WHEN chirps.visibility = 'mentioned' THEN FALSE
+            ELSE EXISTS (SELECT 1 FROM follows WHERE follower_id = sqlc.arg('viewer_id') AND followee_id = chirps.user_id)
+        END
+    )
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: GetChirpThread :many
+SELECT * FROM chirps
+WHERE (id = sqlc.arg('id') OR thread_id = sqlc.arg('id')) AND status = 'published'
+    AND (
+        chirps.user_id = sqlc.arg('viewer_id')
+        OR EXISTS (SELECT 1 FROM json_each(chirps.mentions) WHERE value = sqlc.arg('viewer_id'))
+        OR CASE
+            WHEN chirps.visibility = 'public' AND NOT (SELECT protected FROM users WHERE id = chirps.user_id) THEN TRUE
+            WHEN chirps.visibility = 'mentioned' THEN FALSE
+            ELSE EXISTS (SELECT 1 FROM follows WHERE follower_id = sqlc.arg('viewer_id') AND followee_id = chirps.user_id)
+        END
+    )
+ORDER BY created_at ASC, rowid ASC;
+
+-- name: CountChirpsByAuthorSince :one
+-- Only published chirps count. Publishing a draft or scheduled chirp resets
+-- its created_at, so it counts from when it was published.
+SELECT COUNT(*) FROM chirps
+WHERE user_id = sqlc.arg('user_id') AND status = 'published' AND created_at > strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('since'));
+
+-- name: UpdateChirpBody :one
+UPDATE chirps
+SET body = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?
+RETURNING *;
+
+-- name: GetUnpublishedChirpsByAuthor :many
+SELECT * FROM chirps
+WHERE user_id = ? AND status <> 'published'
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: ScheduleChirp :one
+UPDATE chirps
+SET status = 'scheduled', publish_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('publish_at')), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id') AND status <> 'published'
+RETURNING *;
+
+-- name: UnscheduleChirp :one
+UPDATE chirps
+SET status = 'draft', publish_at = NULL, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ? AND status = 'scheduled'
+RETURNING *;
+
+-- name: PublishChirp :one
+UPDATE chirps
+SET status = 'published', publish_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), created_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ? AND status <> 'published'
+RETURNING *;
+
+-- name: LockDueChirps :many
+-- Returns the scheduled chirps that are due, oldest first. Writers take turns
+-- on SQLite, so nobody else can publish them before the transaction ends.
+SELECT * FROM chirps
+WHERE status = 'scheduled' AND publish_at <= strftime('%Y-%m-%d %H:%M:%f', 'now')
+ORDER BY publish_at
+LIMIT ?;
@@ -0,0 +1,44 @@
+-- name: CreateDataExport :one
+INSERT INTO data_exports (user_id, status)
+VALUES (?, 'pending')
+RETURNING id, created_at, updated_at, user_id, status, error, completed_at, expires_at;
+
+-- name: GetLatestDataExport :one
+SELECT id, created_at, updated_at, user_id, status, error, completed_at, expires_at FROM data_exports
+WHERE user_id = ?
+ORDER BY created_at DESC, rowid DESC
+LIMIT 1;
+
+-- name: GetDataExportArchive :one
+SELECT archive FROM data_exports
+WHERE id = ? AND user_id = ? AND status = 'complete' AND expires_at > strftime('%Y-%m-%d %H:%M:%f', 'now');
+
+-- name: ClaimDataExport :one
+-- Writers take turns on SQLite, so the oldest pending export can't be
+-- claimed twice. An export still running since before stale_before is
+-- claimed again, in case the worker building it died.
+UPDATE data_exports
+SET status = 'running', updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = (
+    SELECT id FROM data_exports
+    WHERE status = 'pending'
+        OR (status = 'running' AND updated_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('stale_before')))
+    ORDER BY created_at, rowid
+    LIMIT 1
+)
+RETURNING id, created_at, updated_at, user_id, status, error, completed_at, expires_at;
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'complete', archive = sqlc.arg('archive'), completed_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    expires_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('expires_at')), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id');
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed', error = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: DeleteExpiredDataExports :exec
+DELETE FROM data_exports
+WHERE expires_at <= strftime('%Y-%m-%d %H:%M:%f', 'now');
@@ -0,0 +1,84 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (created_by)
+VALUES (?)
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id)
+VALUES (?, ?);
+
+-- name: GetConversationMember :one
+SELECT * FROM conversation_members
+WHERE conversation_id = ? AND user_id = ?;
+
+-- name: GetDirectConversation :one
+-- The one-to-one conversation between two users, if they have one.
+SELECT conversations.id, conversations.created_at, conversations.updated_at, conversations.created_by
+FROM conversations
+JOIN conversation_members a ON a.conversation_id = conversations.id AND a.user_id = sqlc.arg('user_id')
+JOIN conversation_members b ON b.conversation_id = conversations.id AND b.user_id = sqlc.arg('other_user_id')
+WHERE (
+    SELECT COUNT(*) FROM conversation_members m
+    WHERE m.conversation_id = conversations.id
+) = 2
+LIMIT 1;
+
+-- name: GetConversationsByUser :many
+SELECT conversations.id, conversations.created_at, conversations.updated_at, conversations.created_by,
+    conversation_members.last_read_at,
+    (
+        SELECT COUNT(*) FROM direct_messages
+        WHERE direct_messages.conversation_id = conversations.id
+            AND direct_messages.sender_id <> sqlc.arg('user_id')
+            AND (conversation_members.last_read_at IS NULL
+                OR direct_messages.created_at > conversation_members.last_read_at)
+    ) AS unread_count
+FROM conversations
+JOIN conversation_members ON conversation_members.conversation_id = conversations.id
+WHERE conversation_members.user_id = sqlc.arg('user_id')
+    AND conversations.updated_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('before'))
+ORDER BY conversations.updated_at DESC, conversations.rowid DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetConversationMembers :many
+SELECT conversation_id, user_id FROM conversation_members
+WHERE conversation_id IN (sqlc.slice('conversation_ids'))
+ORDER BY joined_at, rowid;
+
+-- name: IsBlockedInConversation :one
+-- Whether anyone else in the conversation has blocked the user, or been
+-- blocked by them.
+SELECT CAST(EXISTS (
+    SELECT 1 FROM conversation_members
+    JOIN blocks ON (blocks.blocker_id = conversation_members.user_id AND blocks.blocked_id = sqlc.arg('user_id'))
+        OR (blocks.blocker_id = sqlc.arg('user_id') AND blocks.blocked_id = conversation_members.user_id)
+    WHERE conversation_members.conversation_id = sqlc.arg('conversation_id')
+        AND conversation_members.user_id <> sqlc.arg('user_id')
+) AS BOOLEAN);
+
+-- name: CreateDirectMessage :one
+INSERT INTO direct_messages (conversation_id, sender_id, body)
+VALUES (?, ?, ?)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: GetDirectMessages :many
+SELECT * FROM direct_messages
+WHERE conversation_id = sqlc.arg('conversation_id')
+    AND created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('before'))
+ORDER BY created_at DESC, rowid DESC
+LIMIT sqlc.arg('limit');
+
+-- name: GetDirectMessagesBySender :many
+SELECT * FROM direct_messages
+WHERE sender_id = ?
+ORDER BY created_at, rowid;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE conversation_id = ? AND user_id = ?;
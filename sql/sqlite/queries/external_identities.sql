@@ -0,0 +1,14 @@
+-- name: CreateExternalIdentity :one
+INSERT INTO external_identities (user_id, issuer, subject, email)
+VALUES (?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetUserByExternalIdentity :one
+SELECT users.* FROM users
+JOIN external_identities ON external_identities.user_id = users.id
+WHERE external_identities.issuer = ? AND external_identities.subject = ?;
+
+-- name: GetExternalIdentitiesByUser :many
+SELECT * FROM external_identities
+WHERE user_id = ?
+ORDER BY created_at, rowid;
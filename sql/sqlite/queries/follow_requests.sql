@@ -0,0 +1,30 @@
+-- name: CreateFollowRequest :exec
+INSERT INTO follow_requests (requester_id, target_id)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollowRequest :execrows
+DELETE FROM follow_requests
+WHERE requester_id = ? AND target_id = ?;
+
+-- name: DeleteFollowRequestsBetween :exec
+DELETE FROM follow_requests
+WHERE (requester_id = sqlc.arg('requester_id') AND target_id = sqlc.arg('target_id'))
+    OR (requester_id = sqlc.arg('target_id') AND target_id = sqlc.arg('requester_id'));
+
+-- name: GetFollowRequestsByTarget :many
+SELECT * FROM follow_requests
+WHERE target_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: FollowAllRequesters :exec
+-- Turns every pending request to follow target_id into a follow. The
+-- requests are left for the caller to delete.
+INSERT INTO follows (follower_id, followee_id)
+SELECT requester_id, target_id FROM follow_requests
+WHERE target_id = ?
+ON CONFLICT DO NOTHING;
+
+-- name: DeleteFollowRequestsByTarget :exec
+DELETE FROM follow_requests
+WHERE target_id = ?;
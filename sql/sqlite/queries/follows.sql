@@ -0,0 +1,28 @@
+-- name: FollowUser :exec
+INSERT INTO follows (follower_id, followee_id)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UnfollowUser :execrows
+DELETE FROM follows
+WHERE follower_id = ? AND followee_id = ?;
+
+-- name: GetFolloweeIDs :many
+SELECT followee_id FROM follows
+WHERE follower_id = ?;
+
+-- name: GetFollowsByUser :many
+SELECT follower_id, followee_id, created_at FROM follows
+WHERE follower_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: IsFollowing :one
+SELECT CAST(EXISTS (
+    SELECT 1 FROM follows
+    WHERE follower_id = ? AND followee_id = ?
+) AS BOOLEAN);
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = sqlc.arg('follower_id') AND followee_id = sqlc.arg('followee_id'))
+    OR (follower_id = sqlc.arg('followee_id') AND followee_id = sqlc.arg('follower_id'));
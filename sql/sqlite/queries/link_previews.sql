@@ -0,0 +1,37 @@
+-- name: QueueLinkPreview :exec
+INSERT INTO link_previews (url)
+VALUES (?)
+ON CONFLICT (url) DO NOTHING;
+
+-- name: RequeueStaleLinkPreview :exec
+-- Previews fetched before stale_before are fetched again.
+UPDATE link_previews
+SET status = 'pending', lease_until = NULL
+WHERE url = sqlc.arg('url') AND status <> 'pending' AND fetched_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('stale_before'));
+
+-- name: ClaimLinkPreview :one
+-- Writers take turns on SQLite, so a preview can't be leased twice.
+UPDATE link_previews
+SET lease_until = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('lease_until'))
+WHERE url = (
+    SELECT url FROM link_previews
+    WHERE status = 'pending' AND (lease_until IS NULL OR lease_until <= strftime('%Y-%m-%d %H:%M:%f', 'now'))
+    ORDER BY created_at, rowid
+    LIMIT 1
+)
+RETURNING url;
+
+-- name: SaveLinkPreview :exec
+UPDATE link_previews
+SET status = 'ready', lease_until = NULL, fetched_at = strftime('%Y-%m-%d %H:%M:%f', 'now'),
+    title = ?, description = ?, image_url = ?, site_name = ?
+WHERE url = ?;
+
+-- name: FailLinkPreview :exec
+UPDATE link_previews
+SET status = 'failed', lease_until = NULL, fetched_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE url = ?;
+
+-- name: GetLinkPreviews :many
+SELECT url, created_at, status, lease_until, fetched_at, title, description, image_url, site_name FROM link_previews
+WHERE status = 'ready' AND url IN (sqlc.slice('urls'));
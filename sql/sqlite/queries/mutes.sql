@@ -0,0 +1,13 @@
+-- name: MuteUser :exec
+INSERT INTO mutes (muter_id, muted_id)
+VALUES (?, ?)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :execrows
+DELETE FROM mutes
+WHERE muter_id = ? AND muted_id = ?;
+
+-- name: GetMutesByUser :many
+SELECT muter_id, muted_id, created_at FROM mutes
+WHERE muter_id = ?
+ORDER BY created_at DESC, rowid DESC;
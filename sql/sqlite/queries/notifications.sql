@@ -0,0 +1,18 @@
+-- name: GetNotificationsAfter :many
+SELECT id, created_at, user_id, type, actor_id, chirp_id FROM notifications
+WHERE id > ?
+ORDER BY id
+LIMIT ?;
+
+-- name: GetLatestNotificationID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS INTEGER) FROM notifications;
+
+-- name: GetNotificationsByUser :many
+SELECT id, created_at, user_id, type, actor_id, chirp_id FROM notifications
+WHERE user_id = ? AND id < ?
+ORDER BY id DESC
+LIMIT ?;
+
+-- name: DeleteNotificationsBefore :execrows
+DELETE FROM notifications
+WHERE created_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('created_at'));
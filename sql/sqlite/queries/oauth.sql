@@ -0,0 +1,44 @@
+-- name: CreateOAuthClient :one
+-- redirect_uris and scopes are JSON arrays.
+INSERT INTO oauth_clients (user_id, name, secret_hash, redirect_uris, scopes)
+VALUES (?, ?, ?, ?, ?)
+RETURNING *;
+
+-- name: GetOAuthClientByID :one
+SELECT * FROM oauth_clients
+WHERE id = ?;
+
+-- name: DeleteOAuthClient :execrows
+DELETE FROM oauth_clients
+WHERE id = ? AND user_id = ?;
+
+-- name: CreateAuthorizationCode :exec
+-- scopes is a JSON array.
+INSERT INTO oauth_authorization_codes (
+    code_hash,
+    client_id,
+    user_id,
+    redirect_uri,
+    scopes,
+    code_challenge,
+    expires_at
+) VALUES (
+    sqlc.arg('code_hash'),
+    sqlc.arg('client_id'),
+    sqlc.arg('user_id'),
+    sqlc.arg('redirect_uri'),
+    sqlc.arg('scopes'),
+    sqlc.arg('code_challenge'),
+    strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('expires_at'))
+);
+
+-- name: ConsumeAuthorizationCode :one
+UPDATE oauth_authorization_codes
+SET used_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE code_hash = ? AND used_at IS NULL AND expires_at > strftime('%Y-%m-%d %H:%M:%f', 'now')
+RETURNING *;
+
+-- name: GetOAuthClientsByUser :many
+SELECT * FROM oauth_clients
+WHERE user_id = ?
+ORDER BY created_at, rowid;
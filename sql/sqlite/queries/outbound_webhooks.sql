@@ -0,0 +1,82 @@
+-- name: CreateWebhookEndpoint :one
+-- events is a JSON array.
+INSERT INTO webhook_endpoints (user_id, url, secret, events)
+VALUES (?, ?, ?, ?)
+RETURNING id, created_at, updated_at, user_id, url, secret, events;
+
+-- name: GetWebhookEndpoint :one
+SELECT id, created_at, updated_at, user_id, url, secret, events FROM webhook_endpoints
+WHERE id = ? AND user_id = ?;
+
+-- name: GetWebhookEndpointsByUser :many
+SELECT id, created_at, updated_at, user_id, url, secret, events FROM webhook_endpoints
+WHERE user_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: DeleteWebhookEndpoint :execrows
+DELETE FROM webhook_endpoints
+WHERE id = ? AND user_id = ?;
+
+-- name: EnqueueOutboundWebhooks :execrows
+INSERT INTO outbound_webhooks (endpoint_id, event, payload, status, next_attempt_at)
+SELECT id, sqlc.arg('event'), sqlc.arg('payload'), 'pending', strftime('%Y-%m-%d %H:%M:%f', 'now')
+FROM webhook_endpoints
+WHERE user_id = sqlc.arg('user_id')
+    AND EXISTS (SELECT 1 FROM json_each(webhook_endpoints.events) WHERE value = sqlc.arg('event'));
+
+-- name: ClaimOutboundWebhook :one
+-- Writers take turns on SQLite, so the webhook that has been due longest
+-- can't be claimed twice.
+UPDATE outbound_webhooks
+SET attempts = attempts + 1, next_attempt_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('next_attempt_at')), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = (
+    SELECT id FROM outbound_webhooks
+    WHERE status = 'pending' AND next_attempt_at <= strftime('%Y-%m-%d %H:%M:%f', 'now')
+    ORDER BY next_attempt_at
+    LIMIT 1
+)
+RETURNING id, endpoint_id, event, payload, attempts;
+
+-- name: GetWebhookEndpointTarget :one
+-- The url and secret a claimed webhook is delivered with.
+SELECT url, secret FROM webhook_endpoints
+WHERE id = ?;
+
+-- name: CreateOutboundWebhookAttempt :exec
+INSERT INTO outbound_webhook_attempts (outbound_webhook_id, attempt, status_code, error, duration_ms)
+VALUES (?, ?, ?, ?, ?);
+
+-- name: MarkOutboundWebhookDelivered :exec
+UPDATE outbound_webhooks
+SET status = 'delivered', delivered_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), last_error = NULL, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: RetryOutboundWebhook :exec
+UPDATE outbound_webhooks
+SET next_attempt_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('next_attempt_at')), last_error = sqlc.arg('last_error'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id');
+
+-- name: DeadLetterOutboundWebhook :exec
+UPDATE outbound_webhooks
+SET status = 'dead', last_error = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: RequeueOutboundWebhook :execrows
+UPDATE outbound_webhooks
+SET status = 'pending', attempts = 0, next_attempt_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ? AND endpoint_id = ? AND status = 'dead';
+
+-- name: GetOutboundWebhook :one
+SELECT id, created_at, updated_at, endpoint_id, event, payload, status, attempts, next_attempt_at, last_error, delivered_at FROM outbound_webhooks
+WHERE id = ? AND endpoint_id = ?;
+
+-- name: GetOutboundWebhooksByEndpoint :many
+SELECT id, created_at, updated_at, endpoint_id, event, payload, status, attempts, next_attempt_at, last_error, delivered_at FROM outbound_webhooks
+WHERE endpoint_id = ?
+ORDER BY created_at DESC, rowid DESC
+LIMIT ?;
+
+-- name: GetOutboundWebhookAttempts :many
+SELECT id, created_at, outbound_webhook_id, attempt, status_code, error, duration_ms FROM outbound_webhook_attempts
+WHERE outbound_webhook_id = ?
+ORDER BY attempt;
@@ -0,0 +1,24 @@
+-- name: CreatePersonalAccessToken :one
+-- scopes is a JSON array.
+INSERT INTO personal_access_tokens (user_id, name, token_hash, scopes, expires_at)
+VALUES (sqlc.arg('user_id'), sqlc.arg('name'), sqlc.arg('token_hash'), sqlc.arg('scopes'), strftime('%Y-%m-%d %H:%M:%f', sqlc.narg('expires_at')))
+RETURNING *;
+
+-- name: GetPersonalAccessTokensByUser :many
+SELECT * FROM personal_access_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: GetPersonalAccessTokenByHash :one
+SELECT * FROM personal_access_tokens
+WHERE token_hash = ?;
+
+-- name: TouchPersonalAccessToken :exec
+UPDATE personal_access_tokens
+SET last_used_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: RevokePersonalAccessToken :execrows
+UPDATE personal_access_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ? AND user_id = ? AND revoked_at IS NULL;
@@ -0,0 +1,23 @@
+-- name: PinChirp :execrows
+-- Takes the lowest free position. Affects no rows if the chirp is already
+-- pinned or every position is taken.
+INSERT INTO pinned_chirps (user_id, position, chirp_id)
+SELECT sqlc.arg('user_id'), free.position, sqlc.arg('chirp_id')
+FROM (SELECT 0 AS position UNION ALL SELECT 1 UNION ALL SELECT 2) AS free
+WHERE free.position NOT IN (
+    SELECT position FROM pinned_chirps
+    WHERE pinned_chirps.user_id = sqlc.arg('user_id')
+)
+ORDER BY free.position
+LIMIT 1
+ON CONFLICT DO NOTHING;
+
+-- name: UnpinChirp :execrows
+DELETE FROM pinned_chirps
+WHERE user_id = ? AND chirp_id = ?;
+
+-- name: GetPinnedChirpIDs :many
+-- Most recently pinned first.
+SELECT chirp_id FROM pinned_chirps
+WHERE user_id = ?
+ORDER BY created_at DESC, position DESC;
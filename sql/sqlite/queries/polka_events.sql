@@ -0,0 +1,4 @@
+-- name: RecordPolkaEvent :execrows
+INSERT INTO polka_events (event_id, event)
+VALUES (?, ?)
+ON CONFLICT (event_id) DO NOTHING;
@@ -0,0 +1,34 @@
+-- name: CreatePoll :exec
+INSERT INTO polls (chirp_id, closes_at)
+VALUES (sqlc.arg('chirp_id'), strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('closes_at')));
+
+-- name: CreatePollOption :exec
+INSERT INTO poll_options (chirp_id, position, text)
+VALUES (?, ?, ?);
+
+-- name: GetPoll :one
+SELECT chirp_id, created_at, closes_at FROM polls
+WHERE chirp_id = ?;
+
+-- name: GetPollOptions :many
+-- Options of the polls on chirp_ids, with how many votes each has.
+SELECT poll_options.chirp_id, poll_options.position, poll_options.text, polls.closes_at,
+    (
+        SELECT COUNT(*) FROM poll_votes
+        WHERE poll_votes.chirp_id = poll_options.chirp_id
+            AND poll_votes.position = poll_options.position
+    ) AS votes
+FROM poll_options
+JOIN polls ON polls.chirp_id = poll_options.chirp_id
+WHERE poll_options.chirp_id IN (sqlc.slice('chirp_ids'))
+ORDER BY poll_options.chirp_id, poll_options.position;
+
+-- name: GetPollVotesByUser :many
+SELECT chirp_id, user_id, position, created_at FROM poll_votes
+WHERE user_id = sqlc.arg('user_id') AND chirp_id IN (sqlc.slice('chirp_ids'));
+
+-- name: CreatePollVote :execrows
+-- Affects no rows if the user has already voted.
+INSERT INTO poll_votes (chirp_id, user_id, position)
+VALUES (?, ?, ?)
+ON CONFLICT (chirp_id, user_id) DO NOTHING;
@@ -0,0 +1,40 @@
+-- name: CreateRefreshToken :one
+INSERT INTO refresh_tokens (token, user_id, expires_at)
+VALUES (sqlc.arg('token'), sqlc.arg('user_id'), strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('expires_at')))
+RETURNING *;
+
+-- name: GetRefreshTokenById :one
+SELECT * FROM refresh_tokens
+WHERE token = ?;
+
+-- name: GetUsernameByRefreshToken :one
+SELECT user_id FROM refresh_tokens
+WHERE token = ?;
+
+-- name: RevokeRefreshToken :exec
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE token = ?;
+
+-- name: RotateRefreshToken :execrows
+-- Revokes a refresh token that is being exchanged for a new one. A token
+-- that is already revoked is left alone, so only one exchange succeeds.
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE token = ? AND revoked_at IS NULL;
+
+-- name: CreateOAuthRefreshToken :one
+-- scopes is a JSON array.
+INSERT INTO refresh_tokens (token, user_id, expires_at, client_id, scopes)
+VALUES (sqlc.arg('token'), sqlc.arg('user_id'), strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('expires_at')), sqlc.arg('client_id'), sqlc.arg('scopes'))
+RETURNING *;
+
+-- name: RevokeClientRefreshToken :exec
+UPDATE refresh_tokens
+SET revoked_at = strftime('%Y-%m-%d %H:%M:%f', 'now'), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE token = ? AND client_id = ?;
+
+-- name: GetRefreshTokensByUser :many
+SELECT * FROM refresh_tokens
+WHERE user_id = ?
+ORDER BY created_at DESC, rowid DESC;
@@ -0,0 +1,29 @@
+-- name: GetSubscriptionByUser :one
+SELECT * FROM subscriptions
+WHERE user_id = ?;
+
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (user_id, status, current_period_end)
+VALUES (sqlc.arg('user_id'), sqlc.arg('status'), strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('current_period_end')))
+ON CONFLICT (user_id) DO UPDATE
+SET status = excluded.status,
+    current_period_end = excluded.current_period_end,
+    updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+RETURNING *;
+
+-- name: CreateSubscriptionEvent :exec
+INSERT INTO subscription_events (user_id, event, status, period_end)
+VALUES (sqlc.arg('user_id'), sqlc.arg('event'), sqlc.arg('status'), strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('period_end')));
+
+-- name: GetSubscriptionEventsByUser :many
+SELECT * FROM subscription_events
+WHERE user_id = ?
+ORDER BY created_at DESC, rowid DESC;
+
+-- name: ExpireLapsedSubscriptions :many
+-- SQLite can't write to other tables in the same statement, so recording
+-- the expiry and taking Chirpy Red away is left to the caller.
+UPDATE subscriptions
+SET status = 'expired', updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE status IN ('active', 'past_due', 'canceled') AND current_period_end <= strftime('%Y-%m-%d %H:%M:%f', 'now')
+RETURNING user_id, current_period_end;
@@ -0,0 +1,64 @@
+-- name: CreateUser :one
+INSERT INTO users (email, hashed_password)
+VALUES (?, ?)
+RETURNING *;
+
+-- name: DeleteUsers :execresult
+DELETE FROM users;
+
+-- name: GetUserByEmail :one
+SELECT * FROM users
+WHERE email = ?;
+
+-- name: GetUserByID :one
+SELECT * FROM users
+WHERE id = ?;
+
+-- name: UpdateUsernamePassword :one
+UPDATE users
+SET email = ?, hashed_password = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?
+RETURNING id, created_at, updated_at, email;
+
+-- name: UpgradeToChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = TRUE, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: ScheduleUserDeletion :one
+UPDATE users
+SET deletion_scheduled_at = strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('deletion_scheduled_at')), updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = sqlc.arg('id')
+RETURNING *;
+
+-- name: CancelUserDeletion :execrows
+UPDATE users
+SET deletion_scheduled_at = NULL, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ? AND deletion_scheduled_at IS NOT NULL;
+
+-- name: DeleteUsersPastGracePeriod :execrows
+DELETE FROM users
+WHERE deletion_scheduled_at <= strftime('%Y-%m-%d %H:%M:%f', 'now');
+
+-- name: SetChirpyRed :exec
+UPDATE users
+SET is_chirpy_red = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: SetDMPolicy :exec
+UPDATE users
+SET dm_policy = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: SetProtected :exec
+UPDATE users
+SET protected = ?, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?;
+
+-- name: CountMentionableUsers :one
+-- Counts the users in ids that exist and aren't on either side of a block
+-- with user_id.
+SELECT COUNT(*) FROM users
+WHERE id NOT IN (SELECT blocks.blocked_id FROM blocks WHERE blocks.blocker_id = sqlc.arg('user_id'))
+    AND id NOT IN (SELECT blocks.blocker_id FROM blocks WHERE blocks.blocked_id = sqlc.arg('user_id'))
+    AND id IN (sqlc.slice('ids'));
@@ -0,0 +1,26 @@
+-- name: CreateWebhookDelivery :one
+INSERT INTO webhook_deliveries (source, headers, body, status)
+VALUES (?, ?, ?, 'received')
+RETURNING *;
+
+-- name: UpdateWebhookDeliveryOutcome :one
+UPDATE webhook_deliveries
+SET status = ?, event_id = ?, event = ?, error = ?, processed_at = strftime('%Y-%m-%d %H:%M:%f', 'now')
+WHERE id = ?
+RETURNING *;
+
+-- name: IncrementWebhookReplayCount :exec
+UPDATE webhook_deliveries
+SET replay_count = replay_count + 1
+WHERE id = ?;
+
+-- name: GetWebhookDelivery :one
+SELECT * FROM webhook_deliveries
+WHERE id = ?;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE (sqlc.narg('status') IS NULL OR status = sqlc.narg('status'))
+    AND received_at < strftime('%Y-%m-%d %H:%M:%f', sqlc.arg('before'))
+ORDER BY received_at DESC, rowid DESC
+LIMIT sqlc.arg('limit');
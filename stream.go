@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/caleb-fringer/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+// The store notifies these channels when chirp events and notifications are
+// logged.
+const (
+	CHIRP_EVENTS_CHANNEL  = "chirp_events"
+	NOTIFICATIONS_CHANNEL = "notifications"
+)
+
+const (
+	// Events are re-read from the log this often in case a notification was
+	// missed.
+	CHIRP_EVENT_POLL = 30 * time.Second
+	// Ids are allocated before commit, so an event can become visible after
+	// one with a higher id. Each read looks back this many ids to catch them.
+	CHIRP_EVENT_LOOKBACK = 100
+	CHIRP_EVENT_BATCH    = 500
+	// Streams can resume from events up to this old.
+	CHIRP_EVENT_RETENTION        = 24 * time.Hour
+	CHIRP_EVENT_CLEANUP_INTERVAL = time.Hour
+	STREAM_BUFFER                = 64
+	STREAM_HEARTBEAT             = 15 * time.Second
+	STREAM_RETRY_MILLISECONDS    = 3000
+	MAX_STREAM_REPLAY_EVENTS     = 5000
+)
+
+// chirpFilter selects the chirps a stream is interested in. A nil authors set
+// matches every author who isn't hidden.
+type chirpFilter struct {
+	authors map[uuid.UUID]struct{}
+	// Authors hidden from the viewer by blocks and mutes.
+	hidden map[uuid.UUID]struct{}
+	// The viewer is not valid for anonymous streams, which only see public
+	// chirps.
+	viewer    uuid.NullUUID
+	following map[uuid.UUID]struct{}
+}
+
+func (f chirpFilter) match(e stream.Event) bool {
+	if _, ok := f.hidden[e.AuthorID]; ok {
+		return false
+	}
+	if !chirpEventVisible(e, f.viewer, f.following) {
+		return false
+	}
+	if f.authors == nil {
+		return true
+	}
+	_, ok := f.authors[e.AuthorID]
+	return ok
+}
+
+// chirpStreamEvent turns a logged chirp event into a stream event. It returns
+// false for chirps that were deleted or unpublished before the event was
+// read, since there is nothing left to show.
+func chirpStreamEvent(ctx context.Context, q database.Querier, e database.ChirpEvent) (stream.Event, bool, error) {
+	var data any
+	if e.Event == CHIRP_DELETED_EVENT {
+		data = map[string]uuid.UUID{"id": e.ChirpID, "user_id": e.UserID}
+	} else {
+		chirp, err := q.GetChirpById(ctx, e.ChirpID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return stream.Event{}, false, nil
+		}
+		if err != nil {
+			return stream.Event{}, false, err
+		}
+		if chirp.Status != CHIRP_PUBLISHED {
+			return stream.Event{}, false, nil
+		}
+		data = newChirpResponse(chirp)
+	}
+
+	rawData, err := json.Marshal(data)
+	if err != nil {
+		return stream.Event{}, false, err
+	}
+	return stream.Event{
+		ID:         e.ID,
+		Type:       e.Event,
+		Data:       rawData,
+		AuthorID:   e.UserID,
+		ThreadID:   e.ThreadID,
+		Tags:       e.Tags,
+		Visibility: e.Visibility,
+		Mentions:   e.Mentions,
+	}, true, nil
+}
+
+// loggedEvent is a row read from an event log. Building its stream event
+// may need more queries, so it is only done for events not yet published.
+type loggedEvent struct {
+	id    int64
+	build func(ctx context.Context) (stream.Event, bool, error)
+}
+
+// eventFeed publishes an event log to a broker, in this process.
+type eventFeed struct {
+	name   string
+	broker *stream.Broker
+	// fetch returns up to CHIRP_EVENT_BATCH events logged after id, in
+	// order.
+	fetch  func(ctx context.Context, after int64) ([]loggedEvent, error)
+	cursor int64
+	seen   map[int64]struct{}
+}
+
+// read publishes events that haven't been seen yet. With publish false it
+// only marks them seen.
+func (f *eventFeed) read(ctx context.Context, publish bool) error {
+	after := max(f.cursor-CHIRP_EVENT_LOOKBACK, 0)
+	for {
+		events, err := f.fetch(ctx, after)
+		if err != nil {
+			return fmt.Errorf("Error reading %s: %v", f.name, err)
+		}
+
+		for _, e := range events {
+			after = e.id
+			if _, ok := f.seen[e.id]; ok {
+				continue
+			}
+			f.seen[e.id] = struct{}{}
+			f.cursor = max(f.cursor, e.id)
+
+			if !publish {
+				continue
+			}
+			se, ok, err := e.build(ctx)
+			if err != nil {
+				return fmt.Errorf("Error building %s %d: %v", f.name, e.id, err)
+			}
+			if ok {
+				f.broker.Publish(se)
+			}
+		}
+
+		if len(events) < CHIRP_EVENT_BATCH {
+			break
+		}
+	}
+
+	for id := range f.seen {
+		if id <= f.cursor-CHIRP_EVENT_LOOKBACK {
+			delete(f.seen, id)
+		}
+	}
+	return nil
+}
+
+// start marks everything already in the log as seen. Events from before
+// startup are only replayed to clients that ask.
+func (f *eventFeed) start(ctx context.Context, latest func(context.Context) (int64, error)) {
+	var err error
+	f.cursor, err = latest(ctx)
+	if err != nil {
+		log.Printf("Error reading latest %s: %v\n", f.name, err)
+	}
+	err = f.read(ctx, false)
+	if err != nil {
+		log.Printf("%v\n", err)
+	}
+}
+
+func (cfg *apiConfig) chirpEventFeed() *eventFeed {
+	return &eventFeed{
+		name:   "chirp events",
+		broker: cfg.chirpStream,
+		seen:   map[int64]struct{}{},
+		fetch: func(ctx context.Context, after int64) ([]loggedEvent, error) {
+			events, err := cfg.store.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{
+				ID:    after,
+				Limit: CHIRP_EVENT_BATCH,
+			})
+			if err != nil {
+				return nil, err
+			}
+			logged := make([]loggedEvent, 0, len(events))
+			for _, e := range events {
+				logged = append(logged, loggedEvent{
+					id: e.ID,
+					build: func(ctx context.Context) (stream.Event, bool, error) {
+						return chirpStreamEvent(ctx, cfg.store, e)
+					},
+				})
+			}
+			return logged, nil
+		},
+	}
+}
+
+func (cfg *apiConfig) notificationFeed() *eventFeed {
+	return &eventFeed{
+		name:   "notifications",
+		broker: cfg.notificationStream,
+		seen:   map[int64]struct{}{},
+		fetch: func(ctx context.Context, after int64) ([]loggedEvent, error) {
+			notifications, err := cfg.store.GetNotificationsAfter(ctx, database.GetNotificationsAfterParams{
+				ID:    after,
+				Limit: CHIRP_EVENT_BATCH,
+			})
+			if err != nil {
+				return nil, err
+			}
+			logged := make([]loggedEvent, 0, len(notifications))
+			for _, n := range notifications {
+				logged = append(logged, loggedEvent{
+					id: n.ID,
+					build: func(ctx context.Context) (stream.Event, bool, error) {
+						return notificationStreamEvent(n)
+					},
+				})
+			}
+			return logged, nil
+		},
+	}
+}
+
+// listenEventLogs feeds cfg.chirpStream and cfg.notificationStream from
+// store notifications until ctx is done. Every replica runs one, so live
+// clients see events caused through any of them.
+func (cfg *apiConfig) listenEventLogs(ctx context.Context) {
+	notified, err := cfg.store.Listen(ctx, CHIRP_EVENTS_CHANNEL, NOTIFICATIONS_CHANNEL)
+	if err != nil {
+		log.Printf("Error listening for event logs: %v\n", err)
+		return
+	}
+
+	feeds := map[string]*eventFeed{
+		CHIRP_EVENTS_CHANNEL:  cfg.chirpEventFeed(),
+		NOTIFICATIONS_CHANNEL: cfg.notificationFeed(),
+	}
+
+	feeds[CHIRP_EVENTS_CHANNEL].start(ctx, cfg.store.GetLatestChirpEventID)
+	feeds[NOTIFICATIONS_CHANNEL].start(ctx, cfg.store.GetLatestNotificationID)
+
+	ticker := time.NewTicker(CHIRP_EVENT_POLL)
+	defer ticker.Stop()
+	for {
+		// An empty channel means notifications may have been missed, so
+		// every feed catches up.
+		stale := feeds
+		select {
+		case channel, ok := <-notified:
+			if !ok {
+				return
+			}
+			if channel != "" {
+				stale = map[string]*eventFeed{channel: feeds[channel]}
+			}
+		case <-ticker.C:
+		}
+
+		for _, feed := range stale {
+			err := feed.read(ctx, true)
+			if err != nil {
+				log.Printf("%v\n", err)
+			}
+		}
+	}
+}
+
+// deleteOldChirpEvents trims the chirp event log.
+func (cfg *apiConfig) deleteOldChirpEvents(ctx context.Context) error {
+	_, err := cfg.store.DeleteChirpEventsBefore(ctx, time.Now().UTC().Add(-CHIRP_EVENT_RETENTION))
+	if err != nil {
+		return fmt.Errorf("Error deleting old chirp events: %v", err)
+	}
+	return nil
+}
+
+// timelineAuthors returns the users whose chirps appear on userID's
+// timeline: everyone they follow, and themselves.
+func (cfg *apiConfig) timelineAuthors(ctx context.Context, userID uuid.UUID) (map[uuid.UUID]struct{}, error) {
+	followees, err := cfg.store.GetFolloweeIDs(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	authors := map[uuid.UUID]struct{}{userID: {}}
+	for _, id := range followees {
+		authors[id] = struct{}{}
+	}
+	return authors, nil
+}
+
+func writeStreamEvent(w http.ResponseWriter, e stream.Event) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	return err
+}
+
+// streamChirps pushes chirps as they are published and deleted, as
+// Server-Sent Events. Clients that reconnect with Last-Event-ID are sent what
+// they missed first.
+func (cfg *apiConfig) streamChirps(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := chirpFilter{}
+
+	authorID, err := request.QueryUUID(r, "author_id")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+	timeline := query.Get("timeline") == "true"
+	if authorID.Valid && timeline {
+		apierror.Write(w, apierror.BadRequest("Please filter by either author_id or timeline."))
+		return
+	}
+
+	if authorID.Valid {
+		filter.authors = map[uuid.UUID]struct{}{authorID.UUID: {}}
+	}
+
+	// Anonymous clients can stream everything but their timeline.
+	viewer := chirpViewer(r)
+	if timeline && !viewer.Valid {
+		writeAuthError(w, apierror.Unauthorized("Sign in to stream your timeline."), "")
+		return
+	}
+
+	// Follows, blocks and mutes are read once, so changes made while
+	// connected apply after the client reconnects.
+	filter.viewer = viewer
+	filter.hidden, err = cfg.hiddenAuthors(r.Context(), viewer)
+	if err == nil && viewer.Valid {
+		filter.following, err = cfg.timelineAuthors(r.Context(), viewer.UUID)
+	}
+	if timeline {
+		filter.authors = filter.following
+	}
+	if err != nil {
+		log.Printf("GET /api/stream: Error retrieving timeline: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	var lastEventID int64 = -1
+	if rawID := r.Header.Get("Last-Event-ID"); rawID != "" {
+		id, err := strconv.ParseInt(rawID, 10, 64)
+		if err != nil || id < 0 {
+			apierror.Write(w, apierror.BadRequest("Invalid Last-Event-ID"))
+			return
+		}
+		lastEventID = id
+	}
+
+	// Subscribe before replaying so nothing is missed in between.
+	sub := cfg.chirpStream.Subscribe(STREAM_BUFFER)
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", STREAM_RETRY_MILLISECONDS)
+
+	replayed := map[int64]struct{}{}
+	if lastEventID >= 0 {
+		err := cfg.replayChirpEvents(r.Context(), w, lastEventID, filter, replayed)
+		if err != nil {
+			log.Printf("GET /api/stream: Error replaying events: %v\n", err)
+			return
+		}
+	}
+
+	err = rc.Flush()
+	if err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(STREAM_HEARTBEAT)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-sub.Events():
+			// Closed when the client falls too far behind or the server is
+			// shutting down. It reconnects and resumes with Last-Event-ID.
+			if !ok {
+				return
+			}
+			if _, ok := replayed[e.ID]; ok || !filter.match(e) {
+				continue
+			}
+			err = writeStreamEvent(w, e)
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(w, ": keepalive\n\n")
+		}
+
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// replayChirpEvents writes events after lastEventID that match filter,
+// recording their ids in replayed.
+func (cfg *apiConfig) replayChirpEvents(ctx context.Context, w http.ResponseWriter, lastEventID int64, filter chirpFilter, replayed map[int64]struct{}) error {
+	after := lastEventID
+	for len(replayed) < MAX_STREAM_REPLAY_EVENTS {
+		events, err := cfg.store.GetChirpEventsAfter(ctx, database.GetChirpEventsAfterParams{
+			ID:    after,
+			Limit: CHIRP_EVENT_BATCH,
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, e := range events {
+			after = e.ID
+			replayed[e.ID] = struct{}{}
+			if !filter.match(stream.Event{AuthorID: e.UserID, Visibility: e.Visibility, Mentions: e.Mentions}) {
+				continue
+			}
+			se, ok, err := chirpStreamEvent(ctx, cfg.store, e)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+			err = writeStreamEvent(w, se)
+			if err != nil {
+				return err
+			}
+		}
+
+		if len(events) < CHIRP_EVENT_BATCH {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/entitlements"
+	"github.com/google/uuid"
+)
+
+// Polka subscription events.
+const (
+	RENEWAL_EVENT        = "user.renewed"
+	PAYMENT_FAILED_EVENT = "user.payment_failed"
+	CANCEL_EVENT         = "user.cancelled"
+	DOWNGRADE_EVENT      = "user.downgraded"
+	REFUND_EVENT         = "user.refunded"
+)
+
+// Subscription statuses.
+const (
+	SUBSCRIPTION_ACTIVE   = "active"
+	SUBSCRIPTION_PAST_DUE = "past_due"
+	SUBSCRIPTION_CANCELED = "canceled"
+	SUBSCRIPTION_EXPIRED  = "expired"
+	SUBSCRIPTION_REFUNDED = "refunded"
+)
+
+// Used when Polka doesn't tell us when the paid period ends.
+const SUBSCRIPTION_PERIOD = 30 * 24 * time.Hour
+
+const SUBSCRIPTION_EXPIRY_INTERVAL = 10 * time.Minute
+
+type subscriptionResponse struct {
+	Status           string                       `json:"status"`
+	IsChirpyRed      bool                         `json:"is_chirpy_red"`
+	CurrentPeriodEnd *time.Time                   `json:"current_period_end"`
+	Plan             string                       `json:"plan"`
+	Entitlements     []entitlements.Entitlement   `json:"entitlements"`
+	MaxChirpLength   int                          `json:"max_chirp_length"`
+	ChirpsPerHour    int                          `json:"chirps_per_hour"`
+	AnalyticsDays    int                          `json:"analytics_days"`
+	History          []database.SubscriptionEvent `json:"history"`
+}
+
+// isSubscriptionEvent reports whether event changes a Chirpy Red
+// subscription.
+func isSubscriptionEvent(event string) bool {
+	switch event {
+	case UPGRADE_EVENT, RENEWAL_EVENT, PAYMENT_FAILED_EVENT, CANCEL_EVENT, DOWNGRADE_EVENT, REFUND_EVENT:
+		return true
+	}
+	return false
+}
+
+// applySubscriptionEvent moves a user's subscription to the state implied by
+// event, records it in the subscription history and keeps is_chirpy_red in
+// sync. Users keep Chirpy Red through the end of a period they paid for
+// unless they were refunded or downgraded.
+func applySubscriptionEvent(ctx context.Context, q database.Querier, userID uuid.UUID, event string, periodEnd *time.Time) error {
+	now := time.Now().UTC()
+	current, err := q.GetSubscriptionByUser(ctx, userID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	hasSubscription := err == nil
+
+	end := current.CurrentPeriodEnd
+	if periodEnd != nil {
+		end = periodEnd.UTC()
+	}
+
+	var status string
+	switch event {
+	case UPGRADE_EVENT:
+		status = SUBSCRIPTION_ACTIVE
+		if periodEnd == nil {
+			end = now.Add(SUBSCRIPTION_PERIOD)
+		}
+	case RENEWAL_EVENT:
+		status = SUBSCRIPTION_ACTIVE
+		if periodEnd == nil {
+			end = now
+			if hasSubscription && current.CurrentPeriodEnd.After(now) {
+				end = current.CurrentPeriodEnd
+			}
+			end = end.Add(SUBSCRIPTION_PERIOD)
+		}
+	case PAYMENT_FAILED_EVENT:
+		status = SUBSCRIPTION_PAST_DUE
+	case CANCEL_EVENT:
+		status = SUBSCRIPTION_CANCELED
+	case DOWNGRADE_EVENT:
+		status = SUBSCRIPTION_EXPIRED
+		end = now
+	case REFUND_EVENT:
+		status = SUBSCRIPTION_REFUNDED
+		end = now
+	default:
+		return fmt.Errorf("Unknown subscription event %q", event)
+	}
+
+	// A payment failure or cancellation for a user we have no subscription
+	// for leaves them without Chirpy Red.
+	if !hasSubscription && periodEnd == nil && status != SUBSCRIPTION_ACTIVE {
+		end = now
+	}
+
+	_, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+		UserID:           userID,
+		Status:           status,
+		CurrentPeriodEnd: end,
+	})
+	if err != nil {
+		return fmt.Errorf("Error updating subscription: %v", err)
+	}
+
+	err = q.CreateSubscriptionEvent(ctx, database.CreateSubscriptionEventParams{
+		UserID:    userID,
+		Event:     event,
+		Status:    status,
+		PeriodEnd: end,
+	})
+	if err != nil {
+		return fmt.Errorf("Error recording subscription history: %v", err)
+	}
+
+	isChirpyRed := status != SUBSCRIPTION_EXPIRED && status != SUBSCRIPTION_REFUNDED && end.After(now)
+	return q.SetChirpyRed(ctx, database.SetChirpyRedParams{
+		ID:          userID,
+		IsChirpyRed: isChirpyRed,
+	})
+}
+
+// expireSubscriptions ends Chirpy Red for subscriptions whose paid period is
+// over.
+func (cfg *apiConfig) expireSubscriptions(ctx context.Context) error {
+	rows, err := cfg.store.ExpireLapsedSubscriptions(ctx)
+	if err != nil {
+		return fmt.Errorf("Error expiring subscriptions: %v", err)
+	}
+	if rows > 0 {
+		log.Printf("Expired %d lapsed Chirpy Red subscriptions\n", rows)
+	}
+	return nil
+}
+
+func (cfg *apiConfig) getSubscription(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	user, err := cfg.store.GetUserByID(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/users/subscription: Error retrieving user %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	plan := entitlements.ForUser(user.IsChirpyRed)
+	res := subscriptionResponse{
+		Status:         "none",
+		IsChirpyRed:    user.IsChirpyRed,
+		Plan:           plan.Name,
+		Entitlements:   plan.Entitlements,
+		MaxChirpLength: plan.MaxChirpLength,
+		ChirpsPerHour:  plan.ChirpsPerHour,
+		AnalyticsDays:  plan.AnalyticsDays,
+		History:        []database.SubscriptionEvent{},
+	}
+	if res.Entitlements == nil {
+		res.Entitlements = []entitlements.Entitlement{}
+	}
+
+	sub, err := cfg.store.GetSubscriptionByUser(r.Context(), userID)
+	if err == nil {
+		res.Status = sub.Status
+		res.CurrentPeriodEnd = &sub.CurrentPeriodEnd
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("GET /api/users/subscription: Error retrieving subscription for %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	history, err := cfg.store.GetSubscriptionEventsByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/users/subscription: Error retrieving history for %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+	if history != nil {
+		res.History = history
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/users/subscription: Error writing response: %v\n", err)
+	}
+}
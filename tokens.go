@@ -0,0 +1,168 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+type createTokenReqParams struct {
+	Name             string   `json:"name"`
+	Scopes           []string `json:"scopes"`
+	ExpiresInSeconds int      `json:"expires_in_seconds,omitempty"`
+}
+
+type personalAccessTokenResponse struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Name       string     `json:"name"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func newPersonalAccessTokenResponse(pat database.PersonalAccessToken) personalAccessTokenResponse {
+	return personalAccessTokenResponse{
+		ID:         pat.ID,
+		CreatedAt:  pat.CreatedAt,
+		Name:       pat.Name,
+		Scopes:     pat.Scopes,
+		ExpiresAt:  nullTimePtr(pat.ExpiresAt),
+		LastUsedAt: nullTimePtr(pat.LastUsedAt),
+		RevokedAt:  nullTimePtr(pat.RevokedAt),
+	}
+}
+
+func (cfg *apiConfig) createPersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	reqBody := &createTokenReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	if reqBody.Name == "" {
+		apierror.Write(w, apierror.BadRequest("Please provide a name for the token."))
+		return
+	}
+
+	if len(reqBody.Scopes) == 0 {
+		apierror.Write(w, apierror.BadRequest("Please request at least one scope."))
+		return
+	}
+	for _, scope := range reqBody.Scopes {
+		if _, ok := auth.Scopes[scope]; !ok {
+			apierror.Write(w, apierror.BadRequest(fmt.Sprintf("Unknown scope %q", scope)))
+			return
+		}
+	}
+
+	token, hash, err := auth.MakePersonalAccessToken()
+	if err != nil {
+		log.Printf("POST /api/tokens: Error creating personal access token: %v\n", err)
+		apierror.Write(w, apierror.Internal("Server error"))
+		return
+	}
+
+	params := database.CreatePersonalAccessTokenParams{
+		UserID:    userID,
+		Name:      reqBody.Name,
+		TokenHash: hash,
+		Scopes:    reqBody.Scopes,
+	}
+	if reqBody.ExpiresInSeconds > 0 {
+		params.ExpiresAt = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(reqBody.ExpiresInSeconds) * time.Second),
+			Valid: true,
+		}
+	}
+
+	pat, err := cfg.store.CreatePersonalAccessToken(r.Context(), params)
+	if err != nil {
+		log.Printf("POST /api/tokens: Error storing personal access token: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	// The plaintext token is only ever returned here.
+	res := newPersonalAccessTokenResponse(pat)
+	res.Token = token
+
+	w.WriteHeader(http.StatusCreated)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("POST /api/tokens: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) getPersonalAccessTokens(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	pats, err := cfg.store.GetPersonalAccessTokensByUser(r.Context(), userID)
+	if err != nil {
+		log.Printf("GET /api/tokens: Error retrieving personal access tokens: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := make([]personalAccessTokenResponse, 0, len(pats))
+	for _, pat := range pats {
+		res = append(res, newPersonalAccessTokenResponse(pat))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /api/tokens: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) revokePersonalAccessToken(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	userID := auth.PrincipalFrom(r.Context()).UserID
+
+	tokenID, err := request.PathUUID(r, "tokenID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	rows, err := cfg.store.RevokePersonalAccessToken(r.Context(), database.RevokePersonalAccessTokenParams{
+		ID:     tokenID,
+		UserID: userID,
+	})
+	if err != nil {
+		log.Printf("DELETE /api/tokens/%s: Error revoking personal access token: %v\n", tokenID, err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	if rows == 0 {
+		apierror.Write(w, apierror.NotFound("Token not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -2,13 +2,16 @@ package main
 
 import (
 	"encoding/json"
-	"io"
+	"errors"
 	"log"
 	"net/http"
 	"time"
 
+	"github.com/caleb-fringer/chirpy/internal/apierror"
 	"github.com/caleb-fringer/chirpy/internal/auth"
 	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/caleb-fringer/chirpy/internal/store"
 	"github.com/google/uuid"
 )
 
@@ -29,45 +32,43 @@ func validPassword(password string) bool {
 	return len(password) > 0
 }
 
+func (p *createUserReqParams) Validate() request.FieldErrors {
+	errs := request.FieldErrors{}
+	if p.Email == "" {
+		errs.Add("email", "is required")
+	}
+	if !validPassword(p.Password) {
+		errs.Add("password", "is required")
+	}
+	return errs
+}
+
 func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 	params := &createUserReqParams{}
-	rawReqBody, err := io.ReadAll(r.Body)
+	err := request.DecodeJSON(w, r, params)
 	if err != nil {
-		log.Printf("POST /api/users: Error decoding requested user data: %v\n", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write(json.RawMessage(`{"error": "Bad email/password"}`))
-		return
-	}
-
-	json.Unmarshal(rawReqBody, params)
-
-	if !validPassword(params.Password) {
-		log.Printf("POST /api/users: Error hashing password: %v\n", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write(json.RawMessage(`{"error": "Invalid password. Minimum password length is 8 characters."}`))
+		apierror.Write(w, err)
 		return
 	}
 
 	hash, err := auth.HashPassword(params.Password)
 	if err != nil {
 		log.Printf("POST /api/users: Error hashing password: %v\n", err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write(json.RawMessage(`{"error": "Bad email/password"}`))
+		apierror.Write(w, apierror.Internal("Server error"))
 		return
 	}
 
 	userParams := database.CreateUserParams{Email: params.Email, HashedPassword: hash}
 
-	user, err := cfg.queries.CreateUser(r.Context(), userParams)
+	user, err := cfg.store.CreateUser(r.Context(), userParams)
 
+	if errors.Is(err, store.ErrDuplicateEmail) {
+		apierror.Write(w, apierror.Conflict("A user with that email already exists."))
+		return
+	}
 	if err != nil {
 		log.Printf("POST /api/users: Error creating user %s in database: %v\n", params.Email, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write(json.RawMessage(`{"error": "Database error"}`))
+		apierror.Write(w, apierror.Internal("Database error"))
 		return
 	}
 
@@ -82,83 +83,56 @@ func (cfg *apiConfig) createUser(w http.ResponseWriter, r *http.Request) {
 	rawResBody, err := json.Marshal(res)
 	if err != nil {
 		log.Printf("POST /api/users: Error encoding user %s to binary for response: %v\n", params.Email, err)
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(500)
-		w.Write(json.RawMessage(`{"error": "Encoding error"}`))
+		apierror.Write(w, apierror.Internal("Encoding error"))
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(201)
+	w.WriteHeader(http.StatusCreated)
 	w.Write(rawResBody)
 	return
 }
 
 func (cfg *apiConfig) updateUser(w http.ResponseWriter, r *http.Request) {
-	token, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		log.Printf("PUT /api/users: Error reading request headers: %v\n", err)
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`{"error": "Missing/malformed access token."}`))
-		return
-	}
+	userID := auth.PrincipalFrom(r.Context()).UserID
 
-	uuid, err := auth.ValidateJWT(token, cfg.secretKey)
+	reqBody := &createUserReqParams{}
+	err := request.DecodeJSON(w, r, reqBody)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`{"error": "Missing/malformed access token."}`))
-		return
-	}
-
-	rawReqBody, err := io.ReadAll(r.Body)
-	if err != nil {
-		log.Printf("PUT /api/users: Error reading request body: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Server error"}`))
-		return
-	}
-
-	reqBody := &struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
-	}{}
-
-	err = json.Unmarshal(rawReqBody, reqBody)
-	if err != nil {
-		log.Printf("PUT /api/users: Error unmarshalling request body: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Server error"}`))
+		apierror.Write(w, err)
 		return
 	}
 
 	hashedPassword, err := auth.HashPassword(reqBody.Password)
 	if err != nil {
 		log.Printf("PUT /api/users: Error hashing password: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Server error"}`))
+		apierror.Write(w, apierror.Internal("Server error"))
 		return
 	}
 
 	updateUserParams := database.UpdateUsernamePasswordParams{
-		ID:             uuid,
+		ID:             userID,
 		Email:          reqBody.Email,
 		HashedPassword: hashedPassword,
 	}
-	user, err := cfg.queries.UpdateUsernamePassword(r.Context(), updateUserParams)
+	user, err := cfg.store.UpdateUsernamePassword(r.Context(), updateUserParams)
+	if errors.Is(err, store.ErrDuplicateEmail) {
+		apierror.Write(w, apierror.Conflict("A user with that email already exists."))
+		return
+	}
 	if err != nil {
-		log.Printf("PUT /api/users: Error updating user %d: %v\n", uuid, err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Server error"}`))
+		log.Printf("PUT /api/users: Error updating user %s: %v\n", userID, err)
+		apierror.Write(w, apierror.Internal("Server error"))
 		return
 	}
 
 	rawRes, err := json.Marshal(&user)
 	if err != nil {
 		log.Printf("PUT /api/users: Error marshalling response: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Server error"}`))
+		apierror.Write(w, apierror.Internal("Server error"))
 		return
 	}
 
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
 	w.Write(rawRes)
 	return
@@ -11,8 +11,8 @@ var profaneWords = map[string]struct{}{
 	"farking":   {},
 }
 
-func validateChirp(chirp string) (string, bool) {
-	if len(chirp) > 140 {
+func validateChirp(chirp string, maxLength int) (string, bool) {
+	if len(chirp) > maxLength {
 		return "", false
 	}
 
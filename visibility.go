@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/stream"
+	"github.com/google/uuid"
+)
+
+// Who a chirp is shown to. The author and the users a chirp mentions can
+// always see it, and chirps by protected users are only ever shown to their
+// followers.
+const (
+	VISIBILITY_PUBLIC    = "public"
+	VISIBILITY_FOLLOWERS = "followers"
+	VISIBILITY_MENTIONED = "mentioned"
+)
+
+const MAX_CHIRP_MENTIONS = 50
+
+// checkMentions removes duplicates from the users a chirp mentions. It
+// returns a message for the client if they can't be mentioned by authorID.
+func (cfg *apiConfig) checkMentions(ctx context.Context, authorID uuid.UUID, mentions []uuid.UUID) ([]uuid.UUID, string, error) {
+	unique := []uuid.UUID{}
+	for _, id := range mentions {
+		if id == authorID {
+			return nil, "You can't mention yourself.", nil
+		}
+		if !slices.Contains(unique, id) {
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) > MAX_CHIRP_MENTIONS {
+		return nil, fmt.Sprintf("A chirp can mention at most %d users.", MAX_CHIRP_MENTIONS), nil
+	}
+	if len(unique) == 0 {
+		return unique, "", nil
+	}
+
+	// Users on either side of a block look as if they don't exist.
+	n, err := cfg.store.CountMentionableUsers(ctx, database.CountMentionableUsersParams{
+		Ids:    unique,
+		UserID: authorID,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	if n != int64(len(unique)) {
+		return nil, "mentions must be ids of existing users.", nil
+	}
+	return unique, "", nil
+}
+
+// chirpEventVisible reports whether viewer may see the chirp e is about.
+// following holds the users whose followers-only chirps they can see.
+func chirpEventVisible(e stream.Event, viewer uuid.NullUUID, following map[uuid.UUID]struct{}) bool {
+	if e.Visibility == VISIBILITY_PUBLIC {
+		return true
+	}
+	if !viewer.Valid {
+		return false
+	}
+	if e.AuthorID == viewer.UUID || slices.Contains(e.Mentions, viewer.UUID) {
+		return true
+	}
+	if e.Visibility == VISIBILITY_FOLLOWERS {
+		_, ok := following[e.AuthorID]
+		return ok
+	}
+	return false
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/caleb-fringer/chirpy/internal/apierror"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/request"
+	"github.com/google/uuid"
+)
+
+// Webhook delivery statuses.
+const (
+	WEBHOOK_RECEIVED  = "received"
+	WEBHOOK_REJECTED  = "rejected"
+	WEBHOOK_PROCESSED = "processed"
+	WEBHOOK_DUPLICATE = "duplicate"
+	WEBHOOK_FAILED    = "failed"
+)
+
+const MAX_WEBHOOK_BODY_BYTES = 1 << 20
+
+const (
+	DEFAULT_WEBHOOK_PAGE_SIZE = 50
+	MAX_WEBHOOK_PAGE_SIZE     = 200
+)
+
+// Headers that carry credentials are not stored.
+var redactedWebhookHeaders = []string{"Authorization", "Cookie"}
+
+type webhookDeliveryResponse struct {
+	ID          uuid.UUID       `json:"id"`
+	ReceivedAt  time.Time       `json:"received_at"`
+	Source      string          `json:"source"`
+	Headers     json.RawMessage `json:"headers"`
+	Body        string          `json:"body"`
+	Status      string          `json:"status"`
+	EventID     string          `json:"event_id,omitempty"`
+	Event       string          `json:"event,omitempty"`
+	Error       string          `json:"error,omitempty"`
+	ProcessedAt *time.Time      `json:"processed_at"`
+	ReplayCount int32           `json:"replay_count"`
+}
+
+func newWebhookDeliveryResponse(d database.WebhookDelivery) webhookDeliveryResponse {
+	return webhookDeliveryResponse{
+		ID:          d.ID,
+		ReceivedAt:  d.ReceivedAt,
+		Source:      d.Source,
+		Headers:     d.Headers,
+		Body:        string(d.Body),
+		Status:      d.Status,
+		EventID:     d.EventID.String,
+		Event:       d.Event.String,
+		Error:       d.Error.String,
+		ProcessedAt: nullTimePtr(d.ProcessedAt),
+		ReplayCount: d.ReplayCount,
+	}
+}
+
+func (cfg *apiConfig) recordWebhookDelivery(ctx context.Context, source string, headers http.Header, body []byte) (database.WebhookDelivery, error) {
+	stored := headers.Clone()
+	for _, h := range redactedWebhookHeaders {
+		stored.Del(h)
+	}
+
+	rawHeaders, err := json.Marshal(stored)
+	if err != nil {
+		return database.WebhookDelivery{}, err
+	}
+
+	return cfg.store.CreateWebhookDelivery(ctx, database.CreateWebhookDeliveryParams{
+		Source:  source,
+		Headers: rawHeaders,
+		Body:    body,
+	})
+}
+
+// finishWebhookDelivery records the outcome of a delivery. Failing to do so
+// is logged rather than returned, since the delivery itself was handled.
+func (cfg *apiConfig) finishWebhookDelivery(ctx context.Context, id uuid.UUID, status, eventID, event string, deliveryErr error) database.WebhookDelivery {
+	params := database.UpdateWebhookDeliveryOutcomeParams{
+		ID:      id,
+		Status:  status,
+		EventID: sql.NullString{String: eventID, Valid: eventID != ""},
+		Event:   sql.NullString{String: event, Valid: event != ""},
+	}
+	if deliveryErr != nil {
+		params.Error = sql.NullString{String: deliveryErr.Error(), Valid: true}
+	}
+
+	delivery, err := cfg.store.UpdateWebhookDeliveryOutcome(ctx, params)
+	if err != nil {
+		log.Printf("Error recording outcome of webhook delivery %s: %v\n", id, err)
+	}
+	return delivery
+}
+
+func (cfg *apiConfig) listWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !cfg.isAdmin(r) {
+		apierror.Write(w, apierror.Forbidden("Access forbidden"))
+		return
+	}
+
+	status, err := request.QueryEnum(r, "status", "",
+		WEBHOOK_RECEIVED, WEBHOOK_REJECTED, WEBHOOK_PROCESSED, WEBHOOK_DUPLICATE, WEBHOOK_FAILED)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+	before, err := request.QueryTime(r, "before", time.Now().UTC().Add(time.Second))
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+	limit, err := request.QueryInt(r, "limit", DEFAULT_WEBHOOK_PAGE_SIZE, 1, MAX_WEBHOOK_PAGE_SIZE)
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	params := database.ListWebhookDeliveriesParams{
+		Status: sql.NullString{String: status, Valid: status != ""},
+		Before: before,
+		Limit:  int32(limit),
+	}
+
+	deliveries, err := cfg.store.ListWebhookDeliveries(r.Context(), params)
+	if err != nil {
+		log.Printf("GET /admin/webhooks: Error listing deliveries: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
+		return
+	}
+
+	res := make([]webhookDeliveryResponse, 0, len(deliveries))
+	for _, d := range deliveries {
+		res = append(res, newWebhookDeliveryResponse(d))
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(res)
+	if err != nil {
+		log.Printf("GET /admin/webhooks: Error writing response: %v\n", err)
+	}
+}
+
+func (cfg *apiConfig) getWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !cfg.isAdmin(r) {
+		apierror.Write(w, apierror.Forbidden("Access forbidden"))
+		return
+	}
+
+	id, err := request.PathUUID(r, "deliveryID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	delivery, err := cfg.store.GetWebhookDelivery(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, apierror.NotFound("Delivery not found"))
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newWebhookDeliveryResponse(delivery))
+	if err != nil {
+		log.Printf("GET /admin/webhooks/%s: Error writing response: %v\n", id, err)
+	}
+}
+
+// replayWebhookDelivery runs a failed delivery through the same processing
+// as subscribe. Only deliveries that passed authentication can fail, so the
+// signature isn't checked again; its timestamp will usually be stale by now.
+func (cfg *apiConfig) replayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if !cfg.isAdmin(r) {
+		apierror.Write(w, apierror.Forbidden("Access forbidden"))
+		return
+	}
+
+	id, err := request.PathUUID(r, "deliveryID")
+	if err != nil {
+		apierror.Write(w, err)
+		return
+	}
+
+	delivery, err := cfg.store.GetWebhookDelivery(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, apierror.NotFound("Delivery not found"))
+		return
+	}
+
+	if delivery.Status != WEBHOOK_FAILED || delivery.Source != POLKA_SOURCE {
+		apierror.Write(w, apierror.Conflict("Only failed deliveries can be replayed"))
+		return
+	}
+
+	event := &polkaEvent{}
+	err = json.Unmarshal(delivery.Body, event)
+	if err != nil {
+		apierror.Write(w, apierror.New(http.StatusUnprocessableEntity, apierror.UNPROCESSABLE, "Delivery body is not a valid event"))
+		return
+	}
+
+	headers := http.Header{}
+	err = json.Unmarshal(delivery.Headers, &headers)
+	if err != nil {
+		log.Printf("POST /admin/webhooks/%s/replay: Error decoding stored headers: %v\n", id, err)
+	}
+
+	eventID := delivery.EventID.String
+	if eventID == "" {
+		eventID = cfg.polkaEventID(headers, event, delivery.Body)
+	}
+
+	err = cfg.store.IncrementWebhookReplayCount(r.Context(), id)
+	if err != nil {
+		log.Printf("POST /admin/webhooks/%s/replay: Error counting replay: %v\n", id, err)
+	}
+
+	delivery, err = cfg.runPolkaDelivery(r.Context(), id, eventID, event)
+	if err != nil && !errors.Is(err, errPolkaUserNotFound) {
+		log.Printf("POST /admin/webhooks/%s/replay: Error processing event: %v\n", id, err)
+	}
+
+	// The outcome of the replay, success or not, is in the delivery.
+	w.WriteHeader(http.StatusOK)
+	err = json.NewEncoder(w).Encode(newWebhookDeliveryResponse(delivery))
+	if err != nil {
+		log.Printf("POST /admin/webhooks/%s/replay: Error writing response: %v\n", id, err)
+	}
+}
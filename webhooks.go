@@ -1,69 +1,219 @@
 package main
 
 import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"time"
 
+	"github.com/caleb-fringer/chirpy/internal/apierror"
 	"github.com/caleb-fringer/chirpy/internal/auth"
+	"github.com/caleb-fringer/chirpy/internal/database"
+	"github.com/caleb-fringer/chirpy/internal/webhook"
 	"github.com/google/uuid"
 )
 
 const UPGRADE_EVENT = "user.upgraded"
 
-func (cfg *apiConfig) subscribe(w http.ResponseWriter, r *http.Request) {
+const POLKA_SOURCE = "polka"
+
+const (
+	POLKA_TIMESTAMP_HEADER = "X-Polka-Timestamp"
+	POLKA_SIGNATURE_HEADER = "X-Polka-Signature"
+	// Deliveries signed longer ago than this are rejected as replays.
+	POLKA_SIGNATURE_TOLERANCE = 5 * time.Minute
+)
+
+type polkaEvent struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID string `json:"user_id"`
+		// When the paid period ends, if Polka tells us.
+		PeriodEnd *time.Time `json:"period_end,omitempty"`
+	} `json:"data"`
+}
+
+var errPolkaUserNotFound = errors.New("Polka event refers to an unknown user")
+
+// authenticatePolka checks that a delivery came from Polka. When webhook
+// secrets are configured the body must carry a valid signature; otherwise
+// the legacy static API key is accepted.
+func (cfg *apiConfig) authenticatePolka(r *http.Request, body []byte) error {
+	if len(cfg.polkaSecrets) > 0 {
+		return webhook.Verify(
+			cfg.polkaSecrets,
+			r.Header.Get(POLKA_TIMESTAMP_HEADER),
+			r.Header.Get(POLKA_SIGNATURE_HEADER),
+			body,
+			POLKA_SIGNATURE_TOLERANCE,
+			time.Now(),
+		)
+	}
+
 	apiKey, err := auth.GetAPIKey(r.Header)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`{"error": "Please provide an API key in the Authorization header"}`))
-		return
+		return err
+	}
+	if subtle.ConstantTimeCompare([]byte(apiKey), []byte(cfg.polkaKey)) != 1 {
+		return errors.New("Invalid API key")
 	}
+	return nil
+}
 
-	if apiKey != cfg.polkaKey {
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write(json.RawMessage(`{"error": "Invalid API key"}`))
-		return
+// polkaEventID identifies a delivery for deduplication. Polka's event id is
+// used when present; otherwise the signed timestamp and body identify it, so
+// a captured delivery still can't be replayed inside the tolerance window.
+// Without a signed timestamp a repeated body may be a genuine second event,
+// so the delivery isn't deduplicated and the id is empty.
+func (cfg *apiConfig) polkaEventID(headers http.Header, event *polkaEvent, body []byte) string {
+	if event.ID != "" {
+		return event.ID
+	}
+	timestamp := headers.Get(POLKA_TIMESTAMP_HEADER)
+	if len(cfg.polkaSecrets) == 0 || timestamp == "" {
+		return ""
 	}
+	return "sha256:" + auth.HashToken(timestamp+"."+string(body))
+}
 
-	reqBody := &struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}{}
+func (cfg *apiConfig) subscribe(w http.ResponseWriter, r *http.Request) {
+	reqBody := &polkaEvent{}
 
-	rawReqBody, err := io.ReadAll(r.Body)
+	rawReqBody, err := io.ReadAll(http.MaxBytesReader(w, r.Body, MAX_WEBHOOK_BODY_BYTES))
 	if err != nil {
 		log.Printf("POST /api/polka/webhooks: Error reading request body: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Bad request body.}`))
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Write(w, apierror.New(http.StatusRequestEntityTooLarge, apierror.BODY_TOO_LARGE, "Request body is too large."))
+			return
+		}
+		apierror.Write(w, apierror.BadRequest("Bad request body."))
+		return
+	}
+
+	err = cfg.authenticatePolka(r, rawReqBody)
+	if err != nil {
+		log.Printf("POST /api/polka/webhooks: Rejected delivery: %v\n", err)
+		// Only the headers of a rejected delivery are logged, so nobody can
+		// put a body in front of an admin to replay.
+		rejected, recordErr := cfg.recordWebhookDelivery(r.Context(), POLKA_SOURCE, r.Header, []byte{})
+		if recordErr != nil {
+			log.Printf("POST /api/polka/webhooks: Error recording rejected delivery: %v\n", recordErr)
+		} else {
+			cfg.finishWebhookDelivery(r.Context(), rejected.ID, WEBHOOK_REJECTED, "", "", err)
+		}
+		apierror.Write(w, apierror.Unauthorized("Invalid webhook signature"))
+		return
+	}
+
+	delivery, err := cfg.recordWebhookDelivery(r.Context(), POLKA_SOURCE, r.Header, rawReqBody)
+	if err != nil {
+		log.Printf("POST /api/polka/webhooks: Error recording delivery: %v\n", err)
+		apierror.Write(w, apierror.Internal("Database error"))
 		return
 	}
 
 	err = json.Unmarshal(rawReqBody, reqBody)
 	if err != nil {
 		log.Printf("POST /api/polka/webhooks: Error unmarshalling request body: %v\n", err)
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write(json.RawMessage(`{"error": "Bad request body.}`))
+		cfg.finishWebhookDelivery(r.Context(), delivery.ID, WEBHOOK_FAILED, "", "", err)
+		apierror.Write(w, apierror.InvalidJSON("Bad request body."))
 		return
 	}
 
-	// Only respond to upgrade events
-	if reqBody.Event != UPGRADE_EVENT {
-		w.WriteHeader(http.StatusNoContent)
-		w.Write(nil)
+	_, err = cfg.runPolkaDelivery(r.Context(), delivery.ID, cfg.polkaEventID(r.Header, reqBody, rawReqBody), reqBody)
+	if errors.Is(err, errPolkaUserNotFound) {
+		apierror.Write(w, apierror.NotFound("User not found"))
 		return
 	}
-
-	err = cfg.queries.UpgradeToChirpyRed(r.Context(), uuid.MustParse(reqBody.Data.UserID))
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write(nil)
+		log.Printf("POST /api/polka/webhooks: Error processing event: %v\n", err)
+		apierror.Write(w, apierror.Internal("Error processing event"))
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
-	w.Write(nil)
 	return
 }
+
+// runPolkaDelivery processes an authenticated delivery and records the
+// outcome in the webhook log. Both subscribe and admin replays go through
+// here.
+func (cfg *apiConfig) runPolkaDelivery(ctx context.Context, deliveryID uuid.UUID, eventID string, event *polkaEvent) (database.WebhookDelivery, error) {
+	applied, err := cfg.processPolkaEvent(ctx, eventID, event)
+
+	status := WEBHOOK_PROCESSED
+	if err != nil {
+		status = WEBHOOK_FAILED
+	} else if !applied {
+		status = WEBHOOK_DUPLICATE
+	}
+
+	delivery := cfg.finishWebhookDelivery(ctx, deliveryID, status, eventID, event.Event, err)
+	return delivery, err
+}
+
+// processPolkaEvent applies event exactly once, reporting whether it was
+// applied. The event id is recorded in the same transaction as its effects,
+// so a duplicate delivery is a no-op and a failed one can be retried. Events
+// without an id are always applied.
+func (cfg *apiConfig) processPolkaEvent(ctx context.Context, eventID string, event *polkaEvent) (bool, error) {
+	tx, err := cfg.store.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if eventID != "" {
+		rows, err := tx.RecordPolkaEvent(ctx, database.RecordPolkaEventParams{
+			EventID: eventID,
+			Event:   event.Event,
+		})
+		if err != nil {
+			return false, fmt.Errorf("Error recording event %s: %v", eventID, err)
+		}
+		if rows == 0 {
+			log.Printf("Ignoring duplicate Polka event %s\n", eventID)
+			return false, nil
+		}
+	}
+
+	// Only respond to subscription events
+	if isSubscriptionEvent(event.Event) {
+		userID, err := uuid.Parse(event.Data.UserID)
+		if err != nil {
+			return false, errPolkaUserNotFound
+		}
+
+		_, err = tx.GetUserByID(ctx, userID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, errPolkaUserNotFound
+		}
+		if err != nil {
+			return false, err
+		}
+
+		err = applySubscriptionEvent(ctx, tx, userID, event.Event, event.Data.PeriodEnd)
+		if err != nil {
+			return false, err
+		}
+
+		if event.Event == UPGRADE_EVENT {
+			err = enqueueWebhookEvent(ctx, tx, userID, USER_UPGRADED_EVENT, map[string]uuid.UUID{
+				"user_id": userID,
+			})
+			if err != nil {
+				return false, err
+			}
+		}
+	}
+
+	return true, tx.Commit()
+}